@@ -13,6 +13,7 @@ import { init as initOptions } from "./ts/options";
 import { init as initAdmin } from "./ts/admin";
 import { init as initAlerts, showAlert } from "./ts/alerts";
 import { init as initAuth } from "./ts/auth";
+import { init as initBanned } from "./ts/banned";
 import { init as initHandlers } from "./ts/client";
 import { init as initConnection } from "./ts/connection";
 import { init as initDB } from "./ts/db";
@@ -36,6 +37,8 @@ async function init() {
     /* skip */
   } else if (page.stickers) {
     /* skip */
+  } else if (page.banned) {
+    initBanned();
   } else if (page.admin) {
     initAdmin();
   } else if (page.thread) {
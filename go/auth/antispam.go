@@ -16,6 +16,12 @@ const (
 
 	// Image insertion score
 	ImageScore = time.Second * 20
+
+	// Post report score
+	ReportScore = time.Second * 20
+
+	// Remote file fetch score
+	FetchScore = time.Second * 30
 )
 
 var (
@@ -25,6 +31,17 @@ var (
 	spamCounters = spamCounterMap{
 		m: make(map[string]*spamCounter, 64),
 	}
+
+	// Reports are counted separately so they don't affect posting
+	reportCounters = spamCounterMap{
+		m: make(map[string]*spamCounter, 64),
+	}
+
+	// Remote uploads make the server download files, so they are limited
+	// regardless of posting
+	fetchCounters = spamCounterMap{
+		m: make(map[string]*spamCounter, 64),
+	}
 )
 
 type spamCounterMap struct {
@@ -74,6 +91,8 @@ func init() {
 		for {
 			<-t
 			spamCounters.deleteExpired()
+			reportCounters.deleteExpired()
+			fetchCounters.deleteExpired()
 		}
 	}()
 }
@@ -147,7 +166,22 @@ func ResetSpamScore(ip string) {
 	spamCounters.get(ip).reset()
 }
 
+// Increment report score of an IP, after submitting a report. Unlike
+// posting it's always enabled since reports don't require captcha.
+// Returns, if the limit was exceeded.
+func IncrementReportScore(ip string) (bool, error) {
+	return reportCounters.get(ip).increment(ReportScore)
+}
+
+// Increment fetch score of an IP, after requesting remote upload.
+// Returns, if the limit was exceeded.
+func IncrementFetchScore(ip string) (bool, error) {
+	return fetchCounters.get(ip).increment(FetchScore)
+}
+
 // Clear all spam detection data. Only use for tests.
 func ClearSpamCounters() {
 	spamCounters.clear()
+	reportCounters.clear()
+	fetchCounters.clear()
 }
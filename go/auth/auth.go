@@ -23,9 +23,11 @@ var (
 	// for filtering in XFF IP determination.
 	ReverseProxyIP string
 
-	// board: IP: IsBanned
-	bans   = map[string]map[string]bool{}
-	bansMu sync.RWMutex
+	// ban type: board: banned IP ranges
+	bans = map[BanType]map[string]*ipTree{}
+	// ban type: board: banned account IDs
+	accountBans = map[BanType]map[string]map[string]struct{}{}
+	bansMu      sync.RWMutex
 
 	NullPositions = Positions{CurBoard: NotLoggedIn, AnyBoard: NotLoggedIn}
 )
@@ -102,31 +104,119 @@ func BcryptCompare(password string, hash []byte) error {
 
 // IsBanned returns if the IP is banned on the target board
 func IsBanned(board, ip string) (banned bool) {
+	return isBanned(RegularBan, board, ip)
+}
+
+// IsShadowBanned returns if the IP is shadow banned on the target board
+func IsShadowBanned(board, ip string) bool {
+	return isBanned(ShadowBan, board, ip)
+}
+
+func isBanned(typ BanType, board, ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
 	bansMu.RLock()
 	defer bansMu.RUnlock()
-	global := bans["all"]
-	ips := bans[board]
-	if global != nil && global[ip] {
+	global := bans[typ]["all"]
+	ips := bans[typ][board]
+	if global != nil && global.contains(addr) {
 		return true
 	}
-	if ips != nil && ips[ip] {
+	if ips != nil && ips.contains(addr) {
+		return true
+	}
+	return false
+}
+
+// IsAccountBanned returns if the account is banned on the target board
+func IsAccountBanned(board, account string) bool {
+	return isAccountBanned(RegularBan, board, account)
+}
+
+// IsAccountShadowBanned returns if the account is shadow banned on the
+// target board
+func IsAccountShadowBanned(board, account string) bool {
+	return isAccountBanned(ShadowBan, board, account)
+}
+
+func isAccountBanned(typ BanType, board, account string) bool {
+	if account == "" {
+		return false
+	}
+	bansMu.RLock()
+	defer bansMu.RUnlock()
+	if _, ok := accountBans[typ]["all"][account]; ok {
 		return true
 	}
+	_, ok := accountBans[typ][board][account]
+	return ok
+}
+
+// IsShadowBannedAnywhere returns if the IP or account is shadow banned
+// on any board
+func IsShadowBannedAnywhere(ip, account string) bool {
+	addr := net.ParseIP(ip)
+	bansMu.RLock()
+	defer bansMu.RUnlock()
+	if addr != nil {
+		for _, ips := range bans[ShadowBan] {
+			if ips.contains(addr) {
+				return true
+			}
+		}
+	}
+	if account != "" {
+		for _, accounts := range accountBans[ShadowBan] {
+			if _, ok := accounts[account]; ok {
+				return true
+			}
+		}
+	}
 	return false
 }
 
-// SetBans replaces the ban cache with the new set
+// SetBans replaces the ban cache with the new set. Ban IPs can be either
+// single addresses or CIDR ranges. Bans can target IP, account or both.
 func SetBans(b ...Ban) {
-	newBans := map[string]map[string]bool{}
+	newBans := map[BanType]map[string]*ipTree{}
+	newAccountBans := map[BanType]map[string]map[string]struct{}{}
 	for _, b := range b {
-		board, ok := newBans[b.Board]
+		if b.Account != "" {
+			boards, ok := newAccountBans[b.Type]
+			if !ok {
+				boards = map[string]map[string]struct{}{}
+				newAccountBans[b.Type] = boards
+			}
+			accounts, ok := boards[b.Board]
+			if !ok {
+				accounts = map[string]struct{}{}
+				boards[b.Board] = accounts
+			}
+			accounts[b.Account] = struct{}{}
+		}
+		if b.IP == "" {
+			continue
+		}
+		n, err := parseStoredRange(b.IP)
+		if err != nil {
+			continue
+		}
+		boards, ok := newBans[b.Type]
+		if !ok {
+			boards = map[string]*ipTree{}
+			newBans[b.Type] = boards
+		}
+		board, ok := boards[b.Board]
 		if !ok {
-			board = map[string]bool{}
-			newBans[b.Board] = board
+			board = &ipTree{}
+			boards[b.Board] = board
 		}
-		board[b.IP] = true
+		board.insert(n)
 	}
 	bansMu.Lock()
 	bans = newBans
+	accountBans = newAccountBans
 	bansMu.Unlock()
 }
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"sync"
+)
+
+var (
+	// Secret salt of IP hashes, so hashes of the small IPv4 space can't
+	// be reversed by brute force.
+	ipSalt   []byte
+	ipSaltMu sync.RWMutex
+)
+
+// SetIPSalt sets the secret used by HashIP.
+func SetIPSalt(salt []byte) {
+	ipSaltMu.Lock()
+	ipSalt = salt
+	ipSaltMu.Unlock()
+}
+
+// HashIP returns a stable keyed hash of the IP, suitable for storing
+// instead of the raw address. IPv6 addresses are hashed by their
+// subnet, same as bans. Returns empty string for an invalid IP.
+func HashIP(ip string) string {
+	n := DefaultBanRange(net.ParseIP(ip))
+	if n == nil {
+		return ""
+	}
+	ipSaltMu.RLock()
+	mac := hmac.New(sha256.New, ipSalt)
+	ipSaltMu.RUnlock()
+	mac.Write([]byte(FormatBanRange(n)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"testing"
+)
+
+func TestHashIP(t *testing.T) {
+	defer SetIPSalt(nil)
+	SetIPSalt([]byte("salt"))
+
+	h := HashIP("10.0.0.1")
+	if len(h) != 64 {
+		t.Fatalf("unexpected hash length: %d", len(h))
+	}
+	if HashIP("10.0.0.1") != h {
+		t.Fatal("hash is not stable")
+	}
+	if HashIP("10.0.0.2") == h {
+		t.Fatal("different IPs have the same hash")
+	}
+	if HashIP("2001:db8::1") != HashIP("2001:db8::ffff") {
+		t.Fatal("IPv6 subnet hashes differ")
+	}
+	if HashIP("nope") != "" {
+		t.Fatal("invalid IP is hashed")
+	}
+
+	SetIPSalt([]byte("other"))
+	if HashIP("10.0.0.1") == h {
+		t.Fatal("hash doesn't depend on salt")
+	}
+}
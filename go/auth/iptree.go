@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+// Default prefix of IPv6 bans, single address is usually handed out to
+// every device of the subscriber.
+const DefaultBanPrefixV6 = 64
+
+// Shortest allowed prefixes of range bans, to guard against mistyped
+// ranges banning half of the internet.
+const (
+	MinBanPrefixV4 = 16
+	MinBanPrefixV6 = 32
+)
+
+var ErrInvalidBanRange = errors.New("invalid ban range")
+
+// Binary radix tree of banned IP ranges. IPv4 addresses are stored in
+// IPv4-mapped IPv6 form so both families share the same tree.
+type ipTree struct {
+	root ipNode
+}
+
+type ipNode struct {
+	children [2]*ipNode
+	// Whole subtree is banned
+	leaf bool
+}
+
+// Get n-th bit of 16-byte address.
+func ipBit(ip net.IP, n int) int {
+	return int(ip[n/8]>>(7-uint(n%8))) & 1
+}
+
+// Convert network to 16-byte form with prefix length adjusted
+// accordingly.
+func toIPv6Net(n *net.IPNet) (ip net.IP, ones int) {
+	ones, bits := n.Mask.Size()
+	if bits == 8*net.IPv4len {
+		ones += 8 * (net.IPv6len - net.IPv4len)
+	}
+	return n.IP.To16(), ones
+}
+
+func (t *ipTree) insert(n *net.IPNet) {
+	ip, ones := toIPv6Net(n)
+	node := &t.root
+	for i := 0; i < ones; i++ {
+		if node.leaf {
+			// Already covered by wider range.
+			return
+		}
+		b := ipBit(ip, i)
+		if node.children[b] == nil {
+			node.children[b] = &ipNode{}
+		}
+		node = node.children[b]
+	}
+	node.leaf = true
+	// Narrower ranges are redundant now.
+	node.children = [2]*ipNode{}
+}
+
+func (t *ipTree) contains(ip net.IP) bool {
+	ip = ip.To16()
+	if ip == nil {
+		return false
+	}
+	node := &t.root
+	for i := 0; node != nil; i++ {
+		if node.leaf {
+			return true
+		}
+		if i == 8*net.IPv6len {
+			break
+		}
+		node = node.children[ipBit(ip, i)]
+	}
+	return false
+}
+
+// Parse ban range as stored in database. Plain address is banned
+// exactly.
+func parseStoredRange(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, n, err := net.ParseCIDR(s)
+		return n, err
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, ErrInvalidBanRange
+	}
+	return hostNet(ip, 0), nil
+}
+
+// Get network of the specified prefix length containing the address.
+// Zero length means full address.
+func hostNet(ip net.IP, ones int) *net.IPNet {
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		bits = 8 * net.IPv4len
+	}
+	if ones == 0 || ones > bits {
+		ones = bits
+	}
+	mask := net.CIDRMask(ones, bits)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+}
+
+// ParseBanRange parses moderator-provided IP address or CIDR range.
+// IPv6 addresses without prefix are extended to DefaultBanPrefixV6.
+// IPv4-mapped ranges are converted to IPv4 ones.
+func ParseBanRange(s string) (*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+	var n *net.IPNet
+	if strings.Contains(s, "/") {
+		_, parsed, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, ErrInvalidBanRange
+		}
+		n = parsed
+		if ip4 := n.IP.To4(); ip4 != nil && len(n.IP) == net.IPv6len {
+			ones, _ := n.Mask.Size()
+			if ones < 8*(net.IPv6len-net.IPv4len) {
+				return nil, ErrInvalidBanRange
+			}
+			ones -= 8 * (net.IPv6len - net.IPv4len)
+			n = &net.IPNet{IP: ip4, Mask: net.CIDRMask(ones, 8*net.IPv4len)}
+		}
+	} else {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, ErrInvalidBanRange
+		}
+		n = DefaultBanRange(ip)
+	}
+
+	ones, bits := n.Mask.Size()
+	min := MinBanPrefixV6
+	if bits == 8*net.IPv4len {
+		min = MinBanPrefixV4
+	}
+	if ones < min {
+		return nil, ErrInvalidBanRange
+	}
+	return n, nil
+}
+
+// DefaultBanRange returns range to ban for the poster's address or nil
+// if address is invalid.
+func DefaultBanRange(ip net.IP) *net.IPNet {
+	if ip.To16() == nil {
+		return nil
+	}
+	if ip.To4() != nil {
+		return hostNet(ip, 0)
+	}
+	return hostNet(ip, DefaultBanPrefixV6)
+}
+
+// FormatBanRange formats range the same way database does, omitting
+// prefix of single addresses.
+func FormatBanRange(n *net.IPNet) string {
+	if ones, bits := n.Mask.Size(); ones == bits {
+		return n.IP.String()
+	}
+	return n.String()
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"testing"
+)
+
+func TestIsBannedRanges(t *testing.T) {
+	defer SetBans()
+	SetBans(
+		Ban{IP: "10.0.0.1", Board: "a"},
+		Ban{IP: "192.168.1.0/24", Board: "a"},
+		Ban{IP: "2001:db8:1:2::/64", Board: "a"},
+		Ban{IP: "172.16.0.0/16", Board: "all"},
+		Ban{IP: "bad", Board: "a"},
+	)
+
+	cases := [...]struct {
+		name, board, ip string
+		banned          bool
+	}{
+		{"exact", "a", "10.0.0.1", true},
+		{"exact neighbour", "a", "10.0.0.2", false},
+		{"in range", "a", "192.168.1.200", true},
+		{"out of range", "a", "192.168.2.1", false},
+		{"other board", "b", "192.168.1.1", false},
+		{"global", "b", "172.16.5.5", true},
+		{"ipv6 in range", "a", "2001:db8:1:2:ffff::1", true},
+		{"ipv6 out of range", "a", "2001:db8:1:3::1", false},
+		{"mapped ipv4", "a", "::ffff:192.168.1.1", true},
+		{"invalid", "a", "nope", false},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if banned := IsBanned(c.board, c.ip); banned != c.banned {
+				t.Fatalf("expected %v, got %v", c.banned, banned)
+			}
+		})
+	}
+}
+
+func TestParseBanRange(t *testing.T) {
+	cases := [...]struct {
+		in, out string
+		err     error
+	}{
+		{"1.2.3.4", "1.2.3.4/32", nil},
+		{" 1.2.3.4/24 ", "1.2.3.0/24", nil},
+		{"2001:db8::1", "2001:db8::/64", nil},
+		{"2001:db8::1/48", "2001:db8::/48", nil},
+		{"1.0.0.0/8", "", ErrInvalidBanRange},
+		{"2001::/16", "", ErrInvalidBanRange},
+		{"::ffff:1.2.3.4/120", "1.2.3.0/24", nil},
+		{"::ffff:0.0.0.0/96", "", ErrInvalidBanRange},
+		{"::ffff:1.0.0.0/104", "", ErrInvalidBanRange},
+		{"nope", "", ErrInvalidBanRange},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.in, func(t *testing.T) {
+			n, err := ParseBanRange(c.in)
+			if err != c.err {
+				t.Fatalf("expected %v, got %v", c.err, err)
+			}
+			if err == nil && n.String() != c.out {
+				t.Fatalf("expected %s, got %s", c.out, n)
+			}
+		})
+	}
+}
+
+func TestIsShadowBanned(t *testing.T) {
+	defer SetBans()
+	SetBans(
+		Ban{IP: "10.0.0.0/24", Board: "a", Type: ShadowBan},
+		Ban{IP: "10.0.1.1", Board: "a"},
+	)
+
+	if IsBanned("a", "10.0.0.1") {
+		t.Fatal("shadow banned IP is banned")
+	}
+	if !IsShadowBanned("a", "10.0.0.1") {
+		t.Fatal("IP is not shadow banned")
+	}
+	if IsShadowBanned("a", "10.0.1.1") {
+		t.Fatal("banned IP is shadow banned")
+	}
+	if IsShadowBanned("b", "10.0.0.1") {
+		t.Fatal("IP is shadow banned on other board")
+	}
+}
+
+func TestIsAccountBanned(t *testing.T) {
+	defer SetBans()
+	SetBans(
+		Ban{Account: "troll", Board: "a"},
+		Ban{IP: "10.0.0.1", Account: "both", Board: "a"},
+		Ban{Account: "global", Board: "all"},
+		Ban{Account: "sneaky", Board: "a", Type: ShadowBan},
+	)
+
+	cases := [...]struct {
+		name, board, account string
+		banned               bool
+	}{
+		{"banned", "a", "troll", true},
+		{"other board", "b", "troll", false},
+		{"with IP", "a", "both", true},
+		{"global", "b", "global", true},
+		{"shadow", "a", "sneaky", false},
+		{"not banned", "a", "user", false},
+		{"anonymous", "a", "", false},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			banned := IsAccountBanned(c.board, c.account)
+			if banned != c.banned {
+				t.Fatalf("expected %v, got %v", c.banned, banned)
+			}
+		})
+	}
+
+	if !IsBanned("a", "10.0.0.1") {
+		t.Fatal("IP of account ban is not banned")
+	}
+	if !IsAccountShadowBanned("a", "sneaky") {
+		t.Fatal("account is not shadow banned")
+	}
+}
+
+func TestIsShadowBannedAnywhere(t *testing.T) {
+	defer SetBans()
+	SetBans(
+		Ban{IP: "10.0.0.0/24", Board: "a", Type: ShadowBan},
+		Ban{IP: "10.0.1.1", Board: "b"},
+		Ban{Account: "sneaky", Board: "c", Type: ShadowBan},
+	)
+
+	cases := [...]struct {
+		name, ip, account string
+		shadow            bool
+	}{
+		{"ip", "10.0.0.1", "", true},
+		{"account", "10.0.2.1", "sneaky", true},
+		{"regular ban", "10.0.1.1", "", false},
+		{"not banned", "10.0.2.1", "user", false},
+		{"invalid ip", "", "", false},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if IsShadowBannedAnywhere(c.ip, c.account) != c.shadow {
+				t.Fatalf("expected %v", c.shadow)
+			}
+		})
+	}
+}
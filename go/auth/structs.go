@@ -74,10 +74,22 @@ func (staff *Staff) TryMarshal() []byte {
 	return data
 }
 
-// Ban holdsan entry of an IP being banned from a board
+// BanType is the way ban is enforced
+type BanType uint8
+
+const (
+	// Posting is rejected
+	RegularBan BanType = iota
+	// Posts are accepted but only shown to the banned IP and moderators
+	ShadowBan
+)
+
+// Ban holdsan entry of an IP and/or account being banned from a board
 type Ban struct {
-	IP    string `json:"ip"`
-	Board string `json:"board"`
+	IP      string  `json:"ip"`
+	Account string  `json:"account"`
+	Board   string  `json:"board"`
+	Type    BanType `json:"type"`
 }
 
 // BanRecord stores information about a specific ban
@@ -114,6 +126,26 @@ const (
 	SpoilerImage
 	DeleteThread
 	UpdateBoard
+	StickThread
+	UnstickThread
+	DeleteBoard
+	RestoreBoard
+	SendNotification
+	LockThread
+	UnlockThread
+	MoveThread
+	SplitThread
+	DismissReports
+	BanRange
+	UnbanRange
+	RejectAppeal
+	ShadowBanPost
+	BanAccount
+	PurgePosts
+	AutomodMatch
+	ApprovePost
+	RejectPost
+	UnbanAccount
 )
 
 // Single entry in the moderation log
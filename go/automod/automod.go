@@ -0,0 +1,261 @@
+//go:generate easyjson $GOFILE
+
+// Package automod evaluates per-board rules which codify common
+// moderation decisions on newly created posts.
+package automod
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/util"
+)
+
+// Condition is the kind of check rule performs on a post.
+type Condition uint8
+
+// All supported rule conditions.
+// NOTE(Kagami): This is represented as number is DB, so add new items
+// to the end, don't remove!
+const (
+	// Post body matches regular expression
+	BodyMatch Condition = iota
+	// Post body links to one of space-separated domains or subdomains
+	LinkDomain
+	// One of attached files has one of space-separated SHA1 hashes
+	FileHash
+	// There are no earlier posts from the same IP
+	NewIP
+	// Poster's country code is one of space-separated codes
+	Country
+	// Pattern or more posts from the same IP within the last minute
+	PostRate
+	numConditions
+)
+
+// Action is taken on the post when rule matches.
+type Action uint8
+
+// All supported rule actions.
+// NOTE(Kagami): This is represented as number is DB, so add new items
+// to the end, don't remove!
+const (
+	// Post is rejected with rule message
+	Reject Action = iota
+	// Post is hidden until moderator approves it
+	Hold
+	// Post is only shown to its author and moderators
+	ShadowBan
+	// Post is reported to moderators with rule message
+	Report
+	// Poster should solve captcha in order to post
+	RequireCaptcha
+	numActions
+)
+
+// Rule is a single automoderation rule of the board.
+type Rule struct {
+	Board     string    `json:"board"`
+	Condition Condition `json:"condition"`
+	Pattern   string    `json:"pattern"`
+	Action    Action    `json:"action"`
+	Message   string    `json:"message"`
+	// Matches are only logged, action isn't taken
+	DryRun bool `json:"dryRun"`
+	// Index of the rule among rules of the board, set by SetRules
+	Position int `json:"-"`
+}
+
+//easyjson:json
+type Rules []Rule
+
+func (rules *Rules) TryMarshal() []byte {
+	data, err := rules.MarshalJSON()
+	if err != nil {
+		return []byte("null")
+	}
+	return data
+}
+
+// Post contains data of the post being created which rules are
+// checked against.
+type Post struct {
+	Board   string
+	Body    string
+	Country string
+	SHA1s   []string
+	// Post history of the IP is only queried if some rule needs it.
+	IsNewIP     func() (bool, error)
+	RecentPosts func() (int, error)
+}
+
+var (
+	errInvalidCondition = errors.New("invalid automod condition")
+	errInvalidAction    = errors.New("invalid automod action")
+	errNoPattern        = errors.New("no automod pattern")
+	errPatternTooLong   = errors.New("automod pattern too long")
+	errMessageTooLong   = errors.New("automod message too long")
+	errInvalidRate      = errors.New("invalid automod post rate")
+
+	linkRe = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"]+`)
+
+	mu    sync.RWMutex
+	rules = map[string][]compiledRule{}
+)
+
+// Rule with pattern parsed once to check posts faster.
+type compiledRule struct {
+	Rule
+	re   *regexp.Regexp
+	rate int
+}
+
+func compile(r Rule) (c compiledRule, err error) {
+	c.Rule = r
+	switch {
+	case r.Condition >= numConditions:
+		err = errInvalidCondition
+		return
+	case r.Action >= numActions:
+		err = errInvalidAction
+		return
+	case len(r.Pattern) > common.MaxLenAutomodPattern:
+		err = errPatternTooLong
+		return
+	case len(r.Message) > common.MaxLenAutomodMessage:
+		err = errMessageTooLong
+		return
+	case r.Condition != NewIP && strings.TrimSpace(r.Pattern) == "":
+		err = errNoPattern
+		return
+	}
+	switch r.Condition {
+	case BodyMatch:
+		c.re, err = regexp.Compile(r.Pattern)
+	case PostRate:
+		c.rate, err = strconv.Atoi(strings.TrimSpace(r.Pattern))
+		if err != nil || c.rate <= 0 {
+			err = errInvalidRate
+		}
+	}
+	return
+}
+
+// Validate checks that rule is well-formed and can be used.
+func Validate(r Rule) error {
+	_, err := compile(r)
+	return err
+}
+
+// SetRules replaces cached rules of all boards. Rules of the same
+// board are checked in the order given.
+func SetRules(rs ...Rule) {
+	m := make(map[string][]compiledRule, len(rs))
+	positions := make(map[string]int, len(rs))
+	for _, r := range rs {
+		// Invalid rules still take their position
+		r.Position = positions[r.Board]
+		positions[r.Board]++
+		c, err := compile(r)
+		if err != nil {
+			log.Printf("automod: skipping rule of /%s/: %s", r.Board, err)
+			continue
+		}
+		m[r.Board] = append(m[r.Board], c)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	rules = m
+}
+
+// Check returns all rules of the post's board which match it.
+func Check(p Post) (matched Rules, err error) {
+	mu.RLock()
+	rs := rules[p.Board]
+	mu.RUnlock()
+
+	var (
+		links     []string
+		parsed    bool
+		newIP     *bool
+		rateCount *int
+	)
+	for _, r := range rs {
+		var ok bool
+		switch r.Condition {
+		case BodyMatch:
+			ok = r.re.MatchString(p.Body)
+		case LinkDomain:
+			if !parsed {
+				links = linkHosts(p.Body)
+				parsed = true
+			}
+			for _, host := range links {
+				if util.MatchDomain(host, r.Pattern) {
+					ok = true
+					break
+				}
+			}
+		case FileHash:
+			for _, sha1 := range p.SHA1s {
+				if hasField(r.Pattern, sha1) {
+					ok = true
+					break
+				}
+			}
+		case NewIP:
+			if newIP == nil {
+				var v bool
+				if v, err = p.IsNewIP(); err != nil {
+					return
+				}
+				newIP = &v
+			}
+			ok = *newIP
+		case Country:
+			ok = p.Country != "" && hasField(r.Pattern, p.Country)
+		case PostRate:
+			if rateCount == nil {
+				var n int
+				if n, err = p.RecentPosts(); err != nil {
+					return
+				}
+				rateCount = &n
+			}
+			ok = *rateCount >= r.rate
+		}
+		if ok {
+			matched = append(matched, r.Rule)
+		}
+	}
+	return
+}
+
+// Extract lowercased hosts of all links in the post body.
+func linkHosts(body string) (hosts []string) {
+	for _, link := range linkRe.FindAllString(body, -1) {
+		u, err := url.Parse(link)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		hosts = append(hosts, strings.ToLower(u.Hostname()))
+	}
+	return
+}
+
+// Case-insensitive check whether s is one of space-separated fields.
+func hasField(fields, s string) bool {
+	for _, f := range strings.Fields(fields) {
+		if strings.EqualFold(f, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package automod
+
+import (
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	cases := [...]struct {
+		name  string
+		rule  Rule
+		valid bool
+	}{
+		{"regex", Rule{Condition: BodyMatch, Pattern: `(?i)buy\s+now`}, true},
+		{"bad regex", Rule{Condition: BodyMatch, Pattern: `(`}, false},
+		{"no pattern", Rule{Condition: LinkDomain, Pattern: " "}, false},
+		{"new ip", Rule{Condition: NewIP}, true},
+		{"rate", Rule{Condition: PostRate, Pattern: "5"}, true},
+		{"bad rate", Rule{Condition: PostRate, Pattern: "0"}, false},
+		{"bad condition", Rule{Condition: numConditions, Pattern: "x"}, false},
+		{"bad action", Rule{Condition: NewIP, Action: numActions}, false},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if err := Validate(c.rule); (err == nil) != c.valid {
+				t.Fatalf("expected valid=%v, got %v", c.valid, err)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	defer SetRules()
+	SetRules(
+		Rule{Board: "a", Condition: BodyMatch, Pattern: `(?i)casino`},
+		Rule{Board: "a", Condition: LinkDomain, Pattern: "spam.com"},
+		Rule{Board: "a", Condition: FileHash, Pattern: "abc def"},
+		Rule{Board: "a", Condition: Country, Pattern: "xx yy"},
+		Rule{Board: "a", Condition: PostRate, Pattern: "3"},
+		Rule{Board: "b", Condition: NewIP, Action: Hold},
+	)
+
+	cases := [...]struct {
+		name    string
+		post    Post
+		matched int
+	}{
+		{"clean", Post{Board: "a", Body: "hello"}, 0},
+		{"body", Post{Board: "a", Body: "best CASINO"}, 1},
+		{"subdomain link", Post{Board: "a", Body: "see https://x.spam.com/a"}, 1},
+		{"other link", Post{Board: "a", Body: "see https://notspam.com/"}, 0},
+		{"file", Post{Board: "a", SHA1s: []string{"DEF"}}, 1},
+		{"country", Post{Board: "a", Country: "YY"}, 1},
+		{"no country", Post{Board: "a"}, 0},
+		{"other board", Post{Board: "c", Body: "casino"}, 0},
+		{"new ip", Post{Board: "b"}, 1},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			c.post.IsNewIP = func() (bool, error) { return true, nil }
+			c.post.RecentPosts = func() (int, error) { return 0, nil }
+			matched, err := Check(c.post)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(matched) != c.matched {
+				t.Fatalf("expected %d matches, got %v", c.matched, matched)
+			}
+		})
+	}
+
+	t.Run("rate", func(t *testing.T) {
+		queries := 0
+		p := Post{
+			Board: "a",
+			RecentPosts: func() (int, error) {
+				queries++
+				return 3, nil
+			},
+		}
+		matched, err := Check(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matched) != 1 || matched[0].Condition != PostRate {
+			t.Fatalf("unexpected matches: %v", matched)
+		}
+		if queries != 1 {
+			t.Fatalf("expected 1 query, got %d", queries)
+		}
+	})
+}
+
+func TestRulePosition(t *testing.T) {
+	defer SetRules()
+	SetRules(
+		Rule{Board: "a", Condition: BodyMatch, Pattern: "x"},
+		Rule{Board: "b", Condition: BodyMatch, Pattern: "x"},
+		Rule{Board: "a", Condition: BodyMatch, Pattern: "("},
+		Rule{Board: "a", Condition: BodyMatch, Pattern: "y"},
+	)
+	matched, err := Check(Post{Board: "a", Body: "y"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matched) != 1 || matched[0].Position != 2 {
+		t.Fatalf("unexpected matches: %v", matched)
+	}
+}
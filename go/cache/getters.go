@@ -48,7 +48,7 @@ func getData(s *store, f FrontEnd) (
 	data interface{}, buf []byte, ctr uint64, fresh bool, err error,
 ) {
 	// Have cached data and json
-	if s.data != nil {
+	if s.data != nil && !s.isStale() {
 		if s.isFresh() {
 			// No freshness check needed yet
 			return s.data, s.json, s.updateCounter, false, nil
@@ -87,6 +87,7 @@ func getData(s *store, f FrontEnd) (
 	}
 
 	s.lastChecked = time.Now()
+	s.lastFetched = s.lastChecked
 	return
 }
 
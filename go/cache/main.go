@@ -8,8 +8,14 @@ import (
 	"time"
 )
 
-// Time for the cache to expire and need counter comparison
-const expiryTime = time.Second
+const (
+	// Time for the cache to expire and need counter comparison
+	expiryTime = time.Second
+	// Data is refetched after this time even if counter hasn't changed, so
+	// signed file URLs embedded into it don't expire. Must be less than
+	// their 24 hours lifetime.
+	maxDataAge = 12 * time.Hour
+)
 
 var (
 	cache     = make(map[Key]*list.Element, 10)
@@ -29,6 +35,8 @@ type Key struct {
 	LastN   int
 	Page    int
 	Catalog bool
+	// Visibility of shadow banned posts, see db.ShadowAll
+	Shadow string
 }
 
 // Single cache entry
@@ -38,6 +46,7 @@ type store struct {
 	key           Key
 	updateCounter uint64
 	lastChecked   time.Time
+	lastFetched   time.Time
 	data          interface{}
 	html, json    []byte
 
@@ -72,6 +81,25 @@ func Clear() {
 	cache = make(map[Key]*list.Element, 10)
 }
 
+// Delete entries with matching keys, so they are regenerated on next
+// request regardless of update counter.
+func Delete(match func(Key) bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for k, el := range cache {
+		if !match(k) {
+			continue
+		}
+		s := ll.Remove(el).(*store)
+		delete(cache, k)
+
+		s.sizeMu.Lock()
+		totalUsed -= s.size
+		s.sizeMu.Unlock()
+	}
+}
+
 // Update the total used memory counter and evict, if over limit
 func updateUsedSize(delta int) {
 	mu.Lock()
@@ -98,6 +126,11 @@ func (s *store) isFresh() bool {
 	return time.Now().Sub(s.lastChecked) < expiryTime
 }
 
+// Return, if the data was fetched too long ago and must be refetched
+func (s *store) isStale() bool {
+	return time.Now().Sub(s.lastFetched) >= maxDataAge
+}
+
 // Stores the new values of s. Calculates and stores the new size. Passes the
 // delta to the central cache to fire eviction checks.
 func (s *store) update(data interface{}, json, html []byte, f FrontEnd) {
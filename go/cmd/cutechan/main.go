@@ -10,6 +10,7 @@ import (
 	"github.com/cutechan/cutechan/go/db"
 	"github.com/cutechan/cutechan/go/file"
 	"github.com/cutechan/cutechan/go/geoip"
+	"github.com/cutechan/cutechan/go/ipc"
 	"github.com/cutechan/cutechan/go/lang"
 	"github.com/cutechan/cutechan/go/server"
 	"github.com/cutechan/cutechan/go/templates"
@@ -26,11 +27,23 @@ const VERSION = "0.0.0"
 const USAGE = `
 Usage:
   cutechan [options]
+  cutechan backfill-thumbs [options]
+  cutechan migrate-files --from <backend> --to <backend> [--dry-run] [-j <jobs>] [--checkpoint <path>] [options]
+  cutechan fsck-files [--regen-thumbs] [--delete-orphans] [options]
+  cutechan rethumb [--board <board>] [--since <date>] [--until <date>] [--type <types>] [-j <jobs>] [options]
   cutechan [-h | --help]
   cutechan [-V | --version]
 
 Serve a k-pop oriented imageboard.
 
+Commands:
+  backfill-thumbs  Generate missing thumbnail renditions for old uploads.
+  migrate-files    Copy uploads to another backend. Backends are
+                   configured with migrate_from/migrate_to settings,
+                   file_* ones are used if not set.
+  fsck-files       Check uploads for missing, orphaned and broken files.
+  rethumb          Regenerate thumbnails with current thumbnailer settings.
+
 Options:
   -h --help     Show this screen.
   -V --version  Show version.
@@ -45,6 +58,26 @@ Options:
   -z <size>     Cache size in megabytes (default: 128).
   -s <sitedir>  Site directory location (default: ./dist).
   --cfg <path>  Path to TOML config.
+
+Migration options:
+  --from <backend>     Backend to copy uploads from.
+  --to <backend>       Backend to copy uploads to.
+  --dry-run            Only report what would be copied.
+  -j <jobs>            Number of concurrent copies or thumbnailer
+                       processes (default: 4).
+  --checkpoint <path>  Progress file to resume interrupted migration
+                       (default: ./migrate-files.checkpoint). Images
+                       failed to copy are listed in <path>.failed.
+
+Check options:
+  --regen-thumbs    Regenerate missing thumbnails from sources.
+  --delete-orphans  Delete files which don't belong to any image.
+
+Rethumb options:
+  --board <board>  Only files posted to the board.
+  --since <date>   Only files posted since the date, YYYY-MM-DD.
+  --until <date>   Only files posted before the date, YYYY-MM-DD.
+  --type <types>   Only files of comma-separated types, e.g. jpg,png.
 `
 
 // Duplicates USAGE so make sure to update consistently!
@@ -65,28 +98,81 @@ var confDefault = config{
 	FilePassword:  "password",
 	FileAuthURL:   "https://localhost/v1.0",
 	FileContainer: "uploads",
+	FileEndpoint:  "http://localhost:9000",
+	FileRegion:    "us-east-1",
+
+	FileQuorum: 1,
+	FileQueue:  "./mirror-queue.json",
+
+	MigrateJobs:       4,
+	MigrateCheckpoint: "./migrate-files.checkpoint",
 }
 
 type config struct {
-	Debug         bool
-	Host          string `docopt:"-H"`
-	Port          int    `docopt:"-p"`
-	Conn          string `docopt:"-c"`
-	Rproxy        bool   `docopt:"-r"`
-	Secure        bool   `docopt:"-y"`
-	User          string `docopt:"-u"`
-	Cache         int    `docopt:"-z"`
-	SiteDir       string `docopt:"-s" toml:"site_dir"`
-	GeoHeader     string `docopt:"-g" toml:"geo_header"`
-	Path          string `docopt:"--cfg" toml:"-"`
-	FileBackend   string `toml:"file_backend"`
-	FileDir       string `toml:"file_dir"`
-	FileAddress   string `toml:"file_address"`
-	FileHostKey   string `toml:"file_host_key"`
-	FileUsername  string `toml:"file_username"`
-	FilePassword  string `toml:"file_password"`
-	FileAuthURL   string `toml:"file_auth_url"`
-	FileContainer string `toml:"file_container"`
+	// Commands.
+	BackfillThumbs    bool   `docopt:"backfill-thumbs" toml:"-"`
+	MigrateFiles      bool   `docopt:"migrate-files" toml:"-"`
+	MigrateFrom       string `docopt:"--from" toml:"-"`
+	MigrateTo         string `docopt:"--to" toml:"-"`
+	MigrateDryRun     bool   `docopt:"--dry-run" toml:"-"`
+	MigrateJobs       int    `docopt:"-j" toml:"-"`
+	MigrateCheckpoint string `docopt:"--checkpoint" toml:"-"`
+	CheckFiles        bool   `docopt:"fsck-files" toml:"-"`
+	CheckRegenThumbs  bool   `docopt:"--regen-thumbs" toml:"-"`
+	CheckDeleteOrphan bool   `docopt:"--delete-orphans" toml:"-"`
+	Rethumb           bool   `docopt:"rethumb" toml:"-"`
+	RethumbBoard      string `docopt:"--board" toml:"-"`
+	RethumbSince      string `docopt:"--since" toml:"-"`
+	RethumbUntil      string `docopt:"--until" toml:"-"`
+	RethumbTypes      string `docopt:"--type" toml:"-"`
+
+	Debug          bool
+	Host           string         `docopt:"-H"`
+	Port           int            `docopt:"-p"`
+	Conn           string         `docopt:"-c"`
+	Rproxy         bool           `docopt:"-r"`
+	Secure         bool           `docopt:"-y"`
+	User           string         `docopt:"-u"`
+	Cache          int            `docopt:"-z"`
+	SiteDir        string         `docopt:"-s" toml:"site_dir"`
+	GeoHeader      string         `docopt:"-g" toml:"geo_header"`
+	Path           string         `docopt:"--cfg" toml:"-"`
+	FileBackend    string         `toml:"file_backend"`
+	FileDir        string         `toml:"file_dir"`
+	FileAddress    string         `toml:"file_address"`
+	FileHostKey    string         `toml:"file_host_key"`
+	FileUsername   string         `toml:"file_username"`
+	FilePassword   string         `toml:"file_password"`
+	FileAuthURL    string         `toml:"file_auth_url"`
+	FileContainer  string         `toml:"file_container"`
+	FileEndpoint   string         `toml:"file_endpoint"`
+	FileRegion     string         `toml:"file_region"`
+	FilePathStyle  bool           `toml:"file_path_style"`
+	FilePublicRead bool           `toml:"file_public_read"`
+	FileMirrors    []mirrorConfig `toml:"file_mirrors"`
+	FileQuorum     int            `toml:"file_quorum"`
+	FileQueue      string         `toml:"file_queue"`
+	FileSignKey    string         `toml:"file_sign_key"`
+	ThumbWorkers   []string       `toml:"thumb_workers"`
+	ThumbSecret    string         `toml:"thumb_secret"`
+	MigrateFromCfg mirrorConfig   `toml:"migrate_from"`
+	MigrateToCfg   mirrorConfig   `toml:"migrate_to"`
+}
+
+// Settings of the single mirror backend, same as file_* options.
+type mirrorConfig struct {
+	Backend    string `toml:"backend"`
+	Dir        string `toml:"dir"`
+	Address    string `toml:"address"`
+	HostKey    string `toml:"host_key"`
+	Username   string `toml:"username"`
+	Password   string `toml:"password"`
+	AuthURL    string `toml:"auth_url"`
+	Container  string `toml:"container"`
+	Endpoint   string `toml:"endpoint"`
+	Region     string `toml:"region"`
+	PathStyle  bool   `toml:"path_style"`
+	PublicRead bool   `toml:"public_read"`
 }
 
 // Merge non-zero values from additional config.
@@ -113,24 +199,62 @@ func merge(conf, confAdd, confDef *config) {
 	}
 }
 
+func isValidBackend(name string) bool {
+	return name == "fs" || name == "sftp" || name == "swift" || name == "s3" || name == "mirror"
+}
+
+func (m mirrorConfig) fileConfig() file.Config {
+	return file.Config{
+		Backend:    m.Backend,
+		Dir:        m.Dir,
+		Address:    m.Address,
+		HostKey:    m.HostKey,
+		Username:   m.Username,
+		Password:   m.Password,
+		AuthURL:    m.AuthURL,
+		Container:  m.Container,
+		Endpoint:   m.Endpoint,
+		Region:     m.Region,
+		PathStyle:  m.PathStyle,
+		PublicRead: m.PublicRead,
+	}
+}
+
+func getFileConfig(conf config, backend string) file.Config {
+	var mirrors []file.Config
+	for _, m := range conf.FileMirrors {
+		mirrors = append(mirrors, m.fileConfig())
+	}
+	return file.Config{
+		Backend:    backend,
+		Dir:        conf.FileDir,
+		Address:    conf.FileAddress,
+		HostKey:    conf.FileHostKey,
+		Username:   conf.FileUsername,
+		Password:   conf.FilePassword,
+		AuthURL:    conf.FileAuthURL,
+		Container:  conf.FileContainer,
+		Endpoint:   conf.FileEndpoint,
+		Region:     conf.FileRegion,
+		PathStyle:  conf.FilePathStyle,
+		PublicRead: conf.FilePublicRead,
+		Mirrors:    mirrors,
+		Quorum:     conf.FileQuorum,
+		Queue:      conf.FileQueue,
+		SignKey:    conf.FileSignKey,
+	}
+}
+
 func serve(conf config) {
 	// TODO(Kagami): Use config structs instead of globals.
 	db.ConnArgs = conf.Conn
 	cache.Size = conf.Cache
 	auth.IsReverseProxied = conf.Rproxy
 	geoip.CountryHeader = conf.GeoHeader
+	ipc.StartRemoteWorkers(conf.ThumbWorkers, conf.ThumbSecret)
 
 	startFileBackend := func() error {
-		return file.StartBackend(file.Config{
-			Backend:   conf.FileBackend,
-			Dir:       conf.FileDir,
-			Address:   conf.FileAddress,
-			HostKey:   conf.FileHostKey,
-			Username:  conf.FileUsername,
-			Password:  conf.FilePassword,
-			AuthURL:   conf.FileAuthURL,
-			Container: conf.FileContainer,
-		})
+		return file.StartBackend(getFileConfig(conf, conf.FileBackend))
 	}
 
 	// Prepare subsystems.
@@ -139,6 +263,33 @@ func serve(conf config) {
 		log.Fatalf("Error preparing server: %v", err)
 	}
 
+	if conf.BackfillThumbs {
+		if err := server.BackfillThumbs(conf.User); err != nil {
+			log.Fatalf("Error generating thumbnails: %v", err)
+		}
+		return
+	}
+	if conf.MigrateFiles {
+		if err := migrateFiles(conf); err != nil {
+			log.Fatalf("Error migrating files: %v", err)
+		}
+		return
+	}
+
+	if conf.CheckFiles {
+		err := server.CheckFiles(conf.User, conf.CheckRegenThumbs, conf.CheckDeleteOrphan)
+		if err != nil {
+			log.Fatalf("Error checking files: %v", err)
+		}
+		return
+	}
+	if conf.Rethumb {
+		if err := rethumb(conf); err != nil {
+			log.Fatalf("Error regenerating thumbnails: %v", err)
+		}
+		return
+	}
+
 	// Start serving requests.
 	address := fmt.Sprintf("%v:%v", conf.Host, conf.Port)
 	log.Printf("Listening on %v", address)
@@ -169,9 +320,14 @@ func main() {
 	}
 	merge(&conf, &confFromFile, &confDefault)
 
-	if conf.FileBackend != "fs" && conf.FileBackend != "sftp" && conf.FileBackend != "swift" {
+	if !isValidBackend(conf.FileBackend) {
 		log.Fatalf("Bad uploads backend: %s", conf.FileBackend)
 	}
+	for _, m := range conf.FileMirrors {
+		if m.Backend == "mirror" || !isValidBackend(m.Backend) {
+			log.Fatalf("Bad mirror backend: %s", m.Backend)
+		}
+	}
 
 	serve(conf)
 }
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/db"
+	"github.com/cutechan/cutechan/go/file"
+)
+
+const migrateBatchSize = 100
+
+// Subset of file backend methods required to copy files.
+type fileStore interface {
+	Write(sha1 string, fileType, thumbType uint8, src, thumb []byte) error
+	WriteThumbs(sha1 string, thumbs []file.Thumb) error
+	ReadSource(sha1 string, fileType uint8) ([]byte, error)
+	ReadThumb(sha1 string, thumb file.Thumb) ([]byte, error)
+	Stat(name string) (file.Object, error)
+}
+
+type migration struct {
+	from, to   fileStore
+	dryRun     bool
+	jobs       int
+	checkpoint string
+
+	mu                  sync.Mutex
+	images, files, size int
+	failed              []string
+}
+
+// Copy all uploads between backends. Images are processed in batches
+// ordered by SHA1 and last SHA1 of the processed batch is stored in
+// checkpoint file so interrupted migration can be resumed. Copying is
+// idempotent so it's fine to redo the last batch. Images which failed to
+// copy are logged and listed in the failures file next to checkpoint.
+func migrateFiles(conf config) (err error) {
+	for _, name := range [...]string{conf.MigrateFrom, conf.MigrateTo} {
+		if !isValidBackend(name) {
+			return fmt.Errorf("bad uploads backend: %s", name)
+		}
+	}
+	if conf.MigrateFrom == conf.MigrateTo {
+		return fmt.Errorf("source and destination backends are the same")
+	}
+	if conf.MigrateJobs < 1 {
+		return fmt.Errorf("bad concurrency: %d", conf.MigrateJobs)
+	}
+
+	m := migration{
+		dryRun:     conf.MigrateDryRun,
+		jobs:       conf.MigrateJobs,
+		checkpoint: conf.MigrateCheckpoint,
+	}
+	fromConf := getMigrateConfig(conf, conf.MigrateFrom, conf.MigrateFromCfg)
+	if m.from, err = file.MakeBackend(fromConf); err != nil {
+		return
+	}
+	if !m.dryRun {
+		toConf := getMigrateConfig(conf, conf.MigrateTo, conf.MigrateToCfg)
+		if m.to, err = file.MakeBackend(toConf); err != nil {
+			return
+		}
+	}
+	return m.run()
+}
+
+// Get settings of the migration side. Backends often differ not only by
+// type but also by credentials, so separate settings can be given for
+// each side. Mirror settings are always shared with the main config.
+func getMigrateConfig(conf config, backend string, side mirrorConfig) file.Config {
+	c := getFileConfig(conf, backend)
+	if side == (mirrorConfig{}) {
+		return c
+	}
+	sc := side.fileConfig()
+	sc.Backend = backend
+	sc.Mirrors = c.Mirrors
+	sc.Quorum = c.Quorum
+	sc.Queue = c.Queue
+	sc.SignKey = c.SignKey
+	return sc
+}
+
+func (m *migration) run() (err error) {
+	after, err := m.readCheckpoint()
+	if err != nil {
+		return
+	}
+	if after != "" {
+		log.Printf("migrate: resuming after %s", after)
+	}
+	if err = m.pass(after, false); err != nil {
+		return
+	}
+	if m.dryRun {
+		log.Printf("migrate: would copy %d images (%d files, %d source bytes)", m.images, m.files, m.size)
+		return
+	}
+
+	// Server keeps accepting uploads during migration, so images with
+	// SHA1 below checkpoint might be added after it was passed. Resumed
+	// migration also skips them. Copy everything still missing.
+	log.Print("migrate: catching up")
+	if err = m.pass("", true); err != nil {
+		return
+	}
+	log.Printf("migrate: %d images copied", m.images)
+	return m.writeFailures()
+}
+
+// Process all images after the given SHA1. In catch up mode only images
+// missing in destination are copied and checkpoint isn't updated.
+func (m *migration) pass(after string, catchUp bool) (err error) {
+	for {
+		var imgs []common.ImageCommon
+		imgs, err = db.GetImages(after, migrateBatchSize)
+		if err != nil || len(imgs) == 0 {
+			return
+		}
+		after = imgs[len(imgs)-1].SHA1
+		if catchUp {
+			if imgs, err = m.filterMissing(imgs); err != nil {
+				return
+			}
+		}
+		if m.dryRun {
+			m.countBatch(imgs)
+			continue
+		}
+		m.copyBatch(imgs)
+		if catchUp {
+			continue
+		}
+		if err = m.writeCheckpoint(after); err != nil {
+			return
+		}
+		log.Printf("migrate: %d images copied", m.images)
+	}
+}
+
+func (m *migration) countBatch(imgs []common.ImageCommon) {
+	for _, img := range imgs {
+		n := 1 + len(file.Variants(&img))
+		if file.HasThumb(&img) {
+			n++
+		}
+		log.Printf("migrate: would copy %s (%d files, %d source bytes)", img.SHA1, n, img.Size)
+		m.images++
+		m.files += n
+		m.size += img.Size
+	}
+}
+
+// Leave only images which source file isn't stored in destination.
+func (m *migration) filterMissing(imgs []common.ImageCommon) (
+	missing []common.ImageCommon, err error,
+) {
+	for _, img := range imgs {
+		_, err = m.to.Stat(file.SourceName(img.FileType, img.SHA1))
+		switch err {
+		case nil:
+		case file.ErrNotExist:
+			missing = append(missing, img)
+		default:
+			return
+		}
+	}
+	err = nil
+	return
+}
+
+// Copy batch concurrently. Failed images are logged and skipped so a
+// single broken file doesn't stall the migration.
+func (m *migration) copyBatch(imgs []common.ImageCommon) {
+	ch := make(chan common.ImageCommon)
+	var wg sync.WaitGroup
+	for i := 0; i < m.jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for img := range ch {
+				err := m.copyImage(img)
+				m.mu.Lock()
+				if err != nil {
+					log.Printf("migrate: %s: %v", img.SHA1, err)
+					m.failed = append(m.failed, img.SHA1)
+				} else {
+					m.images++
+				}
+				m.mu.Unlock()
+			}
+		}()
+	}
+	for _, img := range imgs {
+		ch <- img
+	}
+	close(ch)
+	wg.Wait()
+}
+
+func (m *migration) copyImage(img common.ImageCommon) (err error) {
+	src, err := m.from.ReadSource(img.SHA1, img.FileType)
+	if err != nil {
+		return
+	}
+	// Don't spread already broken files.
+	if err = verifySource(img, src); err != nil {
+		return fmt.Errorf("bad source: %v", err)
+	}
+	var thumb []byte
+	base := file.Thumb{Type: img.ThumbType}
+	if file.HasThumb(&img) {
+		if thumb, err = m.from.ReadThumb(img.SHA1, base); err != nil {
+			return
+		}
+	}
+	thumbs := file.Variants(&img)
+	for i := range thumbs {
+		if thumbs[i].Data, err = m.from.ReadThumb(img.SHA1, thumbs[i]); err != nil {
+			return
+		}
+	}
+
+	if err = m.to.Write(img.SHA1, img.FileType, img.ThumbType, src, thumb); err != nil {
+		return
+	}
+	if err = m.to.WriteThumbs(img.SHA1, thumbs); err != nil {
+		return
+	}
+
+	// Read everything back to make sure it was stored correctly.
+	data, err := m.to.ReadSource(img.SHA1, img.FileType)
+	if err != nil {
+		return
+	}
+	if err = verifyCopy(src, data); err != nil {
+		return fmt.Errorf("bad source copy: %v", err)
+	}
+	if thumb != nil {
+		thumbs = append(thumbs, file.Thumb{Type: base.Type, Data: thumb})
+	}
+	for _, t := range thumbs {
+		if data, err = m.to.ReadThumb(img.SHA1, t); err != nil {
+			return
+		}
+		if err = verifyCopy(t.Data, data); err != nil {
+			return fmt.Errorf("bad thumbnail copy: %v", err)
+		}
+	}
+	return
+}
+
+func hashHex(data []byte) string {
+	hash := sha1.Sum(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func verifySource(img common.ImageCommon, data []byte) error {
+	if len(data) != img.Size {
+		return fmt.Errorf("size mismatch: expected %d, got %d", img.Size, len(data))
+	}
+	if hash := hashHex(data); hash != img.SHA1 {
+		return fmt.Errorf("hash mismatch: got %s", hash)
+	}
+	return nil
+}
+
+func verifyCopy(orig, data []byte) error {
+	if len(data) != len(orig) {
+		return fmt.Errorf("size mismatch: expected %d, got %d", len(orig), len(data))
+	}
+	if hashHex(data) != hashHex(orig) {
+		return fmt.Errorf("hash mismatch")
+	}
+	return nil
+}
+
+func (m *migration) readCheckpoint() (string, error) {
+	data, err := ioutil.ReadFile(m.checkpoint)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return strings.TrimSpace(string(data)), err
+}
+
+// Write to temporary file first so checkpoint is never left truncated.
+func (m *migration) writeCheckpoint(sha1 string) error {
+	tmp := m.checkpoint + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(sha1+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.checkpoint)
+}
+
+// List SHA1s of images failed to copy in the failures file. Images
+// retried on catch up might be listed even if they were copied then,
+// it's fine to copy them again.
+func (m *migration) writeFailures() error {
+	name := m.checkpoint + ".failed"
+	if len(m.failed) == 0 {
+		err := os.Remove(name)
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return err
+	}
+	sort.Strings(m.failed)
+	var data string
+	for i, sha1 := range m.failed {
+		if i == 0 || sha1 != m.failed[i-1] {
+			data += sha1 + "\n"
+		}
+	}
+	if err := ioutil.WriteFile(name, []byte(data), 0644); err != nil {
+		return err
+	}
+	return fmt.Errorf("failed to copy %d images, see %s", len(m.failed), name)
+}
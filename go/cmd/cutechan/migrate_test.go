@@ -0,0 +1,154 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/file"
+	. "github.com/cutechan/cutechan/go/test"
+)
+
+func TestGetMigrateConfig(t *testing.T) {
+	conf := confDefault
+	conf.FileUsername = "main"
+	conf.FileMirrors = []mirrorConfig{{Backend: "fs", Dir: "./mirror"}}
+
+	c := getMigrateConfig(conf, "sftp", mirrorConfig{})
+	AssertDeepEquals(t, c.Backend, "sftp")
+	AssertDeepEquals(t, c.Username, "main")
+
+	c = getMigrateConfig(conf, "s3", mirrorConfig{
+		Backend:  "fs",
+		Username: "other",
+		Region:   "eu-west-1",
+	})
+	AssertDeepEquals(t, c.Backend, "s3")
+	AssertDeepEquals(t, c.Username, "other")
+	AssertDeepEquals(t, c.Region, "eu-west-1")
+	AssertDeepEquals(t, c.Endpoint, "")
+	AssertDeepEquals(t, len(c.Mirrors), 1)
+}
+
+func TestCopyImage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cutechan-migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var stores [2]fileStore
+	for i, name := range [...]string{"from", "to"} {
+		stores[i], err = file.MakeBackend(file.Config{
+			Backend: "fs",
+			Dir:     filepath.Join(dir, name),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := migration{from: stores[0], to: stores[1], jobs: 1}
+
+	src := []byte("source")
+	img := common.ImageCommon{
+		SHA1:       hashHex(src),
+		Size:       len(src),
+		FileType:   common.PNG,
+		ThumbType:  common.JPEG,
+		ThumbSizes: []uint16{200, 400},
+	}
+	retina := file.Thumb{Type: common.JPEG, Size: 400, Data: []byte("retina")}
+	err = m.from.Write(img.SHA1, img.FileType, img.ThumbType, src, []byte("thumb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.from.WriteThumbs(img.SHA1, []file.Thumb{retina}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = m.copyImage(img); err != nil {
+		t.Fatal(err)
+	}
+	data, err := m.to.ReadThumb(img.SHA1, file.Thumb{Type: common.JPEG, Size: 400})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, data, retina.Data)
+
+	t.Run("broken source", func(t *testing.T) {
+		broken := img
+		broken.Size++
+		if err := m.copyImage(broken); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+func TestCopyBatchFailures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cutechan-migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var stores [2]fileStore
+	for i, name := range [...]string{"from", "to"} {
+		stores[i], err = file.MakeBackend(file.Config{
+			Backend: "fs",
+			Dir:     filepath.Join(dir, name),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := migration{
+		from:       stores[0],
+		to:         stores[1],
+		jobs:       2,
+		checkpoint: filepath.Join(dir, "checkpoint"),
+	}
+
+	imgs := make([]common.ImageCommon, 3)
+	for i := range imgs {
+		src := []byte{'a' + byte(i)}
+		imgs[i] = common.ImageCommon{
+			SHA1:     hashHex(src),
+			Size:     len(src),
+			FileType: common.PNG,
+		}
+		// Source of the second image is missing
+		if i == 1 {
+			continue
+		}
+		err = m.from.Write(imgs[i].SHA1, common.PNG, common.JPEG, src, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	missing, err := m.filterMissing(imgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, len(missing), 3)
+
+	m.copyBatch(imgs)
+	AssertDeepEquals(t, m.images, 2)
+	AssertDeepEquals(t, m.failed, []string{imgs[1].SHA1})
+	missing, err = m.filterMissing(imgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, missing, []common.ImageCommon{imgs[1]})
+
+	if err := m.writeFailures(); err == nil {
+		t.Fatal("expected error")
+	}
+	data, err := ioutil.ReadFile(m.checkpoint + ".failed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, string(data), imgs[1].SHA1+"\n")
+}
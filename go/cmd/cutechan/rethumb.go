@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/db"
+	"github.com/cutechan/cutechan/go/server"
+)
+
+const rethumbDateLayout = "2006-01-02"
+
+// Regenerate thumbnails of files matching command line filters.
+func rethumb(conf config) (err error) {
+	// Concurrency option is shared with migrate-files.
+	jobs := conf.MigrateJobs
+	if jobs < 1 {
+		return fmt.Errorf("bad concurrency: %d", jobs)
+	}
+	filter := db.ImageFilter{Board: conf.RethumbBoard}
+	if conf.RethumbSince != "" {
+		filter.Since, err = time.Parse(rethumbDateLayout, conf.RethumbSince)
+		if err != nil {
+			return fmt.Errorf("bad since date: %s", conf.RethumbSince)
+		}
+	}
+	if conf.RethumbUntil != "" {
+		filter.Until, err = time.Parse(rethumbDateLayout, conf.RethumbUntil)
+		if err != nil {
+			return fmt.Errorf("bad until date: %s", conf.RethumbUntil)
+		}
+	}
+	if conf.RethumbTypes != "" {
+		if filter.FileTypes, err = parseFileTypes(conf.RethumbTypes); err != nil {
+			return
+		}
+	}
+	return server.Rethumb(conf.User, jobs, filter)
+}
+
+// Map comma-separated file extensions to internal file types.
+func parseFileTypes(s string) (types []uint8, err error) {
+	for _, ext := range strings.Split(s, ",") {
+		t, ok := common.FileTypeByExt(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		if !ok {
+			return nil, fmt.Errorf("bad file type: %s", ext)
+		}
+		types = append(types, t)
+	}
+	return
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/cutechan/cutechan/go/common"
+	. "github.com/cutechan/cutechan/go/test"
+)
+
+func TestParseFileTypes(t *testing.T) {
+	types, err := parseFileTypes("jpg, .png,webm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, types, []uint8{common.JPEG, common.PNG, common.WEBM})
+
+	if _, err := parseFileTypes("jpg,exe"); err == nil {
+		t.Fatal("expected error")
+	}
+}
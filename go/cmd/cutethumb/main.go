@@ -3,10 +3,17 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
 
 	"github.com/cutechan/cutechan/go/ipc"
 
@@ -16,12 +23,20 @@ import (
 const (
 	maxWidth        = 10000
 	maxHeight       = 10000
-	thumbSize       = 200
 	jpegQuality     = 90
+	webpQuality     = 80
+	webpCmd         = "cwebp"
 	maxLenFileTitle = 300
+	// Shared secret of HTTP mode, env var is used to not expose it in
+	// process list.
+	secretEnv = "CUTETHUMB_SECRET"
 )
 
 var (
+	// Thumbnail box sizes to generate, base size first. Others are used
+	// on high-DPI screens.
+	thumbSizes = []uint{200, 400}
+
 	allowedMimeTypes = map[string]bool{
 		"image/jpeg": true,
 		"image/png":  true,
@@ -40,21 +55,86 @@ func truncString(s string, max int) string {
 	}
 }
 
-func getThumbnail(srcData []byte) (ithumb *ipc.Thumb, err error) {
-	opts := thumbnailer.Options{
+func getOptions(size uint) thumbnailer.Options {
+	return thumbnailer.Options{
 		MaxSourceDims: thumbnailer.Dims{
 			Width:  maxWidth,
 			Height: maxHeight,
 		},
 		ThumbDims: thumbnailer.Dims{
-			Width:  thumbSize,
-			Height: thumbSize,
+			Width:  size,
+			Height: size,
 		},
 		JPEGQuality:       jpegQuality,
 		AcceptedMimeTypes: allowedMimeTypes,
 	}
+}
+
+func getThumbMime(thumb thumbnailer.Thumbnail) string {
+	if thumb.IsPNG {
+		return "image/png"
+	}
+	return "image/jpeg"
+}
+
+// Encode thumbnail with external WebP encoder.
+func encodeWebP(data []byte) ([]byte, error) {
+	q := strconv.Itoa(webpQuality)
+	cmd := exec.Command(webpCmd, "-quiet", "-q", q, "-o", "-", "--", "-")
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stderr = os.Stderr
+	return cmd.Output()
+}
 
-	src, thumb, err := thumbnailer.ProcessBuffer(srcData, opts)
+// Make bigger thumbnails for high-DPI screens and WebP copies of all
+// renditions. Base thumbnail is already made.
+func getVariants(srcData []byte, base thumbnailer.Thumbnail) (variants []ipc.ThumbVariant) {
+	renditions := []ipc.ThumbVariant{{
+		Mime:   getThumbMime(base),
+		Size:   uint16(thumbSizes[0]),
+		Width:  uint16(base.Width),
+		Height: uint16(base.Height),
+		Data:   base.Data,
+	}}
+	for _, size := range thumbSizes[1:] {
+		prev := renditions[len(renditions)-1]
+		_, thumb, err := thumbnailer.ProcessBuffer(srcData, getOptions(size))
+		if err != nil || thumb.Data == nil {
+			log.Printf("thumbnailer error: %dpx variant: %v", size, err)
+			break
+		}
+		// Source is too small, nothing to gain from bigger sizes.
+		if uint16(thumb.Width) <= prev.Width && uint16(thumb.Height) <= prev.Height {
+			break
+		}
+		renditions = append(renditions, ipc.ThumbVariant{
+			Mime:   getThumbMime(thumb),
+			Size:   uint16(size),
+			Width:  uint16(thumb.Width),
+			Height: uint16(thumb.Height),
+			Data:   thumb.Data,
+		})
+	}
+	variants = append(variants, renditions[1:]...)
+
+	// WebP copies are either available for all renditions or for none.
+	webps := make([]ipc.ThumbVariant, 0, len(renditions))
+	for _, r := range renditions {
+		data, err := encodeWebP(r.Data)
+		if err != nil {
+			log.Printf("thumbnailer error: webp: %v", err)
+			return
+		}
+		r.Mime = "image/webp"
+		r.Data = data
+		webps = append(webps, r)
+	}
+	variants = append(variants, webps...)
+	return
+}
+
+func getThumbnail(srcData []byte) (ithumb *ipc.Thumb, err error) {
+	src, thumb, err := thumbnailer.ProcessBuffer(srcData, getOptions(thumbSizes[0]))
 	switch err {
 	case nil:
 		// Do nothing.
@@ -106,12 +186,51 @@ func getThumbnail(srcData []byte) (ithumb *ipc.Thumb, err error) {
 		Height:    uint16(thumb.Height),
 		Duration:  uint32(src.Length.Seconds() + 0.5),
 		Title:     truncString(src.Title, maxLenFileTitle),
+		Size:      uint16(thumbSizes[0]),
 		Data:      thumb.Data,
 	}
+	if thumb.Data != nil {
+		ithumb.Variants = getVariants(srcData, thumb)
+	}
 	return
 }
 
+// Serve jobs of remote web servers. Number of concurrent jobs is limited
+// to the number of CPUs, the rest wait in queue. Every job is processed
+// by a child process in stdin mode which is killed after the deadline.
+func serveHTTP(address string) {
+	secret := os.Getenv(secretEnv)
+	if secret == "" {
+		log.Fatalf("%s must be set in HTTP mode", secretEnv)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	sem := make(chan struct{}, runtime.NumCPU())
+	handler := ipc.ThumbHandler(secret, func(srcData []byte) (*ipc.Thumb, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), ipc.THUMB_JOB_TIMEOUT)
+		defer cancel()
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ipc.ErrThumbTimeout
+		}
+		defer func() { <-sem }()
+		return ipc.RunThumbnailerContext(ctx, self, srcData)
+	})
+	log.Printf("Listening on %v", address)
+	log.Fatal(http.ListenAndServe(address, handler))
+}
+
 func main() {
+	httpAddr := flag.String("http", "", "serve jobs over HTTP on the address instead of stdin")
+	flag.Parse()
+	if *httpAddr != "" {
+		serveHTTP(*httpAddr)
+		return
+	}
+
 	srcData, err := ioutil.ReadAll(os.Stdin)
 	if err != nil {
 		fmt.Print(err.Error())
@@ -0,0 +1,34 @@
+package common
+
+// AppealStatus is the state of ban appeal in moderator queue
+type AppealStatus uint8
+
+const (
+	AppealPending AppealStatus = iota
+	AppealAccepted
+	AppealRejected
+)
+
+// Appeal is banned user's request to lift the ban
+type Appeal struct {
+	ID       uint64       `json:"id"`
+	Board    string       `json:"board"`
+	IP       string       `json:"ip"`
+	Account  string       `json:"account,omitempty"`
+	Post     uint64       `json:"post"`
+	Reason   string       `json:"reason"`
+	Text     string       `json:"text"`
+	Status   AppealStatus `json:"status"`
+	Response string       `json:"response,omitempty"`
+	Created  int64        `json:"created"`
+}
+
+// BanInfo is active ban of the client as shown on the ban page, along
+// with the appeal, if any
+type BanInfo struct {
+	Board   string  `json:"board"`
+	Post    uint64  `json:"post"`
+	Reason  string  `json:"reason"`
+	Expires int64   `json:"expires"`
+	Appeal  *Appeal `json:"appeal,omitempty"`
+}
@@ -18,6 +18,7 @@ const (
 	SevenZip
 	TGZ
 	TXZ
+	WEBP
 )
 
 // Extensions maps internal file types to their canonical file
@@ -35,26 +36,51 @@ var Extensions = map[uint8]string{
 	SevenZip: "7z",
 	TGZ:      "tar.gz",
 	TXZ:      "tar.xz",
+	WEBP:     "webp",
 }
 
-// Image contains a post's image and thumbnail data.
+// FileTypeByExt maps canonical file extension to internal file type.
+func FileTypeByExt(ext string) (uint8, bool) {
+	for t, e := range Extensions {
+		if e == ext {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// Image contains a post's image and thumbnail data. Spoiler is set per
+// post, same file can be spoilered in one post and not in another.
 type Image struct {
 	ImageCommon
+	Spoiler bool `json:"spoiler,omitempty"`
 }
 
 // ImageCommon contains the common data shared between multiple post
-// referencing the same image.
+// referencing the same image. ThumbSizes lists box sizes of available
+// thumbnail renditions with the base size first and is empty for legacy
+// uploads which only have a single thumbnail. WebP is set if each
+// rendition also has a WebP copy.
+//
+// Restricted files are attached only to posts on mod-only boards and
+// accessible only via signed expiring URLs passed to clients in
+// SignedSource and SignedThumb.
 type ImageCommon struct {
-	SHA1      string
-	Size      int       `json:"size"`
-	Video     bool      `json:"video,omitempty"`
-	Audio     bool      `json:"audio,omitempty"`
-	APNG      bool      `json:"apng,omitempty"`
-	FileType  uint8     `json:"fileType"`
-	ThumbType uint8     `json:"thumbType"`
-	Length    uint32    `json:"length,omitempty"`
-	Title     string    `json:"title,omitempty"`
-	Dims      [4]uint16 `json:"dims"`
-	MD5       string    `json:"-"`
-	Artist    string    `json:"-"`
+	SHA1         string
+	Size         int       `json:"size"`
+	Video        bool      `json:"video,omitempty"`
+	Audio        bool      `json:"audio,omitempty"`
+	APNG         bool      `json:"apng,omitempty"`
+	FileType     uint8     `json:"fileType"`
+	ThumbType    uint8     `json:"thumbType"`
+	Length       uint32    `json:"length,omitempty"`
+	Title        string    `json:"title,omitempty"`
+	Dims         [4]uint16 `json:"dims"`
+	ThumbSizes   []uint16  `json:"thumbSizes,omitempty"`
+	WebP         bool      `json:"webp,omitempty"`
+	Restricted   bool      `json:"-"`
+	SignedSource string    `json:"signedSource,omitempty"`
+	SignedThumb  string    `json:"signedThumb,omitempty"`
+	MD5          string    `json:"-"`
+	Artist       string    `json:"-"`
 }
@@ -0,0 +1,14 @@
+package common
+
+// StaffNote is private moderator note on a post or on hashed IP of the
+// poster
+type StaffNote struct {
+	ID    uint64 `json:"id"`
+	Board string `json:"board"`
+	Post  uint64 `json:"post,omitempty"`
+	// Attached to the poster's IP and kept after IPs are wiped from posts
+	ByIP    bool   `json:"byIP"`
+	Text    string `json:"text"`
+	By      string `json:"by"`
+	Created int64  `json:"created"`
+}
@@ -28,6 +28,7 @@ func (b Board) Less(i, j int) bool {
 type Thread struct {
 	Abbrev    bool   `json:"abbrev,omitempty"`
 	Sticky    bool   `json:"sticky,omitempty"`
+	Locked    bool   `json:"locked,omitempty"`
 	PostCtr   uint32 `json:"postCtr"`
 	ImageCtr  uint32 `json:"imageCtr"`
 	ReplyTime int64  `json:"replyTime"`
@@ -49,6 +50,8 @@ type Post struct {
 	Links    Links    `json:"links,omitempty"`
 	Commands Commands `json:"commands,omitempty"`
 	Files    Files    `json:"files,omitempty"`
+	// Shadow banned, only set for moderators
+	Shadow bool `json:"shadow,omitempty"`
 }
 
 // StandalonePost is a post view that includes the "op" and "board"
@@ -58,6 +61,8 @@ type StandalonePost struct {
 	Post
 	OP    uint64 `json:"op"`
 	Board string `json:"board"`
+	// Awaiting moderator approval, only set for moderators
+	Held bool `json:"held,omitempty"`
 }
 
 // Posts.
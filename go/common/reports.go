@@ -0,0 +1,43 @@
+package common
+
+// ReportReason is the category of a user report on a post
+type ReportReason uint8
+
+const (
+	ReportSpam ReportReason = iota
+	ReportIllegal
+	ReportOfftopic
+	ReportAbuse
+	ReportOther
+	numReportReasons
+)
+
+// IsValid checks if reason is one of the known categories
+func (r ReportReason) IsValid() bool {
+	return r < numReportReasons
+}
+
+// ReportResolution is the moderator action taken on reported post
+type ReportResolution uint8
+
+const (
+	ReportDismiss ReportResolution = iota
+	ReportDelete
+	ReportBan
+)
+
+// Report is a single user report on a post
+type Report struct {
+	Reason  ReportReason `json:"reason"`
+	Text    string       `json:"text,omitempty"`
+	Created int64        `json:"created"`
+}
+
+// ReportedPost is a post in the moderator report queue with all its
+// pending reports
+type ReportedPost struct {
+	ID      uint64   `json:"id"`
+	OP      uint64   `json:"op"`
+	Body    string   `json:"body"`
+	Reports []Report `json:"reports"`
+}
@@ -2,19 +2,28 @@ package common
 
 // Maximum lengths of various input fields
 const (
-	MaxLenName         = 50
-	MaxLenAuth         = 50
-	MaxLenSubject      = 100
-	MaxLenBody         = 4000
-	MaxLinesBody       = 300
-	MaxLenPassword     = 50
-	MaxLenUserID       = 20
-	MaxLenBoardID      = 10
-	MaxLenBoardTitle   = 100
-	MaxBanReasonLength = 100
-	MaxLenIgnoreList   = 100
-	MaxLenStaffList    = 1000
-	MaxLenBansList     = 1000
+	MaxLenName           = 50
+	MaxLenAuth           = 50
+	MaxLenSubject        = 100
+	MaxLenBody           = 4000
+	MaxLinesBody         = 300
+	MaxLenPassword       = 50
+	MaxLenUserID         = 20
+	MaxLenBoardID        = 10
+	MaxLenBoardTitle     = 100
+	MaxBanReasonLength   = 100
+	MaxLenNotification   = 500
+	MaxLenIgnoreList     = 100
+	MaxLenStaffList      = 1000
+	MaxLenBansList       = 1000
+	MaxLenSplitPosts     = 1000
+	MaxLenReportText     = 200
+	MaxLenAppealText     = 1000
+	MaxLenAppealReply    = 200
+	MaxLenStaffNote      = 500
+	MaxLenAutomodRules   = 100
+	MaxLenAutomodPattern = 1000
+	MaxLenAutomodMessage = 200
 )
 
 // Various cryptographic token exact lengths
@@ -33,6 +42,8 @@ const (
 	ThreadsPerPage       = 20
 	NumPostsAtIndex      = 3
 	NumPostsOnRequest    = 100
+	MaxPurgeWindow       = 7 * 24 * 60 // Minutes, poster IPs are kept as long
+	MaxHoldPosts         = 100
 )
 
 // Available themes. Change this, when adding any new ones.
@@ -24,6 +24,9 @@ const (
 	MessageDeletePost
 	MessageBanned
 	MessageDeleteImage
+	MessageLockThread
+	MessageSplitThread
+	MessageMoveThread
 )
 
 // >= 30 are miscellaneous and do not write to post models
@@ -75,11 +78,14 @@ var (
 	// Propagate a message about a post being deleted
 	DeletePost func(id, op uint64) error
 
-	// Propagate a message about an image being deleted from a post
-	DeleteImage func(id, op uint64) error
+	// Propagate a message about n-th file being deleted from a post
+	DeleteImage func(id, op uint64, n int) error
 
-	// Propagate a message about an image being spoilered
-	SpoilerImage func(id, op uint64) error
+	// Propagate a message about n-th file of the post being spoilered
+	SpoilerImage func(id, op uint64, n int) error
+
+	// Propagate a message about a thread being locked or unlocked
+	LockThread func(id uint64, locked bool) error
 )
 
 // Client exposes some globally accessible websocket client functionality
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"github.com/cutechan/cutechan/go/common"
+)
+
+// UploadPolicy describes files which can be attached to posts.
+type UploadPolicy struct {
+	// In bytes.
+	MaxSize  int64
+	MaxFiles int
+	TextOnly bool
+	// Allowed file types, any if nil.
+	FileTypes map[uint8]bool
+}
+
+// IsAllowed reports whether file of the specified type can be attached.
+func (p UploadPolicy) IsAllowed(fileType uint8) bool {
+	return p.FileTypes == nil || p.FileTypes[fileType]
+}
+
+// ServerUploadPolicy returns server-wide upload limits.
+func ServerUploadPolicy() UploadPolicy {
+	conf := Get()
+	return UploadPolicy{
+		MaxSize:  conf.MaxSize * 1024 * 1024,
+		MaxFiles: conf.MaxFiles,
+	}
+}
+
+// GetUploadPolicy returns upload limits of the board. Board can only
+// tighten server-wide limits.
+func GetUploadPolicy(board string) UploadPolicy {
+	p := ServerUploadPolicy()
+	conf := GetBoardConfig(board)
+	if conf.MaxSize > 0 && conf.MaxSize*1024*1024 < p.MaxSize {
+		p.MaxSize = conf.MaxSize * 1024 * 1024
+	}
+	if conf.MaxFiles > 0 && conf.MaxFiles < p.MaxFiles {
+		p.MaxFiles = conf.MaxFiles
+	}
+	if len(conf.FileTypes) != 0 {
+		p.FileTypes = make(map[uint8]bool, len(conf.FileTypes))
+		for _, ext := range conf.FileTypes {
+			if t, ok := common.FileTypeByExt(ext); ok {
+				p.FileTypes[t] = true
+			}
+		}
+	}
+	p.TextOnly = conf.TextOnly
+	return p
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/cutechan/cutechan/go/common"
+	. "github.com/cutechan/cutechan/go/test"
+)
+
+func TestGetUploadPolicy(t *testing.T) {
+	defer Set(ServerConfig{})
+	Set(ServerConfig{
+		ServerPublic: ServerPublic{MaxSize: 10, MaxFiles: 4},
+	})
+	boards := [...]BoardPublic{
+		{ID: "a"},
+		{ID: "b", MaxSize: 2, MaxFiles: 1, FileTypes: []string{"png", "jpg", "bad"}},
+		{ID: "c", MaxSize: 20, MaxFiles: 8},
+		{ID: "d", TextOnly: true},
+	}
+	for _, b := range boards {
+		if err := SetBoardConfig(BoardConfig{BoardPublic: b}); err != nil {
+			t.Fatal(err)
+		}
+		defer RemoveBoard(b.ID)
+	}
+
+	cases := [...]struct {
+		board string
+		std   UploadPolicy
+	}{
+		{"a", UploadPolicy{MaxSize: 10 << 20, MaxFiles: 4}},
+		{"b", UploadPolicy{
+			MaxSize:  2 << 20,
+			MaxFiles: 1,
+			FileTypes: map[uint8]bool{
+				common.PNG:  true,
+				common.JPEG: true,
+			},
+		}},
+		// Boards can't raise server-wide limits.
+		{"c", UploadPolicy{MaxSize: 10 << 20, MaxFiles: 4}},
+		{"d", UploadPolicy{MaxSize: 10 << 20, MaxFiles: 4, TextOnly: true}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.board, func(t *testing.T) {
+			AssertDeepEquals(t, GetUploadPolicy(c.board), c.std)
+		})
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		p := GetUploadPolicy("b")
+		if !p.IsAllowed(common.PNG) || p.IsAllowed(common.GIF) {
+			t.Fatal("unexpected file types allowed")
+		}
+		if !GetUploadPolicy("a").IsAllowed(common.GIF) {
+			t.Fatal("any type should be allowed by default")
+		}
+	})
+}
@@ -2,8 +2,13 @@
 
 package config
 
+//easyjson:json
 type ServerConfig struct {
 	ServerPublic
+	// Space-separated domains to allow and deny fetching uploads from.
+	// Subdomains match too. Any domain is allowed if allowlist is empty.
+	UploadURLAllow string `json:"uploadURLAllow,omitempty"`
+	UploadURLDeny  string `json:"uploadURLDeny,omitempty"`
 }
 
 //easyjson:json
@@ -31,6 +36,9 @@ type BoardConfig struct {
 	ModOnly     bool       `json:"modOnly,omitempty"`
 	AccessMode  AccessMode `json:"accessMode,omitempty"`
 	IncludeAnon bool       `json:"includeAnon,omitempty"`
+	// First posts of new posters are held until moderator approves
+	// them, zero disables.
+	HoldPosts int `json:"holdPosts,omitempty"`
 	// Pregenerated public JSON.
 	json []byte
 }
@@ -40,6 +48,13 @@ type BoardPublic struct {
 	ID       string `json:"id"`
 	Title    string `json:"title"`
 	ReadOnly bool   `json:"readOnly,omitempty"`
+	// Upload policy overriding server-wide limits, zero values mean
+	// server default. MaxSize is in megabytes, FileTypes lists allowed
+	// file extensions.
+	MaxSize   int64    `json:"maxSize,omitempty"`
+	MaxFiles  int      `json:"maxFiles,omitempty"`
+	FileTypes []string `json:"fileTypes,omitempty"`
+	TextOnly  bool     `json:"textOnly,omitempty"`
 }
 
 // Implements sort.Interface
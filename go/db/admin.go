@@ -2,9 +2,12 @@ package db
 
 import (
 	"database/sql"
+	"net"
+	"sort"
 	"time"
 
 	"github.com/cutechan/cutechan/go/auth"
+	"github.com/cutechan/cutechan/go/automod"
 	"github.com/cutechan/cutechan/go/common"
 	"github.com/cutechan/cutechan/go/config"
 
@@ -19,13 +22,28 @@ func GetIP(id uint64) (string, error) {
 	return ip.String, err
 }
 
-// Ban IPs from accessing a specific board. Need to target posts. Returns all
-// banned IPs.
-func Ban(board, reason, by string, expires time.Time, ids ...uint64) (
+// GetPostAccount returns an account ID the post was signed with. Empty
+// for anonymous posts.
+func GetPostAccount(id uint64) (string, error) {
+	var account sql.NullString
+	err := prepared["get_post_account"].QueryRow(id).Scan(&account)
+	return account.String, err
+}
+
+// Ban IPs from accessing a specific board. Need to target posts. Accounts
+// of signed posts are banned as well. Returns all banned IPs. Shadow
+// banned posts are left without ban message.
+func Ban(
+	board, reason, by string,
+	expires time.Time,
+	typ auth.BanType,
+	ids ...uint64,
+) (
 	ips map[string]uint64, err error,
 ) {
 	type post struct {
-		id, op uint64
+		id, op      uint64
+		ip, account string
 	}
 
 	// Retrieve matching posts
@@ -41,24 +59,31 @@ func Ban(board, reason, by string, expires time.Time, ids ...uint64) (
 			return nil, err
 		}
 		ips[ip] = id
-		posts = append(posts, post{id: id})
+		posts = append(posts, post{id: id, ip: ip})
 	}
 
 	if len(ips) == 0 {
 		return
 	}
 
-	// Retrieve their OPs
+	// Retrieve their OPs and authors
 	for i, post := range posts {
 		post.op, err = GetPostOP(post.id)
 		if err != nil {
 			return
 		}
+		post.account, err = GetPostAccount(post.id)
+		if err != nil {
+			return
+		}
 		posts[i] = post
 	}
 
 	// Write ban messages to posts
 	for _, post := range posts {
+		if typ == auth.ShadowBan {
+			break
+		}
 		err = execPrepared("ban_post", post.id)
 		if err != nil {
 			return
@@ -71,9 +96,27 @@ func Ban(board, reason, by string, expires time.Time, ids ...uint64) (
 		}
 	}
 
-	// Write bans to the ban table
-	for ip, id := range ips {
-		err = execPrepared("write_ban", board, ip, id, by, expires, reason)
+	// Write bans to the ban table. IPv6 posters are banned by their whole
+	// subnet. Posts older than 7 days have no IP and can only be banned
+	// by account.
+	for _, post := range posts {
+		var ipRange string
+		if n := auth.DefaultBanRange(net.ParseIP(post.ip)); n != nil {
+			ipRange = auth.FormatBanRange(n)
+		}
+		if ipRange == "" && post.account == "" {
+			continue
+		}
+		if post.account != "" {
+			err = execPrepared(
+				"detach_account_ban", board, post.account, ipRange)
+			if err != nil {
+				return
+			}
+		}
+		err = execPrepared(
+			"write_ban", board, ipRange, post.id, by, expires, reason, typ,
+			post.account)
 		if err != nil {
 			return
 		}
@@ -88,6 +131,39 @@ func Unban(board string, id uint64, by string) error {
 	return execPrepared("unban", board, id, by)
 }
 
+// BanRange bans IP range on a specific board, updating existing ban of
+// the same range.
+func BanRange(
+	board, ipRange, reason, by string,
+	expires time.Time,
+	typ auth.BanType,
+) error {
+	return execPrepared(
+		"write_range_ban", board, ipRange, by, expires, reason, typ)
+}
+
+// BanAccount bans account on a specific board regardless of IP,
+// updating existing ban of the same account.
+func BanAccount(
+	board, account, reason, by string,
+	expires time.Time,
+	typ auth.BanType,
+) error {
+	return execPrepared(
+		"write_account_ban", board, account, by, expires, reason, typ)
+}
+
+// UnbanRange lifts a range ban on a specific board.
+func UnbanRange(board, ipRange, by string) error {
+	return execAffected("unban_range", board, ipRange, by)
+}
+
+// UnbanAccount lifts account ban on a specific board. Returns
+// sql.ErrNoRows if the account is not banned.
+func UnbanAccount(board, account, by string) error {
+	return execAffected("unban_account", board, account, by)
+}
+
 func loadBans() error {
 	if err := RefreshBanCache(); err != nil {
 		return err
@@ -100,7 +176,7 @@ func loadBans() error {
 // RefreshBanCache loads up to date bans from the database and caches them in
 // memory
 func RefreshBanCache() (err error) {
-	r, err := db.Query(`SELECT ip, board FROM bans`)
+	r, err := prepared["load_bans"].Query()
 	if err != nil {
 		return
 	}
@@ -108,11 +184,16 @@ func RefreshBanCache() (err error) {
 
 	bans := make([]auth.Ban, 0, 16)
 	for r.Next() {
-		var b auth.Ban
-		err = r.Scan(&b.IP, &b.Board)
+		var (
+			b           auth.Ban
+			ip, account sql.NullString
+		)
+		err = r.Scan(&ip, &account, &b.Board, &b.Type)
 		if err != nil {
 			return
 		}
+		b.IP = ip.String
+		b.Account = account.String
 		bans = append(bans, b)
 	}
 	err = r.Err()
@@ -140,7 +221,7 @@ func moderatePost(
 		query = "delete_thread"
 	}
 
-	err = execPrepared(query, id, by)
+	err = deletePost(id, by, query)
 	if err != nil {
 		return
 	}
@@ -149,10 +230,80 @@ func moderatePost(
 	return
 }
 
+func deletePost(id uint64, by, query string) (err error) {
+	tx, err := BeginTx()
+	if err != nil {
+		return
+	}
+	defer EndTx(tx, &err)
+
+	hashes, err := getPostFiles(tx, []uint64{id})
+	if err != nil {
+		return
+	}
+	if err = execPreparedTx(tx, query, id, by); err != nil {
+		return
+	}
+	return updateRestricted(tx, hashes)
+}
+
 func DeletePost(id uint64, by string) error {
 	return moderatePost(id, by, "delete_post", common.DeletePost)
 }
 
+// SpoilerImage spoilers n-th file of the post. Returns sql.ErrNoRows if
+// the post has no such file.
+func SpoilerImage(id uint64, n int, by string) error {
+	return moderateFile(id, n, by, "spoiler_image", common.SpoilerImage)
+}
+
+// DeleteImage removes n-th file from the post keeping the post itself.
+// Returns sql.ErrNoRows if the post has no such file.
+func DeleteImage(id uint64, n int, by string) error {
+	return moderateFile(id, n, by, "delete_image", common.DeleteImage)
+}
+
+func moderateFile(
+	id uint64,
+	n int,
+	by, query string,
+	propagate func(id, op uint64, n int) error,
+) (
+	err error,
+) {
+	op, err := GetPostOP(id)
+	if err != nil {
+		return
+	}
+	if err = moderateFileTx(id, n, by, query); err != nil {
+		return
+	}
+	return propagate(id, op, n)
+}
+
+func moderateFileTx(id uint64, n int, by, query string) (err error) {
+	tx, err := BeginTx()
+	if err != nil {
+		return
+	}
+	defer EndTx(tx, &err)
+
+	hashes, err := getPostFiles(tx, []uint64{id})
+	if err != nil {
+		return
+	}
+	res, err := getStatement(tx, query).Exec(id, n, by)
+	if err != nil {
+		return
+	}
+	if cnt, err := res.RowsAffected(); err != nil {
+		return err
+	} else if cnt == 0 {
+		return sql.ErrNoRows
+	}
+	return updateRestricted(tx, hashes)
+}
+
 // GetSameIPPosts returns posts with the same IP and on the same board as the
 // target post
 func GetSameIPPosts(id uint64, board string) (
@@ -196,9 +347,327 @@ func GetSameIPPosts(id uint64, board string) (
 	return
 }
 
-// Set the sticky field on a thread
-func SetThreadSticky(id uint64, sticky bool) error {
-	return execPrepared("set_sticky", id, sticky)
+// PurgeRequest describes bulk moderation of all posts made from the IP
+// of the target post.
+type PurgeRequest struct {
+	ID uint64
+	// Board to purge or "all" for all boards
+	Board string
+	// Only posts created after that time are affected
+	Since time.Time
+	// Delete posts and/or ban the IP
+	Delete, Ban bool
+	Reason, By  string
+	Expires     time.Time
+}
+
+// PurgeSummary lists posts affected by the purge.
+type PurgeSummary struct {
+	Posts   []uint64 `json:"posts"`
+	Threads []uint64 `json:"threads"`
+	Boards  []string `json:"boards"`
+	Deleted bool     `json:"deleted"`
+	Banned  bool     `json:"banned"`
+}
+
+// PurgePosts deletes and/or bans all posts from the IP of the target
+// post within the time window. Changes are made in a single transaction
+// and logged as one moderation entry. Returns sql.ErrNoRows if there is
+// nothing to purge.
+func PurgePosts(req PurgeRequest) (s PurgeSummary, err error) {
+	posts, s, err := purgePosts(req)
+	if err != nil {
+		return
+	}
+
+	// Propagate to live clients only after commit
+	for _, p := range posts {
+		if s.Deleted {
+			err = common.DeletePost(p.id, p.op)
+		} else {
+			err = common.BanPost(p.id, p.op)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+type purgedPost struct {
+	id, op uint64
+}
+
+func purgePosts(req PurgeRequest) (
+	posts []purgedPost, s PurgeSummary, err error,
+) {
+	tx, err := BeginTx()
+	if err != nil {
+		return
+	}
+	defer EndTx(tx, &err)
+
+	// IPs of old posts are already cleared
+	var ip sql.NullString
+	err = tx.Stmt(prepared["get_ip"]).QueryRow(req.ID).Scan(&ip)
+	if err != nil {
+		return
+	}
+	if !ip.Valid {
+		err = sql.ErrNoRows
+		return
+	}
+	r, err := tx.Stmt(prepared["get_purge_posts"]).
+		Query(req.ID, req.Board, req.Since.Unix())
+	if err != nil {
+		return
+	}
+	defer r.Close()
+	boards := make(map[string]bool)
+	for r.Next() {
+		var p purgedPost
+		var board string
+		if err = r.Scan(&p.id, &p.op, &board); err != nil {
+			return
+		}
+		posts = append(posts, p)
+		boards[board] = true
+	}
+	if err = r.Err(); err != nil {
+		return
+	}
+	if len(posts) == 0 {
+		err = sql.ErrNoRows
+		return
+	}
+
+	s.Posts = make([]uint64, 0, len(posts))
+	s.Threads = make([]uint64, 0)
+	for _, p := range posts {
+		s.Posts = append(s.Posts, p.id)
+		if p.id == p.op {
+			s.Threads = append(s.Threads, p.id)
+		}
+	}
+	s.Boards = make([]string, 0, len(boards))
+	for b := range boards {
+		s.Boards = append(s.Boards, b)
+	}
+	sort.Strings(s.Boards)
+
+	if req.Ban {
+		n := auth.DefaultBanRange(net.ParseIP(ip.String))
+		if n == nil {
+			err = sql.ErrNoRows
+			return
+		}
+		err = execPreparedTx(tx, "purge_ban",
+			req.Board, auth.FormatBanRange(n), req.ID, req.By, req.Expires,
+			req.Reason)
+		if err != nil {
+			return
+		}
+		s.Banned = true
+	}
+
+	if req.Delete {
+		var hashes []string
+		hashes, err = getPostFiles(tx, s.Posts)
+		if err != nil {
+			return
+		}
+		// Threads take their posts with them
+		deleted := make(map[uint64]bool, len(s.Threads))
+		for _, id := range s.Threads {
+			if err = execPreparedTx(tx, "purge_thread", id); err != nil {
+				return
+			}
+			deleted[id] = true
+		}
+		for _, p := range posts {
+			if deleted[p.op] {
+				continue
+			}
+			if err = execPreparedTx(tx, "purge_post", p.id); err != nil {
+				return
+			}
+		}
+		if err = updateRestricted(tx, hashes); err != nil {
+			return
+		}
+		s.Deleted = true
+	} else if req.Ban {
+		for _, p := range posts {
+			if err = execPreparedTx(tx, "ban_post", p.id); err != nil {
+				return
+			}
+		}
+	}
+
+	err = execPreparedTx(tx, "log_purge", req.Board, req.ID, req.By)
+	return
+}
+
+// Set the sticky field on a thread. Returns sql.ErrNoRows if there is no
+// such thread.
+func SetThreadSticky(id uint64, sticky bool, by string) error {
+	return execAffected("set_sticky", id, sticky, by)
+}
+
+// Set the locked field on a thread and notify live clients. Returns
+// sql.ErrNoRows if there is no such thread.
+func SetThreadLocked(id uint64, locked bool, by string) error {
+	if err := execAffected("set_locked", id, locked, by); err != nil {
+		return err
+	}
+	return common.LockThread(id, locked)
+}
+
+// Move thread with all its posts to another board. Returns sql.ErrNoRows
+// if there is no such thread on the source board.
+func MoveThread(id uint64, from, to, by string) (err error) {
+	tx, err := BeginTx()
+	if err != nil {
+		return
+	}
+	defer EndTx(tx, &err)
+
+	res, err := tx.Stmt(prepared["move_thread"]).Exec(id, from, to, by)
+	if err != nil {
+		return
+	}
+	n, err := res.RowsAffected()
+	switch {
+	case err != nil:
+		return
+	case n == 0:
+		return sql.ErrNoRows
+	}
+	// Post links only store IDs and are resolved through /all/ so they
+	// don't need to be rewritten.
+	if err = execPreparedTx(tx, "move_thread_posts", id, to); err != nil {
+		return
+	}
+	// Files can become restricted or public with the board.
+	hashes, err := getPostFiles(tx, []uint64{id})
+	if err != nil {
+		return
+	}
+	return updateRestricted(tx, hashes)
+}
+
+// Split posts off the thread into a new thread on the specified board.
+// Post with the lowest ID becomes OP of the new thread. Returns
+// sql.ErrNoRows if some of the posts don't belong to the thread.
+func SplitThread(
+	op uint64,
+	ids []uint64,
+	board, subject, by string,
+) (newOP uint64, err error) {
+	if len(ids) == 0 {
+		err = sql.ErrNoRows
+		return
+	}
+	ids = append([]uint64(nil), ids...)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	newOP = ids[0]
+	if newOP == op {
+		err = sql.ErrNoRows
+		return
+	}
+
+	tx, err := BeginTx()
+	if err != nil {
+		return
+	}
+	defer EndTx(tx, &err)
+
+	err = execPreparedTx(tx, "split_thread", newOP, board, subject, by)
+	if err != nil {
+		return
+	}
+	res, err := tx.Stmt(prepared["split_thread_posts"]).
+		Exec(newOP, board, op, pq.Array(ids))
+	if err != nil {
+		return
+	}
+	n, err := res.RowsAffected()
+	switch {
+	case err != nil:
+		return
+	case n != int64(len(ids)):
+		err = sql.ErrNoRows
+		return
+	}
+	for _, id := range [...]uint64{op, newOP} {
+		if err = execPreparedTx(tx, "recount_thread", id); err != nil {
+			return
+		}
+	}
+	if err = relinkSplitPosts(tx, op, newOP, ids); err != nil {
+		return
+	}
+	// Files can become restricted or public with the board.
+	hashes, err := getPostFiles(tx, ids)
+	if err != nil {
+		return
+	}
+	err = updateRestricted(tx, hashes)
+	return
+}
+
+// Links store OP of the target post, so links to the moved posts from
+// any thread must point to the new thread. IDs are sorted.
+func relinkSplitPosts(tx *sql.Tx, op, newOP uint64, ids []uint64) error {
+	moved := make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		moved[id] = true
+	}
+
+	type row struct {
+		id    uint64
+		links linkRow
+	}
+	var changed []row
+	// Overlap also matches link OPs, these are filtered out below.
+	r, err := tx.Stmt(prepared["get_linking_posts"]).
+		Query(pq.Array(ids), ids[0])
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for r.Next() {
+		var p row
+		if err := r.Scan(&p.id, &p.links); err != nil {
+			return err
+		}
+		dirty := false
+		for i, l := range p.links {
+			if moved[l[0]] && l[1] == op {
+				p.links[i][1] = newOP
+				dirty = true
+			}
+		}
+		if dirty {
+			changed = append(changed, p)
+		}
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	r.Close()
+
+	for _, p := range changed {
+		if err := execPreparedTx(tx, "set_post_links", p.id, p.links); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogNotification records global notification sent by admin.
+func LogNotification(by string) error {
+	return execPrepared("log_notification", by)
 }
 
 // GetOwnedBoards returns boards the account holder owns
@@ -273,10 +742,16 @@ func GetBans(tx *sql.Tx, boards []string) (bans auth.BanRecords, err error) {
 	for rs.Next() {
 		var rec auth.BanRecord
 		var expires time.Time
-		err = rs.Scan(&rec.Board, &rec.IP, &rec.ID, &rec.By, &expires, &rec.Reason)
+		var ip, account sql.NullString
+		err = rs.Scan(
+			&rec.Board, &ip, &account, &rec.Type, &rec.ID, &rec.By,
+			&expires, &rec.Reason,
+		)
 		if err != nil {
 			return
 		}
+		rec.IP = ip.String
+		rec.Account = account.String
 		rec.Expires = expires.Unix()
 		bans = append(bans, rec)
 	}
@@ -284,12 +759,19 @@ func GetBans(tx *sql.Tx, boards []string) (bans auth.BanRecords, err error) {
 	return
 }
 
-// GetBanInfo retrieves information about a specific ban
-func GetBanInfo(ip, board string) (b auth.BanRecord, err error) {
-	var expires time.Time
+// GetBanInfo retrieves information about a ban matching the IP or
+// account on a specific board
+func GetBanInfo(ip, account, board string) (b auth.BanRecord, err error) {
+	var (
+		banIP, banAccount sql.NullString
+		expires           time.Time
+	)
 	err = prepared["get_ban_info"].
-		QueryRow(ip, board).
-		Scan(&b.Board, &b.IP, &b.ID, &b.Reason, &b.By, &expires)
+		QueryRow(ip, board, account).
+		Scan(&b.Board, &banIP, &banAccount, &b.ID, &b.Reason, &b.By,
+			&expires)
+	b.IP = banIP.String
+	b.Account = banAccount.String
 	b.Expires = expires.Unix()
 	return
 }
@@ -302,7 +784,9 @@ func WriteBans(tx *sql.Tx, board string, bans auth.BanRecords) (err error) {
 	st := getStatement(tx, "write_ban")
 	for _, rec := range bans {
 		expires := time.Unix(rec.Expires, 0)
-		_, err = st.Exec(board, rec.IP, rec.ID, rec.By, expires, rec.Reason)
+		_, err = st.Exec(
+			board, rec.IP, rec.ID, rec.By, expires, rec.Reason, rec.Type,
+			rec.Account)
 		if err != nil {
 			return
 		}
@@ -334,11 +818,17 @@ func GetModLog(boards []string) (log auth.ModLogRecords, err error) {
 	return
 }
 
-// DeleteBoard deletes a board and all of its contained threads and
-// posts.
-func DeleteBoard(board string) error {
-	_, err := prepared["delete_board"].Exec(board)
-	return err
+// DeleteBoard hides a board from everyone. Board with all its threads and
+// posts is removed permanently after grace period, until then it can be
+// restored with RestoreBoard.
+func DeleteBoard(board, by string) error {
+	return execAffected("delete_board", board, by)
+}
+
+// RestoreBoard brings back a deleted board. Only admin and owners of the
+// board can restore it, sql.ErrNoRows is returned otherwise.
+func RestoreBoard(board, by string) error {
+	return execAffected("restore_board", board, by)
 }
 
 // Operate on multiple tables simultaneously.
@@ -348,6 +838,7 @@ type BoardState struct {
 	Settings config.BoardConfig `json:"settings"`
 	Staff    auth.Staff         `json:"staff"`
 	Bans     auth.BanRecords    `json:"bans"`
+	Rules    automod.Rules      `json:"rules"`
 }
 
 func GetBoardState(tx *sql.Tx, board string) (state BoardState, err error) {
@@ -363,7 +854,11 @@ func GetBoardState(tx *sql.Tx, board string) (state BoardState, err error) {
 	if err != nil {
 		return
 	}
-	state = BoardState{conf, staff, bans}
+	rules, err := GetAutomodRules(tx, []string{board})
+	if err != nil {
+		return
+	}
+	state = BoardState{conf, staff, bans, rules}
 	return
 }
 
@@ -377,5 +872,8 @@ func SetBoardState(tx *sql.Tx, state BoardState, by string) (err error) {
 	if err = WriteBans(tx, state.Settings.ID, state.Bans); err != nil {
 		return
 	}
+	if err = WriteAutomodRules(tx, state.Settings.ID, state.Rules); err != nil {
+		return
+	}
 	return
 }
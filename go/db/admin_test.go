@@ -0,0 +1,284 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/config"
+	. "github.com/cutechan/cutechan/go/test"
+)
+
+const (
+	sampleSHA1  = "0000000000000000000000000000000000000001"
+	sampleSHA1b = "0000000000000000000000000000000000000002"
+)
+
+func TestDeleteImage(t *testing.T) {
+	assertTableClear(t, "boards", "images")
+	writeSampleBoard(t)
+	writeSampleFiles(t, sampleSHA1, sampleSHA1b)
+	writeSamplePost(t, samplePost(1, 1))
+	writeSamplePost(t, samplePost(2, 1, sampleSHA1, sampleSHA1b))
+	assertThreadCounters(t, 1, 2, 2)
+
+	if err := DeleteImage(2, 0, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	post, err := GetPost(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, len(post.Files), 1)
+	AssertDeepEquals(t, post.Files[0].SHA1, sampleSHA1b)
+	assertThreadCounters(t, 1, 2, 1)
+
+	t.Run("no such file", func(t *testing.T) {
+		if err := DeleteImage(2, 1, "admin"); err != sql.ErrNoRows {
+			UnexpectedError(t, err)
+		}
+		assertThreadCounters(t, 1, 2, 1)
+	})
+}
+
+func TestSetThreadSticky(t *testing.T) {
+	assertTableClear(t, "boards")
+	writeSampleBoard(t)
+	writeSampleThread(t)
+
+	if err := SetThreadSticky(1, true, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	var sticky bool
+	err := db.QueryRow(`SELECT sticky FROM threads WHERE id = 1`).Scan(&sticky)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, sticky, true)
+
+	if err := SetThreadSticky(2, true, "admin"); err != sql.ErrNoRows {
+		UnexpectedError(t, err)
+	}
+}
+
+func TestDeleteRestoreBoard(t *testing.T) {
+	assertTableClear(t, "boards")
+	writeSampleBoard(t)
+
+	if err := DeleteBoard("a", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteBoard("a", "admin"); err != sql.ErrNoRows {
+		UnexpectedError(t, err)
+	}
+
+	// Only admin and board owners can restore.
+	if err := RestoreBoard("a", "user"); err != sql.ErrNoRows {
+		UnexpectedError(t, err)
+	}
+	if err := RestoreBoard("a", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RestoreBoard("a", "admin"); err != sql.ErrNoRows {
+		UnexpectedError(t, err)
+	}
+}
+
+func writeModOnlyBoard(t *testing.T, id string) {
+	b := config.BoardConfig{
+		BoardPublic: config.BoardPublic{ID: id},
+		ModOnly:     true,
+	}
+	if err := WriteBoard(nil, b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertRestricted(t *testing.T, sha1 string, std bool) {
+	var restricted bool
+	err := db.QueryRow(`SELECT restricted FROM images WHERE sha1 = $1`, sha1).
+		Scan(&restricted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restricted != std {
+		t.Fatalf("expected restricted=%v for %s", std, sha1)
+	}
+}
+
+func TestMoveThread(t *testing.T) {
+	assertTableClear(t, "boards", "images")
+	writeSampleBoard(t)
+	writeModOnlyBoard(t, "m")
+	writeSampleFiles(t, sampleSHA1, sampleSHA1b)
+	writeSamplePost(t, samplePost(1, 1, sampleSHA1))
+	writeSamplePost(t, samplePost(2, 1, sampleSHA1b))
+	assertRestricted(t, sampleSHA1, false)
+
+	if err := MoveThread(1, "a", "m", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	board, err := GetPostBoard(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, board, "m")
+	assertRestricted(t, sampleSHA1, true)
+	assertRestricted(t, sampleSHA1b, true)
+
+	if err := MoveThread(1, "a", "m", "admin"); err != sql.ErrNoRows {
+		UnexpectedError(t, err)
+	}
+	if err := MoveThread(1, "m", "a", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	assertRestricted(t, sampleSHA1, false)
+}
+
+func TestSplitThread(t *testing.T) {
+	assertTableClear(t, "boards", "images")
+	writeSampleBoard(t)
+	writeModOnlyBoard(t, "m")
+	writeSampleFiles(t, sampleSHA1)
+	writeSamplePost(t, samplePost(1, 1))
+	writeSamplePost(t, samplePost(2, 1))
+	writeSamplePost(t, samplePost(3, 1, sampleSHA1))
+	writeSamplePost(t, samplePost(4, 4))
+	// Links to the split post from the same and another thread
+	for _, id := range [...]uint64{5, 6} {
+		p := samplePost(id, 1)
+		if id == 6 {
+			p.OP = 4
+		}
+		p.Links = common.Links{{2, 1}, {3, 1}}
+		writeSamplePost(t, p)
+	}
+
+	newOP, err := SplitThread(1, []uint64{3}, "m", "split", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, newOP, uint64(3))
+	assertThreadCounters(t, 1, 3, 0)
+	assertThreadCounters(t, 3, 1, 1)
+	assertRestricted(t, sampleSHA1, true)
+	for _, id := range [...]uint64{5, 6} {
+		p, err := GetPost(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		AssertDeepEquals(t, p.Links, common.Links{{2, 1}, {3, 3}})
+	}
+
+	t.Run("foreign posts", func(t *testing.T) {
+		_, err := SplitThread(1, []uint64{2, 6}, "a", "", "admin")
+		if err != sql.ErrNoRows {
+			UnexpectedError(t, err)
+		}
+	})
+}
+
+func TestPurgePosts(t *testing.T) {
+	assertTableClear(t, "boards", "bans")
+	writeSampleBoard(t)
+	writeModOnlyBoard(t, "b")
+	const ip = "10.0.0.1"
+	// Posts 2, 3 and 5 are made from the purged IP.
+	for _, p := range [...]struct {
+		id, op uint64
+		board  string
+		ip     string
+	}{
+		{1, 1, "a", "10.0.0.2"},
+		{2, 1, "a", ip},
+		{3, 3, "a", ip},
+		{4, 3, "a", "10.0.0.2"},
+		{5, 5, "b", ip},
+	} {
+		post := samplePost(p.id, p.op)
+		post.Board = p.board
+		post.IP = p.ip
+		writeSamplePost(t, post)
+	}
+
+	var propagated []uint64
+	defer func() {
+		common.BanPost = func(id, op uint64) error { return nil }
+		common.DeletePost = func(id, op uint64) error { return nil }
+	}()
+	record := func(id, op uint64) error {
+		propagated = append(propagated, id)
+		return nil
+	}
+	common.BanPost = record
+	common.DeletePost = record
+	req := PurgeRequest{
+		ID:      2,
+		Board:   "a",
+		Since:   time.Now().Add(-time.Hour),
+		Reason:  "spam",
+		By:      "admin",
+		Expires: time.Now().Add(time.Hour),
+	}
+
+	t.Run("ban", func(t *testing.T) {
+		propagated = nil
+		req := req
+		req.Ban = true
+		s, err := PurgePosts(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		AssertDeepEquals(t, s, PurgeSummary{
+			Posts:   []uint64{2, 3},
+			Threads: []uint64{3},
+			Boards:  []string{"a"},
+			Banned:  true,
+		})
+		AssertDeepEquals(t, propagated, []uint64{2, 3})
+		if _, err := GetBanInfo(ip, "", "a"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := GetBanInfo(ip, "", "b"); err != sql.ErrNoRows {
+			UnexpectedError(t, err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		propagated = nil
+		req := req
+		req.Board = "all"
+		req.Delete = true
+		s, err := PurgePosts(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		AssertDeepEquals(t, s, PurgeSummary{
+			Posts:   []uint64{2, 3, 5},
+			Threads: []uint64{3, 5},
+			Boards:  []string{"a", "b"},
+			Deleted: true,
+		})
+		AssertDeepEquals(t, propagated, []uint64{2, 3, 5})
+		assertThreadCounters(t, 1, 1, 0)
+		// Threads take their replies with them
+		for _, id := range [...]uint64{2, 3, 4, 5} {
+			if _, err := GetPost(id); err != sql.ErrNoRows {
+				UnexpectedError(t, err)
+			}
+		}
+	})
+
+	t.Run("nothing to purge", func(t *testing.T) {
+		if _, err := PurgePosts(req); err != sql.ErrNoRows {
+			UnexpectedError(t, err)
+		}
+		req := req
+		req.ID = 1
+		req.Since = time.Now().Add(time.Hour)
+		if _, err := PurgePosts(req); err != sql.ErrNoRows {
+			UnexpectedError(t, err)
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/cutechan/cutechan/go/common"
+
+	"github.com/lib/pq"
+)
+
+// InsertAppeal records appeal of the ban. Returns false if the ban was
+// already appealed.
+func InsertAppeal(
+	board, ipRange, account string,
+	post uint64,
+	text string,
+) (inserted bool, err error) {
+	res, err := prepared["insert_appeal"].
+		Exec(board, ipRange, account, post, text)
+	if err != nil {
+		return
+	}
+	n, err := res.RowsAffected()
+	inserted = n != 0
+	return
+}
+
+// GetClientBans retrieves all active bans matching the IP or account
+// along with their appeals.
+func GetClientBans(ip, account string) (bans []common.BanInfo, err error) {
+	bans = make([]common.BanInfo, 0)
+	rs, err := prepared["get_client_bans"].Query(ip, account)
+	if err != nil {
+		return
+	}
+	defer rs.Close()
+	for rs.Next() {
+		var (
+			b        common.BanInfo
+			expires  time.Time
+			id       sql.NullInt64
+			text     sql.NullString
+			status   sql.NullInt64
+			response sql.NullString
+			created  pq.NullTime
+		)
+		err = rs.Scan(
+			&b.Board, &b.Post, &b.Reason, &expires,
+			&id, &text, &status, &response, &created,
+		)
+		if err != nil {
+			return
+		}
+		b.Expires = expires.Unix()
+		if id.Valid {
+			b.Appeal = &common.Appeal{
+				ID:       uint64(id.Int64),
+				Board:    b.Board,
+				Post:     b.Post,
+				Reason:   b.Reason,
+				Text:     text.String,
+				Status:   common.AppealStatus(status.Int64),
+				Response: response.String,
+				Created:  created.Time.Unix(),
+			}
+		}
+		bans = append(bans, b)
+	}
+	err = rs.Err()
+	return
+}
+
+// GetAppeals retrieves pending appeals of the board's bans.
+func GetAppeals(board string) (appeals []common.Appeal, err error) {
+	appeals = make([]common.Appeal, 0)
+	rs, err := prepared["get_appeals"].Query(board)
+	if err != nil {
+		return
+	}
+	defer rs.Close()
+	for rs.Next() {
+		var (
+			a       common.Appeal
+			created time.Time
+		)
+		err = rs.Scan(
+			&a.ID, &a.Board, &a.IP, &a.Account, &a.Post, &a.Reason, &a.Text,
+			&a.Status, &a.Response, &created,
+		)
+		if err != nil {
+			return
+		}
+		a.Created = created.Unix()
+		appeals = append(appeals, a)
+	}
+	err = rs.Err()
+	return
+}
+
+// GetAppeal retrieves a single appeal by ID.
+func GetAppeal(id uint64) (a common.Appeal, err error) {
+	var created time.Time
+	err = prepared["get_appeal"].QueryRow(id).Scan(
+		&a.ID, &a.Board, &a.IP, &a.Account, &a.Post, &a.Text, &a.Status,
+		&a.Response, &created,
+	)
+	a.Created = created.Unix()
+	return
+}
+
+// AcceptAppeal lifts the appealed ban. Returns sql.ErrNoRows if the
+// appeal was already resolved, the ban is left intact then.
+func AcceptAppeal(a common.Appeal, by string) (err error) {
+	tx, err := BeginTx()
+	if err != nil {
+		return
+	}
+	defer EndTx(tx, &err)
+
+	if err = execAffectedTx(tx, "accept_appeal", a.ID, by); err != nil {
+		return
+	}
+	// Ban might have already expired or been lifted by hand.
+	switch {
+	case a.Post != 0:
+		err = execPreparedTx(tx, "unban", a.Board, a.Post, by)
+	case a.IP != "":
+		err = execPreparedTx(tx, "unban_range", a.Board, a.IP, by)
+	default:
+		err = execPreparedTx(tx, "unban_account", a.Board, a.Account, by)
+	}
+	return
+}
+
+// RejectAppeal keeps the ban in place, leaving response to the banned
+// user. Returns sql.ErrNoRows if the appeal was already resolved.
+func RejectAppeal(id uint64, response, by string) error {
+	return execAffected("reject_appeal", id, response, by)
+}
@@ -0,0 +1,184 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/cutechan/cutechan/go/auth"
+	"github.com/cutechan/cutechan/go/common"
+	. "github.com/cutechan/cutechan/go/test"
+)
+
+func TestAppeals(t *testing.T) {
+	assertTableClear(t, "bans", "appeals")
+	const ipRange = "10.0.0.0/24"
+	expires := time.Now().Add(time.Hour)
+	err := BanRange("a", ipRange, "spam", "admin", expires, auth.RegularBan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Shadow bans are not shown to the banned
+	err = BanRange("b", ipRange, "spam", "admin", expires, auth.ShadowBan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bans, err := GetClientBans("10.0.0.5", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, len(bans), 1)
+	AssertDeepEquals(t, bans[0].Board, "a")
+	if bans[0].Appeal != nil {
+		t.Fatal("unexpected appeal")
+	}
+
+	for _, inserted := range [...]bool{true, false} {
+		ok, err := InsertAppeal("a", ipRange, "", 0, "sorry")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != inserted {
+			t.Fatalf("expected inserted=%v", inserted)
+		}
+	}
+	appeals, err := GetAppeals("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, len(appeals), 1)
+	AssertDeepEquals(t, appeals[0].Reason, "spam")
+
+	if err := RejectAppeal(appeals[0].ID, "no", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RejectAppeal(appeals[0].ID, "no", "admin"); err != sql.ErrNoRows {
+		UnexpectedError(t, err)
+	}
+	// Resolved appeal doesn't lift the ban.
+	if err := AcceptAppeal(appeals[0], "admin"); err != sql.ErrNoRows {
+		UnexpectedError(t, err)
+	}
+	bans, err = GetClientBans("10.0.0.5", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, len(bans), 1)
+	AssertDeepEquals(t, bans[0].Appeal.Status, common.AppealRejected)
+	AssertDeepEquals(t, bans[0].Appeal.Response, "no")
+}
+
+func writeSampleAccount(t *testing.T, id string) {
+	assertExec(t, `DELETE FROM accounts WHERE id = $1`, id)
+	if err := RegisterAccount(id, []byte("hash")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAccountAppeals(t *testing.T) {
+	assertTableClear(t, "bans", "appeals")
+	writeSampleAccount(t, "user1")
+	expires := time.Now().Add(time.Hour)
+	err := BanAccount("a", "user1", "spam", "admin", expires, auth.RegularBan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("ip only", func(t *testing.T) {
+		bans, err := GetClientBans("10.0.0.5", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		AssertDeepEquals(t, len(bans), 0)
+		_, err = GetBanInfo("10.0.0.5", "", "a")
+		if err != sql.ErrNoRows {
+			UnexpectedError(t, err)
+		}
+	})
+
+	bans, err := GetClientBans("10.0.0.5", "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, len(bans), 1)
+	ban, err := GetBanInfo("10.0.0.5", "user1", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, ban.IP, "")
+	AssertDeepEquals(t, ban.Account, "user1")
+
+	ok, err := InsertAppeal("a", ban.IP, ban.Account, ban.ID, "sorry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("appeal not inserted")
+	}
+	appeals, err := GetAppeals("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, len(appeals), 1)
+	AssertDeepEquals(t, appeals[0].Account, "user1")
+
+	if err := AcceptAppeal(appeals[0], "admin"); err != nil {
+		t.Fatal(err)
+	}
+	bans, err = GetClientBans("10.0.0.5", "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, len(bans), 0)
+	if err := UnbanAccount("a", "user1", "admin"); err != sql.ErrNoRows {
+		UnexpectedError(t, err)
+	}
+}
+
+// Post ban takes over the account from older bans of the same account.
+func TestBanAccountPosts(t *testing.T) {
+	assertTableClear(t, "boards", "bans")
+	writeSampleAccount(t, "user1")
+	writeSampleBoard(t)
+	writeSampleThread(t)
+	for i, ip := range [...]string{"10.0.0.1", "10.0.1.1"} {
+		p := samplePost(uint64(i+2), 1)
+		p.IP = ip
+		p.UserID = "user1"
+		writeSamplePost(t, p)
+	}
+	expires := time.Now().Add(time.Hour)
+	err := BanAccount("a", "user1", "spam", "admin", expires, auth.RegularBan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range [...]uint64{2, 3} {
+		_, err := Ban("a", "spam", "admin", expires, auth.RegularBan, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var n int
+	err = db.QueryRow(`SELECT count(*) FROM bans WHERE account = 'user1'`).
+		Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, n, 1)
+	ban, err := GetBanInfo("192.168.0.1", "user1", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, ban.ID, uint64(3))
+
+	if err := UnbanAccount("a", "user1", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	// IP ban of the older post is kept.
+	_, err = GetBanInfo("10.0.0.1", "", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
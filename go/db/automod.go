@@ -0,0 +1,110 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/cutechan/cutechan/go/automod"
+	"github.com/cutechan/cutechan/go/common"
+
+	"github.com/lib/pq"
+)
+
+// Retrieve automod rules of the specified boards.
+func GetAutomodRules(tx *sql.Tx, boards []string) (rules automod.Rules, err error) {
+	rs, err := getStatement(tx, "get_automod_rules").Query(pq.Array(boards))
+	if err != nil {
+		return
+	}
+	return scanAutomodRules(rs)
+}
+
+func scanAutomodRules(rs *sql.Rows) (rules automod.Rules, err error) {
+	defer rs.Close()
+	rules = make(automod.Rules, 0)
+	for rs.Next() {
+		var r automod.Rule
+		err = rs.Scan(
+			&r.Board, &r.Condition, &r.Pattern, &r.Action, &r.Message,
+			&r.DryRun,
+		)
+		if err != nil {
+			return
+		}
+		rules = append(rules, r)
+	}
+	err = rs.Err()
+	return
+}
+
+// Set automod rules of specified board, overwriting the old values.
+func WriteAutomodRules(tx *sql.Tx, board string, rules automod.Rules) (err error) {
+	if _, err = getStatement(tx, "clear_automod_rules").Exec(board); err != nil {
+		return
+	}
+	st := getStatement(tx, "write_automod_rule")
+	for i, r := range rules {
+		_, err = st.Exec(
+			board, i, r.Condition, r.Pattern, r.Action, r.Message, r.DryRun)
+		if err != nil {
+			return
+		}
+	}
+	_, err = tx.Exec(`notify automod_updated`)
+	return
+}
+
+func loadAutomodRules() error {
+	if err := RefreshAutomodCache(); err != nil {
+		return err
+	}
+	return listenFunc("automod_updated", func(_ string) error {
+		return RefreshAutomodCache()
+	})
+}
+
+// RefreshAutomodCache loads up to date automod rules from the database
+// and caches them in memory.
+func RefreshAutomodCache() (err error) {
+	rs, err := prepared["load_automod_rules"].Query()
+	if err != nil {
+		return
+	}
+	rules, err := scanAutomodRules(rs)
+	if err != nil {
+		return
+	}
+	automod.SetRules(rules...)
+	return
+}
+
+// IsNewIP returns true if there are no posts from the IP. Note that IPs
+// of old posts are wiped by upkeep.
+func IsNewIP(ip string) (isNew bool, err error) {
+	err = prepared["has_ip_posts"].QueryRow(ip).Scan(&isNew)
+	isNew = !isNew
+	return
+}
+
+// CountRecentPosts returns number of posts from the IP created within
+// the given duration.
+func CountRecentPosts(ip string, d time.Duration) (n int, err error) {
+	since := time.Now().Add(-d).Unix()
+	err = prepared["count_recent_posts"].QueryRow(ip, since).Scan(&n)
+	return
+}
+
+// LogAutomodMatch records automod rule matched on the newly created
+// post. Post ID is 0 if the post was rejected.
+func LogAutomodMatch(tx *sql.Tx, id uint64, r automod.Rule) error {
+	_, err := getStatement(tx, "log_automod_match").
+		Exec(r.Board, id, r.Position, r.Action, r.DryRun)
+	return err
+}
+
+// InsertAutomodReport reports the newly created post to moderators on
+// behalf of automod.
+func InsertAutomodReport(tx *sql.Tx, id uint64, ip, text string) error {
+	return execPreparedTx(
+		tx, "insert_report", id, ip, common.ReportOther, text)
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/cutechan/cutechan/go/auth"
+	"github.com/cutechan/cutechan/go/automod"
+	. "github.com/cutechan/cutechan/go/test"
+)
+
+func TestLogAutomodMatch(t *testing.T) {
+	assertTableClear(t, "automod_matches", "mod_log")
+	r := automod.Rule{
+		Board:    "a",
+		Action:   automod.Hold,
+		DryRun:   true,
+		Position: 2,
+	}
+	// Rejected posts are logged outside of transaction
+	if err := LogAutomodMatch(nil, 0, r); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		post     uint64
+		position int
+		action   automod.Action
+		dryRun   bool
+	)
+	err := db.
+		QueryRow(`SELECT post, position, action, dry_run FROM automod_matches
+			WHERE board = 'a'`).
+		Scan(&post, &position, &action, &dryRun)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, post, uint64(0))
+	AssertDeepEquals(t, position, 2)
+	AssertDeepEquals(t, action, automod.Hold)
+	AssertDeepEquals(t, dryRun, true)
+
+	log, err := GetModLog([]string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, len(log), 1)
+	AssertDeepEquals(t, log[0].Type, auth.AutomodMatch)
+}
@@ -122,5 +122,10 @@ func UpdateBoard(tx *sql.Tx, c config.BoardConfig, by string) (err error) {
 	if err != nil {
 		return
 	}
-	return execPreparedTx(tx, "update_board", c.ID, c.ModOnly, stData, by)
+	err = execPreparedTx(tx, "update_board", c.ID, c.ModOnly, stData, by)
+	if err != nil {
+		return
+	}
+	// Board might become mod-only or public.
+	return execPreparedTx(tx, "update_board_images", c.ID)
 }
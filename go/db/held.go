@@ -0,0 +1,114 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/cutechan/cutechan/go/common"
+)
+
+// IsNewPoster returns true if there are less than n published posts on
+// the board from the IP or account, so the new post should be held.
+// Note that IPs of old posts are wiped by upkeep while accounts are kept.
+func IsNewPoster(board, ip, account string, n int) (isNew bool, err error) {
+	var cnt int
+	err = prepared["count_poster_posts"].QueryRow(board, ip, account).Scan(&cnt)
+	isNew = cnt < n
+	return
+}
+
+// GetHeldPosts retrieves posts awaiting approval on the board, oldest
+// first.
+func GetHeldPosts(board string) (posts []common.StandalonePost, err error) {
+	posts = make([]common.StandalonePost, 0)
+	rs, err := prepared["get_held_post_ids"].Query(board)
+	if err != nil {
+		return
+	}
+	defer rs.Close()
+	var ids []uint64
+	for rs.Next() {
+		var id uint64
+		if err = rs.Scan(&id); err != nil {
+			return
+		}
+		ids = append(ids, id)
+	}
+	if err = rs.Err(); err != nil {
+		return
+	}
+
+	for _, id := range ids {
+		var p common.StandalonePost
+		p, err = GetPost(id)
+		switch err {
+		case nil:
+			posts = append(posts, p)
+		case sql.ErrNoRows:
+			// Deleted in the meantime.
+			err = nil
+		default:
+			return
+		}
+	}
+	return
+}
+
+// ApprovePost publishes the held post, counting it in its thread.
+// Returns sql.ErrNoRows if the post isn't held.
+func ApprovePost(id uint64, by string) (err error) {
+	tx, err := BeginTx()
+	if err != nil {
+		return
+	}
+	defer EndTx(tx, &err)
+
+	var (
+		op      uint64
+		board   string
+		shadow  bool
+		fileCnt int
+	)
+	err = getStatement(tx, "approve_post").
+		QueryRow(id).
+		Scan(&op, &board, &shadow, &fileCnt)
+	if err != nil {
+		return
+	}
+	// Shadow banned posts don't affect thread counters.
+	if op != id && !shadow {
+		err = execPreparedTx(tx, "bump_approved_post", op, fileCnt)
+		if err != nil {
+			return
+		}
+	}
+	err = execPreparedTx(tx, "log_approve_post", board, id, by)
+	return
+}
+
+// RejectPost removes the held post, together with the whole thread if
+// it's an OP. Returns sql.ErrNoRows if the post isn't held.
+func RejectPost(id uint64, by string) (err error) {
+	tx, err := BeginTx()
+	if err != nil {
+		return
+	}
+	defer EndTx(tx, &err)
+
+	var op uint64
+	err = getStatement(tx, "get_held_post_op").QueryRow(id).Scan(&op)
+	if err != nil {
+		return
+	}
+	hashes, err := getPostFiles(tx, []uint64{id})
+	if err != nil {
+		return
+	}
+	q := "reject_post"
+	if op == id {
+		q = "reject_thread"
+	}
+	if _, err = getStatement(tx, q).Exec(id, by); err != nil {
+		return
+	}
+	return updateRestricted(tx, hashes)
+}
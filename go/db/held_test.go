@@ -0,0 +1,138 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	. "github.com/cutechan/cutechan/go/test"
+)
+
+func writeHeldPost(t *testing.T, id, op uint64, files ...string) {
+	p := samplePost(id, op, files...)
+	p.Held = true
+	writeSamplePost(t, p)
+}
+
+func TestHeldPosts(t *testing.T) {
+	assertTableClear(t, "boards", "images")
+	writeSampleBoard(t)
+	writeSampleFiles(t, sampleSHA1, sampleSHA1b)
+	writeSampleThread(t)
+	writeSamplePost(t, samplePost(2, 1, sampleSHA1))
+	writeHeldPost(t, 3, 1, sampleSHA1b)
+	writeHeldPost(t, 4, 1)
+	writeHeldPost(t, 5, 5)
+	assertThreadCounters(t, 1, 2, 1)
+
+	t.Run("filters", func(t *testing.T) {
+		held, err := GetHeldPosts("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]uint64, 0, len(held))
+		for _, p := range held {
+			ids = append(ids, p.ID)
+		}
+		AssertDeepEquals(t, ids, []uint64{3, 4, 5})
+
+		threads, err := GetThreadIDs("a", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		AssertDeepEquals(t, threads, []uint64{1})
+
+		thread, err := GetThread(1, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		AssertDeepEquals(t, len(thread.Posts), 1)
+		AssertDeepEquals(t, thread.Posts[0].ID, uint64(2))
+	})
+
+	// Held posts were never counted
+	if err := DeletePost(4, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	assertThreadCounters(t, 1, 2, 1)
+
+	if err := ApprovePost(3, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	assertThreadCounters(t, 1, 3, 2)
+	if err := ApprovePost(3, "admin"); err != sql.ErrNoRows {
+		UnexpectedError(t, err)
+	}
+
+	writeHeldPost(t, 6, 1, sampleSHA1)
+	writeHeldPost(t, 7, 1, sampleSHA1b)
+	if err := DeleteImage(7, 0, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	assertThreadCounters(t, 1, 3, 2)
+	if _, err := SplitThread(1, []uint64{2}, "a", "split", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	assertThreadCounters(t, 1, 2, 1)
+
+	t.Run("reject", func(t *testing.T) {
+		if err := RejectPost(3, "admin"); err != sql.ErrNoRows {
+			UnexpectedError(t, err)
+		}
+		// Published threads are never deleted as held
+		res, err := prepared["reject_thread"].Exec(1, "admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, _ := res.RowsAffected(); n != 0 {
+			t.Fatal("published thread rejected")
+		}
+
+		for _, id := range [...]uint64{5, 6} {
+			if err := RejectPost(id, "admin"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := GetPost(id); err != sql.ErrNoRows {
+				UnexpectedError(t, err)
+			}
+		}
+		assertThreadCounters(t, 1, 2, 1)
+	})
+}
+
+func TestIsNewPoster(t *testing.T) {
+	assertTableClear(t, "boards")
+	writeSampleBoard(t)
+	writeSampleAccount(t, "user1")
+	op := samplePost(1, 1)
+	op.IP = "::1"
+	writeSamplePost(t, op)
+	// Account is matched even if name isn't shown
+	p := samplePost(2, 1)
+	p.IP = "::2"
+	p.Account = "user1"
+	writeSamplePost(t, p)
+	p = samplePost(3, 1)
+	p.IP = "::3"
+	p.Held = true
+	writeSamplePost(t, p)
+
+	cases := [...]struct {
+		name, ip, account string
+		isNew             bool
+	}{
+		{"ip", "::1", "", false},
+		{"account", "::4", "user1", false},
+		{"held only", "::3", "", true},
+		{"unknown", "::4", "", true},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			isNew, err := IsNewPoster("a", c.ip, c.account, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			AssertDeepEquals(t, isNew, c.isNew)
+		})
+	}
+}
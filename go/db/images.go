@@ -2,6 +2,8 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
+	"math"
 	"time"
 
 	"github.com/cutechan/cutechan/go/auth"
@@ -23,10 +25,20 @@ func WriteImage(tx *sql.Tx, i common.ImageCommon) error {
 	_, err := getStatement(tx, "write_image").Exec(
 		i.APNG, i.Audio, i.Video, i.FileType, i.ThumbType, dims, i.Length,
 		i.Size, i.MD5, i.SHA1, i.Title, i.Artist,
+		thumbSizesArray(i.ThumbSizes), i.WebP,
 	)
 	return err
 }
 
+// Column is not nullable so always pass an array.
+func thumbSizesArray(sizes []uint16) pq.Int64Array {
+	arr := make(pq.Int64Array, len(sizes))
+	for i, size := range sizes {
+		arr[i] = int64(size)
+	}
+	return arr
+}
+
 // GetImage retrieves a thumbnailed image record from the DB.
 func GetImage(SHA1 string) (common.ImageCommon, error) {
 	return scanImage(prepared["get_image"].QueryRow(SHA1))
@@ -79,7 +91,7 @@ func DeleteImageToken(token string) (err error) {
 
 // AllocateImage allocates an image's file resources to their respective
 // served directories and write its data to the database.
-func AllocateImage(src, thumb []byte, img common.ImageCommon) (err error) {
+func AllocateImage(src, thumb []byte, thumbs []file.Thumb, img common.ImageCommon) (err error) {
 	tx, err := BeginTx()
 	if err != nil {
 		return
@@ -93,18 +105,167 @@ func AllocateImage(src, thumb []byte, img common.ImageCommon) (err error) {
 	}
 
 	err = file.Backend.Write(img.SHA1, img.FileType, img.ThumbType, src, thumb)
+	if err == nil {
+		err = file.Backend.WriteThumbs(img.SHA1, thumbs)
+	}
 	if err != nil {
-		err = cleanUpFailedAllocation(img, err)
+		err = cleanUpFailedAllocation(img, thumbs, err)
 	}
 
 	return
 }
 
 // Delete any dangling image files in case of a failed image allocation.
-func cleanUpFailedAllocation(img common.ImageCommon, err error) error {
+func cleanUpFailedAllocation(img common.ImageCommon, thumbs []file.Thumb, err error) error {
 	delErr := file.Backend.Delete(img.SHA1, img.FileType, img.ThumbType)
+	if delErr == nil {
+		delErr = file.Backend.DeleteThumbs(img.SHA1, thumbs)
+	}
 	if delErr != nil {
 		err = util.WrapError(err.Error(), delErr)
 	}
 	return err
 }
+
+// GetImages retrieves next batch of images ordered by SHA1.
+func GetImages(after string, limit int) ([]common.ImageCommon, error) {
+	return queryImages("get_images", after, limit)
+}
+
+// GetImagesWithoutVariants retrieves next batch of legacy images which
+// only have base thumbnail, ordered by SHA1.
+func GetImagesWithoutVariants(after string, limit int) ([]common.ImageCommon, error) {
+	return queryImages("get_images_without_variants", after, limit)
+}
+
+func queryImages(id string, args ...interface{}) (imgs []common.ImageCommon, err error) {
+	r, err := prepared[id].Query(args...)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+	for r.Next() {
+		var img common.ImageCommon
+		img, err = scanImage(r)
+		if err != nil {
+			return
+		}
+		imgs = append(imgs, img)
+	}
+	err = r.Err()
+	return
+}
+
+// IsImageRestricted reports whether file is attached only to posts on
+// mod-only boards.
+func IsImageRestricted(sha1 string) (restricted bool, err error) {
+	err = prepared["get_image_restricted"].QueryRow(sha1).Scan(&restricted)
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	return
+}
+
+// Get files of the posts, including all posts of the threads if OPs are
+// passed. Must be called before deleting or moving them so their
+// restricted state could be updated afterwards.
+func getPostFiles(tx *sql.Tx, ids []uint64) ([]string, error) {
+	return queryFileHashes(tx, "get_post_file_hashes", pq.Array(ids))
+}
+
+func queryFileHashes(tx *sql.Tx, id string, args ...interface{}) (
+	hashes []string, err error,
+) {
+	r, err := getStatement(tx, id).Query(args...)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+	for r.Next() {
+		var sha1 string
+		if err = r.Scan(&sha1); err != nil {
+			return
+		}
+		hashes = append(hashes, sha1)
+	}
+	err = r.Err()
+	return
+}
+
+// Recompute restricted state of files after some of their posts were
+// deleted or moved to another board.
+func updateRestricted(tx *sql.Tx, hashes []string) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+	return execPreparedTx(tx, "update_images_restricted", pq.StringArray(hashes))
+}
+
+// ImageFilter selects images attached to posts matching all set
+// criteria. Zero values match anything.
+type ImageFilter struct {
+	Board     string
+	Since     time.Time
+	Until     time.Time
+	FileTypes []uint8
+}
+
+// GetFilteredImages retrieves next batch of images matching the filter,
+// ordered by SHA1.
+func GetFilteredImages(after string, f ImageFilter, limit int) ([]common.ImageCommon, error) {
+	fileTypes := make(pq.Int64Array, len(f.FileTypes))
+	for i, t := range f.FileTypes {
+		fileTypes[i] = int64(t)
+	}
+	var since, until int64 = 0, math.MaxInt64
+	if !f.Since.IsZero() {
+		since = f.Since.Unix()
+	}
+	if !f.Until.IsZero() {
+		until = f.Until.Unix()
+	}
+	return queryImages("get_filtered_images", after, fileTypes, f.Board, since, until, limit)
+}
+
+// OnThreadUpdated is called when thread is changed by another process,
+// e.g. by rethumb command, so its cached pages must be dropped. Bumping
+// isn't enough since counters have one second precision. Set by the
+// server.
+var OnThreadUpdated = func(id uint64, board string) {}
+
+func listenThreadUpdates() error {
+	return listenFunc("thread_updated", func(msg string) error {
+		var (
+			id    uint64
+			board string
+		)
+		if _, err := fmt.Sscanf(msg, "%d %s", &id, &board); err != nil {
+			return err
+		}
+		OnThreadUpdated(id, board)
+		return nil
+	})
+}
+
+// SetImageThumb records regenerated thumbnail info and bumps threads
+// containing the image, so their cached pages are rebuilt.
+func SetImageThumb(img common.ImageCommon) (err error) {
+	tx, err := BeginTx()
+	if err != nil {
+		return
+	}
+	defer EndTx(tx, &err)
+
+	err = execPreparedTx(tx, "set_image_thumb",
+		img.SHA1, img.ThumbType, pq.GenericArray{A: img.Dims},
+		thumbSizesArray(img.ThumbSizes), img.WebP)
+	if err != nil {
+		return
+	}
+	return execPreparedTx(tx, "bump_image_threads", img.SHA1)
+}
+
+// SetThumbVariants records additional thumbnail renditions of the image.
+func SetThumbVariants(img common.ImageCommon) error {
+	return execPrepared("set_thumb_variants", img.SHA1, thumbSizesArray(img.ThumbSizes), img.WebP)
+}
@@ -167,6 +167,160 @@ var upgrades = []func(*sql.Tx) error{
 			`CREATE INDEX posts_op_time ON posts (op, time)`,
 		)
 	},
+	// Thumbnail renditions.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`ALTER TABLE images
+				ADD COLUMN thumbSizes smallint[] NOT NULL DEFAULT '{}',
+				ADD COLUMN webp boolean NOT NULL DEFAULT false`,
+		)
+	},
+	// Files posted only on mod-only boards.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`ALTER TABLE images
+				ADD COLUMN restricted boolean NOT NULL DEFAULT false`,
+			`UPDATE images SET restricted = true
+				WHERE sha1 IN (
+					SELECT pf.file_hash
+					FROM post_files pf
+					JOIN posts p ON p.id = pf.post_id
+					JOIN boards b ON b.id = p.board
+					GROUP BY pf.file_hash
+					HAVING bool_and(b.modOnly)
+				)`,
+		)
+	},
+	// Per-file spoilers.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`ALTER TABLE post_files
+				ADD COLUMN spoiler boolean NOT NULL DEFAULT false`,
+		)
+	},
+	// Soft deletion of boards.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`ALTER TABLE boards
+				ADD COLUMN deleted timestamp`,
+		)
+	},
+	// Thread locking.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`ALTER TABLE threads
+				ADD COLUMN locked boolean NOT NULL DEFAULT false`,
+		)
+	},
+	// User reports on posts.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`CREATE TABLE reports (
+				id bigserial PRIMARY KEY,
+				post_id bigint NOT NULL REFERENCES posts ON DELETE CASCADE,
+				ip inet NOT NULL,
+				reason smallint NOT NULL,
+				text varchar(200) NOT NULL DEFAULT '',
+				created timestamp NOT NULL DEFAULT (now() at time zone 'utc'),
+				UNIQUE (post_id, ip)
+			)`,
+		)
+	},
+	// Ban appeals.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`CREATE TABLE appeals (
+				id bigserial PRIMARY KEY,
+				board text NOT NULL,
+				ip inet,
+				account varchar(20),
+				forPost bigint NOT NULL DEFAULT 0,
+				text varchar(1000) NOT NULL,
+				status smallint NOT NULL DEFAULT 0,
+				response varchar(200) NOT NULL DEFAULT '',
+				by varchar(20),
+				created timestamp NOT NULL DEFAULT (now() at time zone 'utc'),
+				UNIQUE (ip, board),
+				UNIQUE (account, board),
+				CHECK (ip IS NOT NULL OR account IS NOT NULL)
+			)`,
+		)
+	},
+	// Shadow bans.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`ALTER TABLE bans
+				ADD COLUMN type smallint NOT NULL DEFAULT 0`,
+			`ALTER TABLE posts
+				ADD COLUMN shadow boolean NOT NULL DEFAULT false`,
+		)
+	},
+	// Account bans.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`ALTER TABLE bans
+				DROP CONSTRAINT bans_pkey`,
+			`ALTER TABLE bans
+				ALTER COLUMN ip DROP NOT NULL,
+				ADD COLUMN account varchar(20)
+					REFERENCES accounts ON DELETE CASCADE,
+				ADD UNIQUE (ip, board),
+				ADD UNIQUE (account, board),
+				ADD CHECK (ip IS NOT NULL OR account IS NOT NULL)`,
+		)
+	},
+	// Staff notes. Notes left without both post and IP are removed by
+	// upkeep.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`CREATE TABLE staff_notes (
+				id bigserial PRIMARY KEY,
+				board text NOT NULL,
+				post bigint REFERENCES posts ON DELETE SET NULL,
+				ip_hash char(64),
+				text varchar(500) NOT NULL,
+				by varchar(20) NOT NULL,
+				created timestamp NOT NULL DEFAULT (now() at time zone 'utc')
+			)`,
+			`CREATE INDEX staff_notes_board ON staff_notes (board)`,
+			`CREATE INDEX staff_notes_post ON staff_notes (post)`,
+			`CREATE INDEX staff_notes_ip_hash ON staff_notes (ip_hash)`,
+		)
+	},
+	// Automod rules.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`CREATE TABLE automod_rules (
+				board text NOT NULL REFERENCES boards ON DELETE CASCADE,
+				position smallint NOT NULL,
+				condition smallint NOT NULL,
+				pattern varchar(1000) NOT NULL,
+				action smallint NOT NULL,
+				message varchar(200) NOT NULL,
+				dry_run boolean NOT NULL,
+				PRIMARY KEY (board, position)
+			)`,
+			`CREATE TABLE automod_matches (
+				board text NOT NULL,
+				post bigint NOT NULL,
+				position smallint NOT NULL,
+				action smallint NOT NULL,
+				dry_run boolean NOT NULL,
+				created timestamp NOT NULL DEFAULT (now() at time zone 'utc')
+			)`,
+			`CREATE INDEX automod_matches_board ON automod_matches (board)`,
+		)
+	},
+	// Held posts.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`ALTER TABLE posts
+				ADD COLUMN held boolean NOT NULL DEFAULT false,
+				ADD COLUMN account varchar(20)`,
+			`CREATE INDEX posts_held ON posts (board) WHERE held`,
+			`CREATE INDEX posts_account ON posts (account)`,
+		)
+	},
 }
 
 func StartDB() (err error) {
@@ -190,7 +344,9 @@ func StartDB() (err error) {
 	if !exists {
 		tasks = append(tasks, createAdminAccount)
 	}
-	tasks = append(tasks, loadServerConfig, loadBoardConfigs, loadBans)
+	tasks = append(
+		tasks, loadServerConfig, loadBoardConfigs, loadBans, loadIPSalt,
+		loadAutomodRules, listenThreadUpdates)
 	if err = util.Waterfall(tasks...); err != nil {
 		return
 	}
@@ -1,11 +1,23 @@
 package db
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/cutechan/cutechan/go/common"
+)
 
 func init() {
 	ConnArgs = TestConnArgs
 	IsTest = true
-	if err := LoadDB(); err != nil {
+	// No feeds to propagate moderation to
+	noop := func(id, op uint64) error { return nil }
+	common.BanPost = noop
+	common.DeletePost = noop
+	noopFile := func(id, op uint64, n int) error { return nil }
+	common.DeleteImage = noopFile
+	common.SpoilerImage = noopFile
+	common.LockThread = func(id uint64, locked bool) error { return nil }
+	if err := StartDB(); err != nil {
 		panic(err)
 	}
 }
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"time"
+
+	"github.com/cutechan/cutechan/go/auth"
+	"github.com/cutechan/cutechan/go/common"
+)
+
+// Load secret salt of IP hashes, generating it on first start.
+func loadIPSalt() (err error) {
+	salt, err := auth.RandomID(32)
+	if err != nil {
+		return
+	}
+	_, err = db.Exec(
+		`insert into main (id, val) values ('ip_salt', $1)
+			on conflict do nothing`,
+		salt)
+	if err != nil {
+		return
+	}
+	err = db.QueryRow(`select val from main where id = 'ip_salt'`).Scan(&salt)
+	if err != nil {
+		return
+	}
+	auth.SetIPSalt([]byte(salt))
+	return
+}
+
+// InsertNote records staff note on the post or hashed IP. Both can be
+// set.
+func InsertNote(
+	board string,
+	post uint64,
+	ipHash, text, by string,
+) (n common.StaffNote, err error) {
+	var created time.Time
+	err = prepared["insert_note"].
+		QueryRow(board, post, ipHash, text, by).
+		Scan(&n.ID, &created)
+	if err != nil {
+		return
+	}
+	n.Board = board
+	n.Post = post
+	n.ByIP = ipHash != ""
+	n.Text = text
+	n.By = by
+	n.Created = created.Unix()
+	return
+}
+
+// GetPostNotes retrieves notes of the board attached either to the post
+// or to hashed IP of its author.
+func GetPostNotes(board string, post uint64, ipHash string) (
+	[]common.StaffNote, error,
+) {
+	return queryNotes("get_post_notes", board, post, ipHash)
+}
+
+// SearchNotes retrieves recent notes of the board matching the query by
+// text, author or post ID, or matching hashed IP. Empty query matches
+// all notes.
+func SearchNotes(board, query, ipHash string) ([]common.StaffNote, error) {
+	return queryNotes("search_notes", board, query, ipHash)
+}
+
+func queryNotes(id string, args ...interface{}) (
+	notes []common.StaffNote, err error,
+) {
+	notes = make([]common.StaffNote, 0)
+	rs, err := prepared[id].Query(args...)
+	if err != nil {
+		return
+	}
+	defer rs.Close()
+	for rs.Next() {
+		var (
+			n       common.StaffNote
+			created time.Time
+		)
+		err = rs.Scan(
+			&n.ID, &n.Board, &n.Post, &n.ByIP, &n.Text, &n.By, &created)
+		if err != nil {
+			return
+		}
+		n.Created = created.Unix()
+		notes = append(notes, n)
+	}
+	err = rs.Err()
+	return
+}
+
+// GetNoteBoard returns board of the note.
+func GetNoteBoard(id uint64) (board string, err error) {
+	err = prepared["get_note_board"].QueryRow(id).Scan(&board)
+	return
+}
+
+// DeleteNote removes the note. Returns sql.ErrNoRows if there is no
+// such note.
+func DeleteNote(id uint64) error {
+	return execAffected("delete_note", id)
+}
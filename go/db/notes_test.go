@@ -0,0 +1,35 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/cutechan/cutechan/go/test"
+)
+
+func TestDeleteNotedPost(t *testing.T) {
+	assertTableClear(t, "boards", "staff_notes")
+	writeSampleBoard(t)
+	writeSampleThread(t)
+	writeSamplePost(t, samplePost(2, 1))
+	ipHash := strings.Repeat("0", 64)
+	for _, hash := range [...]string{"", ipHash} {
+		if _, err := InsertNote("a", 2, hash, "note", "admin"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Post-only note is left orphaned until upkeep.
+	assertExec(t, `DELETE FROM posts WHERE id = 2`)
+	if err := execPrepared("expire_orphan_notes"); err != nil {
+		t.Fatal(err)
+	}
+
+	notes, err := SearchNotes("a", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, len(notes), 1)
+	AssertDeepEquals(t, notes[0].Post, uint64(0))
+	AssertDeepEquals(t, notes[0].ByIP, true)
+}
@@ -28,6 +28,9 @@ var (
 
 	// Occurs when client tries to retrieve too much tokens.
 	ErrTokenForbidden = errors.New("token forbidden")
+
+	// Occurs when trying to post into the locked thread.
+	ErrThreadLocked = errors.New("thread is locked")
 )
 
 // Post is for writing new posts to a database. It contains the Password
@@ -36,6 +39,12 @@ type Post struct {
 	common.StandalonePost
 	Password []byte
 	IP       string
+	// Posting account, stored even if poster's name isn't shown
+	Account string
+	// Poster is shadow banned
+	Shadow bool
+	// Post awaits moderator approval
+	Held bool
 }
 
 // Thread is a template for writing new threads to the database
@@ -188,9 +197,25 @@ func (c commandRow) Value() (driver.Value, error) {
 	return strArr.Value()
 }
 
-// ValidateOP confirms the specified thread exists on specific board
+// ValidateOP confirms the specified thread exists on specific board and
+// accepts new posts. Returns ErrThreadLocked if thread is locked.
 func ValidateOP(id uint64, board string) (valid bool, err error) {
-	err = prepared["validate_op"].QueryRow(id, board).Scan(&valid)
+	var locked bool
+	err = prepared["validate_op"].QueryRow(id, board).Scan(&locked)
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, err
+	case locked:
+		return false, ErrThreadLocked
+	}
+	return true, nil
+}
+
+// ThreadExists confirms the specified thread exists on specific board
+func ThreadExists(id uint64, board string) (exists bool, err error) {
+	err = prepared["thread_exists"].QueryRow(id, board).Scan(&exists)
 	if err == sql.ErrNoRows {
 		return false, nil
 	}
@@ -274,13 +299,36 @@ func InsertThread(tx *sql.Tx, p Post, subject string) (err error) {
 	if err != nil {
 		return
 	}
+	if p.Shadow {
+		err = execPreparedTx(tx, "set_post_shadow", p.ID)
+		if err != nil {
+			return
+		}
+	}
+	if p.Held {
+		err = execPreparedTx(tx, "set_post_held", p.ID)
+		if err != nil {
+			return
+		}
+	}
+	if p.Account != "" {
+		err = execPreparedTx(tx, "set_post_account", p.ID, p.Account)
+		if err != nil {
+			return
+		}
+	}
 	err = InsertFiles(tx, p)
 	return
 }
 
-// InsertPost inserts a post into an existing thread.
+// InsertPost inserts a post into an existing thread. Shadow banned and
+// held posts don't affect thread counters.
 func InsertPost(tx *sql.Tx, p Post) (err error) {
-	args := getPostCreationArgs(p)
+	var account *string
+	if p.Account != "" {
+		account = &p.Account
+	}
+	args := append(getPostCreationArgs(p), p.Shadow, p.Held, account)
 	err = execPreparedTx(tx, "insert_post", args...)
 	if err != nil {
 		return
@@ -289,10 +337,15 @@ func InsertPost(tx *sql.Tx, p Post) (err error) {
 	return
 }
 
-// Write post files in current transaction.
+// Write post files in current transaction. Files attached only to
+// mod-only boards become restricted.
 func InsertFiles(tx *sql.Tx, p Post) (err error) {
 	for _, f := range p.Files {
-		err = execPreparedTx(tx, "insert_post_file", p.ID, f.SHA1)
+		err = execPreparedTx(tx, "insert_post_file", p.ID, f.SHA1, f.Spoiler)
+		if err != nil {
+			return
+		}
+		err = getStatement(tx, "set_image_restricted").QueryRow(f.SHA1).Scan(&f.Restricted)
 		if err != nil {
 			return
 		}
@@ -11,14 +11,19 @@ func TestValidateOp(t *testing.T) {
 	assertTableClear(t, "boards")
 	writeSampleBoard(t)
 	writeSampleThread(t)
+	writeSamplePost(t, samplePost(2, 2))
+	assertExec(t, `UPDATE threads SET locked = true WHERE id = 2`)
 
 	cases := [...]struct {
 		id      uint64
 		board   string
 		isValid bool
+		err     error
 	}{
-		{1, "a", true},
-		{15, "a", false},
+		{1, "a", true, nil},
+		{15, "a", false, nil},
+		{1, "b", false, nil},
+		{2, "a", false, ErrThreadLocked},
 	}
 
 	for i := range cases {
@@ -26,8 +31,8 @@ func TestValidateOp(t *testing.T) {
 		t.Run("", func(t *testing.T) {
 			t.Parallel()
 			valid, err := ValidateOP(c.id, c.board)
-			if err != nil {
-				t.Fatal(err)
+			if err != c.err {
+				t.Fatalf("unexpected error: %v", err)
 			}
 			if valid != c.isValid {
 				t.Fatal("unexpected result")
@@ -37,8 +42,8 @@ func TestValidateOp(t *testing.T) {
 }
 
 func writeSampleBoard(t *testing.T) {
-	b := BoardConfigs{
-		BoardConfigs: config.BoardConfigs{
+	b := config.BoardConfig{
+		BoardPublic: config.BoardPublic{
 			ID: "a",
 		},
 	}
@@ -48,20 +53,61 @@ func writeSampleBoard(t *testing.T) {
 }
 
 func writeSampleThread(t *testing.T) {
-	thread := Thread{
-		ID:    1,
-		Board: "a",
-	}
-	op := Post{
+	writeSamplePost(t, samplePost(1, 1))
+}
+
+func samplePost(id, op uint64, files ...string) Post {
+	p := Post{
 		StandalonePost: common.StandalonePost{
 			Post: common.Post{
-				ID:   1,
+				ID:   id,
 				Time: time.Now().Unix(),
 			},
-			OP: 1,
+			OP:    op,
+			Board: "a",
 		},
 	}
-	if err := WriteThread(nil, thread, op); err != nil {
+	for _, sha1 := range files {
+		p.Files = append(p.Files, &common.Image{
+			ImageCommon: common.ImageCommon{SHA1: sha1},
+		})
+	}
+	return p
+}
+
+// Write thread if post is OP or reply otherwise.
+func writeSamplePost(t *testing.T, p Post) {
+	tx, err := BeginTx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID == p.OP {
+		err = InsertThread(tx, p, "")
+	} else {
+		err = InsertPost(tx, p)
+	}
+	EndTx(tx, &err)
+	if err != nil {
 		t.Fatal(err)
 	}
 }
+
+func writeSampleFiles(t *testing.T, hashes ...string) {
+	for _, sha1 := range hashes {
+		if err := WriteImage(nil, common.ImageCommon{SHA1: sha1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func assertThreadCounters(t *testing.T, id uint64, postCtr, imageCtr int) {
+	var p, i int
+	err := db.QueryRow(`SELECT postCtr, imageCtr FROM threads WHERE id = $1`, id).
+		Scan(&p, &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != postCtr || i != imageCtr {
+		t.Fatalf("unexpected counters: %d/%d : %d/%d", postCtr, imageCtr, p, i)
+	}
+}
@@ -14,7 +14,7 @@ type threadScanner struct {
 
 func (t *threadScanner) ScanArgs() []interface{} {
 	return []interface{}{
-		&t.Sticky, &t.Board,
+		&t.Sticky, &t.Locked, &t.Board,
 		&t.PostCtr, &t.ImageCtr,
 		&t.ReplyTime, &t.BumpTime,
 		&t.Subject,
@@ -35,7 +35,7 @@ type postScanner struct {
 }
 
 func (p *postScanner) ScanArgs() []interface{} {
-	return []interface{}{&p.ID, &p.Time, &p.auth, &p.userID, &p.userName, &p.Body, &p.links, &p.commands}
+	return []interface{}{&p.ID, &p.Time, &p.auth, &p.userID, &p.userName, &p.Body, &p.links, &p.commands, &p.Shadow}
 }
 
 func (p *postScanner) Val() common.Post {
@@ -48,16 +48,18 @@ func (p *postScanner) Val() common.Post {
 }
 
 type fileScanner struct {
-	APNG, Audio, Video                sql.NullBool
+	APNG, Audio, Video, WebP          sql.NullBool
+	Restricted                        sql.NullBool
 	FileType, ThumbType, Length, Size sql.NullInt64
 	Name, SHA1, MD5, Title, Artist    sql.NullString
-	Dims                              pq.Int64Array
+	Dims, ThumbSizes                  pq.Int64Array
 }
 
 func (i *fileScanner) ScanArgs() []interface{} {
 	return []interface{}{
 		&i.APNG, &i.Audio, &i.Video, &i.FileType, &i.ThumbType, &i.Dims,
 		&i.Length, &i.Size, &i.MD5, &i.SHA1, &i.Title, &i.Artist,
+		&i.ThumbSizes, &i.WebP, &i.Restricted,
 	}
 }
 
@@ -70,25 +72,50 @@ func (i *fileScanner) Val() *common.Image {
 	for j := range dims {
 		dims[j] = uint16(i.Dims[j])
 	}
+	var thumbSizes []uint16
+	for _, size := range i.ThumbSizes {
+		thumbSizes = append(thumbSizes, uint16(size))
+	}
 
 	return &common.Image{
 		ImageCommon: common.ImageCommon{
-			APNG:      i.APNG.Bool,
-			Audio:     i.Audio.Bool,
-			Video:     i.Video.Bool,
-			FileType:  uint8(i.FileType.Int64),
-			ThumbType: uint8(i.ThumbType.Int64),
-			Length:    uint32(i.Length.Int64),
-			Dims:      dims,
-			Size:      int(i.Size.Int64),
-			MD5:       i.MD5.String,
-			SHA1:      i.SHA1.String,
-			Title:     i.Title.String,
-			Artist:    i.Artist.String,
+			APNG:       i.APNG.Bool,
+			Audio:      i.Audio.Bool,
+			Video:      i.Video.Bool,
+			FileType:   uint8(i.FileType.Int64),
+			ThumbType:  uint8(i.ThumbType.Int64),
+			Length:     uint32(i.Length.Int64),
+			Dims:       dims,
+			ThumbSizes: thumbSizes,
+			WebP:       i.WebP.Bool,
+			Size:       int(i.Size.Int64),
+			MD5:        i.MD5.String,
+			SHA1:       i.SHA1.String,
+			Title:      i.Title.String,
+			Artist:     i.Artist.String,
+			Restricted: i.Restricted.Bool,
 		},
 	}
 }
 
+// Image attached to the post along with per-post file flags.
+type postFileScanner struct {
+	fileScanner
+	spoiler sql.NullBool
+}
+
+func (i *postFileScanner) ScanArgs() []interface{} {
+	return append(i.fileScanner.ScanArgs(), &i.spoiler)
+}
+
+func (i *postFileScanner) Val() *common.Image {
+	img := i.fileScanner.Val()
+	if img != nil {
+		img.Spoiler = i.spoiler.Bool
+	}
+	return img
+}
+
 func scanCatalog(r tableScanner) (b common.Board, err error) {
 	defer r.Close()
 	b = make(common.Board, 0, 32)
@@ -109,7 +136,7 @@ func scanCatalogThread(r rowScanner) (t common.Thread, err error) {
 	var (
 		ts threadScanner
 		ps postScanner
-		fs fileScanner
+		fs postFileScanner
 	)
 	args := make([]interface{}, 0)
 	args = append(args, ts.ScanArgs()...)
@@ -182,9 +209,26 @@ type PostStats struct {
 	Body []byte
 }
 
+// ShadowAll makes shadow banned posts of all IPs visible and marked, for
+// moderators. Otherwise shadow argument of the readers is either empty,
+// hiding such posts, or IP of the viewer, showing only their own posts.
+const ShadowAll = "*"
+
+func shadowArgs(shadow string) (all bool, ip *string) {
+	switch shadow {
+	case "":
+	case ShadowAll:
+		all = true
+	default:
+		ip = &shadow
+	}
+	return
+}
+
 // GetAllBoardCatalog retrieves all OPs for the "/all/" meta-board.
-func GetAllBoardCatalog() (common.Board, error) {
-	r, err := prepared["get_all_catalog"].Query()
+func GetAllBoardCatalog(shadow string) (common.Board, error) {
+	all, ip := shadowArgs(shadow)
+	r, err := prepared["get_all_catalog"].Query(all, ip)
 	if err != nil {
 		return nil, err
 	}
@@ -192,16 +236,20 @@ func GetAllBoardCatalog() (common.Board, error) {
 }
 
 // GetBoardCatalog retrieves all OPs of a single board.
-func GetBoardCatalog(board string) (common.Board, error) {
-	r, err := prepared["get_catalog"].Query(board)
+func GetBoardCatalog(board, shadow string) (common.Board, error) {
+	all, ip := shadowArgs(shadow)
+	r, err := prepared["get_catalog"].Query(board, all, ip)
 	if err != nil {
 		return nil, err
 	}
 	return scanCatalog(r)
 }
 
-// GetThread retrieves public thread data from the database.
-func GetThread(id uint64, lastN int) (t common.Thread, err error) {
+// GetThread retrieves thread data from the database. Shadow banned
+// posts are filtered according to shadow argument, see ShadowAll.
+func GetThread(id uint64, lastN int, shadow string) (t common.Thread, err error) {
+	all, ip := shadowArgs(shadow)
+
 	// Read all data in single transaction.
 	tx, err := StartTransaction()
 	if err != nil {
@@ -214,7 +262,7 @@ func GetThread(id uint64, lastN int) (t common.Thread, err error) {
 	}
 
 	// Get thread info and OP post.
-	t, err = scanThread(tx.Stmt(prepared["get_thread"]).QueryRow(id))
+	t, err = scanThread(tx.Stmt(prepared["get_thread"]).QueryRow(id, all, ip))
 	if err != nil {
 		return
 	}
@@ -229,7 +277,7 @@ func GetThread(id uint64, lastN int) (t common.Thread, err error) {
 	}
 
 	// Get thread posts.
-	r, err := tx.Stmt(prepared["get_thread_posts"]).Query(id, limit)
+	r, err := tx.Stmt(prepared["get_thread_posts"]).Query(id, limit, all, ip)
 	if err != nil {
 		return
 	}
@@ -272,7 +320,7 @@ func GetThread(id uint64, lastN int) (t common.Thread, err error) {
 	defer r2.Close()
 
 	// Fill posts files.
-	var fs fileScanner
+	var fs postFileScanner
 	var pID uint64
 	args = append([]interface{}{&pID}, fs.ScanArgs()...)
 	for r2.Next() {
@@ -304,7 +352,7 @@ func GetPost(id uint64) (p common.StandalonePost, err error) {
 
 	// Get post.
 	var ps postScanner
-	args := append(ps.ScanArgs(), &p.OP, &p.Board)
+	args := append(ps.ScanArgs(), &p.OP, &p.Board, &p.Held)
 	err = tx.Stmt(prepared["get_post"]).QueryRow(id).Scan(args...)
 	if err != nil {
 		return
@@ -319,7 +367,7 @@ func GetPost(id uint64) (p common.StandalonePost, err error) {
 	defer r.Close()
 
 	// Fill post files.
-	var fs fileScanner
+	var fs postFileScanner
 	args = fs.ScanArgs()
 	for r.Next() {
 		err = r.Scan(args...)
@@ -334,8 +382,9 @@ func GetPost(id uint64) (p common.StandalonePost, err error) {
 }
 
 // Retrieves all threads IDs in bump order with stickies first.
-func GetAllThreadsIDs() ([]uint64, error) {
-	r, err := prepared["get_all_thread_ids"].Query()
+func GetAllThreadsIDs(shadow string) ([]uint64, error) {
+	all, ip := shadowArgs(shadow)
+	r, err := prepared["get_all_thread_ids"].Query(all, ip)
 	if err != nil {
 		return nil, err
 	}
@@ -343,8 +392,9 @@ func GetAllThreadsIDs() ([]uint64, error) {
 }
 
 // Retrieves threads IDs on the board.
-func GetThreadIDs(board string) ([]uint64, error) {
-	r, err := prepared["get_board_thread_ids"].Query(board)
+func GetThreadIDs(board, shadow string) ([]uint64, error) {
+	all, ip := shadowArgs(shadow)
+	r, err := prepared["get_board_thread_ids"].Query(board, all, ip)
 	if err != nil {
 		return nil, err
 	}
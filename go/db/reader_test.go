@@ -183,7 +183,7 @@ func testGetAllBoard(t *testing.T) {
 		},
 	}
 
-	board, err := GetAllBoardCatalog()
+	board, err := GetAllBoardCatalog("")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -246,7 +246,7 @@ func testGetBoard(t *testing.T) {
 		t.Run(c.name, func(t *testing.T) {
 			t.Parallel()
 
-			board, err := GetBoardCatalog(c.id)
+			board, err := GetBoardCatalog(c.id, "")
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -335,7 +335,7 @@ func testGetThread(t *testing.T) {
 		t.Run(c.name, func(t *testing.T) {
 			t.Parallel()
 
-			thread, err := GetThread(c.id, c.lastN)
+			thread, err := GetThread(c.id, c.lastN, "")
 			if err != c.err {
 				UnexpectedError(t, err)
 			}
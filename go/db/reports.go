@@ -0,0 +1,66 @@
+package db
+
+import (
+	"time"
+
+	"github.com/cutechan/cutechan/go/common"
+)
+
+// InsertReport records user report on a post. Returns false if this IP
+// already reported the post.
+func InsertReport(
+	id uint64,
+	ip string,
+	reason common.ReportReason,
+	text string,
+) (inserted bool, err error) {
+	res, err := prepared["insert_report"].Exec(id, ip, reason, text)
+	if err != nil {
+		return
+	}
+	n, err := res.RowsAffected()
+	inserted = n != 0
+	return
+}
+
+// GetReports retrieves pending reports on the board grouped by post.
+func GetReports(board string) (posts []common.ReportedPost, err error) {
+	posts = make([]common.ReportedPost, 0)
+	rs, err := prepared["get_reports"].Query(board)
+	if err != nil {
+		return
+	}
+	defer rs.Close()
+	for rs.Next() {
+		var (
+			p       common.ReportedPost
+			rep     common.Report
+			created time.Time
+		)
+		err = rs.Scan(&p.ID, &p.OP, &p.Body, &rep.Reason, &rep.Text, &created)
+		if err != nil {
+			return
+		}
+		rep.Created = created.Unix()
+		if n := len(posts); n != 0 && posts[n-1].ID == p.ID {
+			posts[n-1].Reports = append(posts[n-1].Reports, rep)
+			continue
+		}
+		p.Reports = []common.Report{rep}
+		posts = append(posts, p)
+	}
+	err = rs.Err()
+	return
+}
+
+// DismissReports removes all reports on the post leaving it intact.
+// Returns sql.ErrNoRows if there are no reports.
+func DismissReports(id uint64, by string) error {
+	return execAffected("dismiss_reports", id, by)
+}
+
+// ClearReports removes all reports on the post after moderator acted on
+// it. The action itself is logged separately.
+func ClearReports(id uint64) error {
+	return execPrepared("clear_reports", id)
+}
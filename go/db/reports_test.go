@@ -0,0 +1,58 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/cutechan/cutechan/go/common"
+	. "github.com/cutechan/cutechan/go/test"
+)
+
+func TestReports(t *testing.T) {
+	assertTableClear(t, "boards")
+	writeSampleBoard(t)
+	writeSampleThread(t)
+	writeSamplePost(t, samplePost(2, 1))
+
+	cases := [...]struct {
+		id       uint64
+		ip       string
+		reason   common.ReportReason
+		inserted bool
+	}{
+		{2, "::1", common.ReportSpam, true},
+		{2, "::1", common.ReportAbuse, false},
+		{2, "::2", common.ReportOther, true},
+		{1, "::1", common.ReportOfftopic, true},
+	}
+	for _, c := range cases {
+		inserted, err := InsertReport(c.id, c.ip, c.reason, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inserted != c.inserted {
+			t.Fatalf("%d from %s: expected inserted=%v", c.id, c.ip, c.inserted)
+		}
+	}
+
+	posts, err := GetReports("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, len(posts), 2)
+	AssertDeepEquals(t, posts[0].ID, uint64(1))
+	AssertDeepEquals(t, len(posts[1].Reports), 2)
+	AssertDeepEquals(t, posts[1].Reports[0].Reason, common.ReportSpam)
+
+	if err := DismissReports(2, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DismissReports(2, "admin"); err != sql.ErrNoRows {
+		UnexpectedError(t, err)
+	}
+	posts, err = GetReports("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, len(posts), 1)
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cutechan/cutechan/go/auth"
+	. "github.com/cutechan/cutechan/go/test"
+)
+
+func TestShadowPostCounters(t *testing.T) {
+	assertTableClear(t, "boards", "images")
+	writeSampleBoard(t)
+	writeSampleFiles(t, sampleSHA1, sampleSHA1b)
+	writeSamplePost(t, samplePost(1, 1))
+	writeSamplePost(t, samplePost(2, 1, sampleSHA1))
+	for _, id := range [...]uint64{3, 4} {
+		p := samplePost(id, 1, sampleSHA1b)
+		p.Shadow = true
+		writeSamplePost(t, p)
+	}
+	assertThreadCounters(t, 1, 2, 1)
+
+	if err := DeleteImage(3, 0, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	assertThreadCounters(t, 1, 2, 1)
+	if err := DeletePost(4, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	assertThreadCounters(t, 1, 2, 1)
+	if err := DeletePost(2, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	assertThreadCounters(t, 1, 1, 0)
+}
+
+func TestBanUpdate(t *testing.T) {
+	assertTableClear(t, "boards", "bans")
+	writeSampleBoard(t)
+	writeSampleThread(t)
+	p := samplePost(2, 1)
+	p.IP = "10.0.0.1"
+	writeSamplePost(t, p)
+
+	expires := time.Now().Add(time.Hour)
+	for _, typ := range [...]auth.BanType{auth.ShadowBan, auth.RegularBan} {
+		_, err := Ban("a", "spam", "admin", expires, typ, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	bans, err := GetBans(nil, []string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, len(bans), 1)
+	AssertDeepEquals(t, bans[0].Type, auth.RegularBan)
+}
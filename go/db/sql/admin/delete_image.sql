@@ -0,0 +1,15 @@
+WITH f AS (
+  SELECT id FROM post_files WHERE post_id = $1 ORDER BY id OFFSET $2 LIMIT 1
+),
+del AS (
+  DELETE FROM post_files pf USING f WHERE pf.id = f.id RETURNING pf.post_id
+)
+
+UPDATE threads t SET
+  -- Shadow banned and held posts are not counted.
+  imageCtr = imageCtr - CASE WHEN p.shadow OR p.held THEN 0 ELSE 1 END,
+  replyTime = floor(extract(epoch from now()))
+FROM del, posts p
+WHERE p.id = del.post_id AND t.id = p.op
+
+RETURNING log_moderation(3::smallint, p.board, p.id, $3)
@@ -4,6 +4,7 @@ WITH files AS (
 
 DELETE FROM posts USING files WHERE id = $1
 
+-- Shadow banned and held posts are not counted.
 RETURNING
   log_moderation(2::smallint, board, id, $2),
-  bump_thread(op, false, true, false, files.cnt)
+  bump_thread(op, false, NOT (shadow OR held), false, files.cnt)
@@ -0,0 +1,8 @@
+-- Account can only have one ban per board, so the newest post ban takes
+-- it over. Older bans of the same account without IP are replaced.
+WITH d AS (
+  DELETE FROM bans WHERE board = $1 AND account = $2 AND ip IS NULL
+)
+UPDATE bans SET account = NULL
+WHERE board = $1 AND account = $2
+  AND ip IS DISTINCT FROM NULLIF($3, '')::inet
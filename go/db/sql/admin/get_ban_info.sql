@@ -1,4 +1,6 @@
-select ip, board, forPost, reason, by, expires
+select board, ip, account, forPost, reason, by, expires
   from bans
-  where ip = $1 and board = $2 and expires >= now()
+  where ($1::inet <<= ip or account = nullif($3, ''))
+    and board = $2 and expires >= now() and type = 0
+  order by masklen(ip) desc nulls last
   limit 1
@@ -0,0 +1,3 @@
+-- Posts can only link to older posts.
+SELECT id, links FROM posts
+WHERE id > $2 AND links && $1
@@ -1,3 +1,4 @@
-SELECT board FROM staff
-WHERE account = $1 AND position = 'owners'
-ORDER BY board
+SELECT s.board FROM staff s
+JOIN boards b ON b.id = s.board
+WHERE s.account = $1 AND s.position = 'owners' AND b.deleted IS NULL
+ORDER BY s.board
@@ -0,0 +1,6 @@
+SELECT id, op, board FROM posts
+WHERE ip = (SELECT ip FROM posts WHERE id = $1)
+  AND ($2::text = 'all' OR board = $2)
+  AND time >= $3
+ORDER BY id
+FOR UPDATE
@@ -5,3 +5,5 @@ select id from posts
         where id = $1 and board = $2
     )
     and board = $2
+  order by id desc
+  limit 100
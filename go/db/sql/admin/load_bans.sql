@@ -1,2 +1,2 @@
-select ip, board from bans
+select ip, account, board, type from bans
   where expires >= now()
@@ -0,0 +1 @@
+SELECT log_moderation(11::smallint, 'all', 0::bigint, $1)
@@ -0,0 +1,7 @@
+UPDATE threads SET
+  board = $3,
+  replyTime = floor(extract(epoch from now()))
+WHERE id = $1 AND board = $2
+RETURNING
+  log_moderation(14::smallint, $2, id, $4::varchar(20)),
+  log_moderation(14::smallint, $3, id, $4::varchar(20))
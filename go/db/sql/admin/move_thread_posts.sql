@@ -0,0 +1 @@
+UPDATE posts SET board = $2 WHERE op = $1
@@ -0,0 +1,5 @@
+INSERT INTO bans (board, ip, forPost, by, expires, reason)
+VALUES           ($1,    $2, $3,      $4, $5,      $6)
+ON CONFLICT (ip, board) DO UPDATE
+  SET forPost = $3, by = $4, expires = $5, reason = $6, type = 0
+RETURNING pg_notify('bans_updated', '')
@@ -0,0 +1,8 @@
+WITH files AS (
+  SELECT count(*) AS cnt FROM post_files WHERE post_id = $1
+)
+
+DELETE FROM posts USING files WHERE id = $1
+
+-- Shadow banned and held posts are not counted.
+RETURNING bump_thread(op, false, NOT (shadow OR held), false, files.cnt)
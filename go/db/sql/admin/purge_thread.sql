@@ -0,0 +1 @@
+DELETE FROM threads WHERE id = $1
@@ -0,0 +1,23 @@
+-- Shadow banned and held posts are not counted, except OP.
+UPDATE threads SET
+  replyTime = floor(extract(epoch from now())),
+  postCtr = (
+    SELECT count(*) FROM posts WHERE op = $1 AND (NOT (shadow OR held) OR id = $1)
+  ),
+  imageCtr = (
+    SELECT count(*)
+    FROM post_files pf
+    JOIN posts p ON p.id = pf.post_id
+    WHERE p.op = $1 AND (NOT (p.shadow OR p.held) OR p.id = $1)
+  ),
+  -- Same bump limit as in bump_thread.
+  bumpTime = (
+    SELECT max(time)
+    FROM (
+      SELECT time FROM posts
+      WHERE op = $1 AND (NOT (shadow OR held) OR id = $1)
+      ORDER BY id
+      LIMIT 501
+    ) b
+  )
+WHERE id = $1
@@ -0,0 +1,8 @@
+update threads
+  set locked = $2
+  where id = $1
+  returning
+    bump_thread($1, false, false, false, 0),
+    log_moderation(
+      (case when $2 then 12 else 13 end)::smallint, board, id, $3::varchar(20)
+    )
@@ -0,0 +1 @@
+UPDATE posts SET links = $2 WHERE id = $1
@@ -0,0 +1,3 @@
+INSERT INTO threads (board, id, postCtr, imageCtr, replyTime, bumpTime, subject)
+VALUES              ($2,    $1, 0,       0,        0,         0,        $3)
+RETURNING log_moderation(15::smallint, board, id, $4::varchar(20))
@@ -0,0 +1,2 @@
+UPDATE posts SET op = $1, board = $2
+WHERE op = $3 AND id = ANY($4)
@@ -0,0 +1,11 @@
+WITH f AS (
+  SELECT id FROM post_files WHERE post_id = $1 ORDER BY id OFFSET $2 LIMIT 1
+)
+
+UPDATE post_files pf SET spoiler = true
+FROM f, posts p
+WHERE pf.id = f.id AND p.id = $1
+
+RETURNING
+  log_moderation(4::smallint, p.board, p.id, $3),
+  bump_thread(p.op, false, false, false, 0)
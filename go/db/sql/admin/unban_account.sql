@@ -0,0 +1,4 @@
+DELETE FROM bans WHERE board = $1 AND account = $2
+RETURNING
+  pg_notify('bans_updated', ''),
+  log_moderation(26::smallint, $1, 0, $3)
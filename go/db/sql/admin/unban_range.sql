@@ -0,0 +1,4 @@
+DELETE FROM bans WHERE board = $1 AND ip = $2::inet
+RETURNING
+  pg_notify('bans_updated', ''),
+  log_moderation(18::smallint, $1, 0, $3)
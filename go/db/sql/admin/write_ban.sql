@@ -1,4 +1,8 @@
-INSERT INTO bans (board, ip, forPost, by, expires, reason)
-VALUES           ($1,    $2, $3,      $4, $5,      $6)
-ON CONFLICT DO NOTHING
-RETURNING log_moderation(0::smallint, $1, $3, $4)
+INSERT INTO bans (board, ip,                   forPost, by, expires, reason, type, account)
+VALUES           ($1,    NULLIF($2, '')::inet, $3,      $4, $5,      $6,     $7,   NULLIF($8, ''))
+ON CONFLICT (ip, board) DO UPDATE
+  SET forPost = $3, by = $4, expires = $5, reason = $6, type = $7,
+    account = coalesce(NULLIF($8, ''), bans.account)
+RETURNING log_moderation(
+  (case when $7 = 1 then 20 else 0 end)::smallint, $1, $3, $4
+)
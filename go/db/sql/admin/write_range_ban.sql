@@ -0,0 +1,7 @@
+INSERT INTO bans (board, ip, forPost, by, expires, reason, type)
+VALUES           ($1,    $2, 0,       $3, $4,      $5,     $6)
+ON CONFLICT (ip, board) DO UPDATE
+  SET by = $3, expires = $4, reason = $5, type = $6
+RETURNING
+  pg_notify('bans_updated', ''),
+  log_moderation(17::smallint, $1, 0, $3)
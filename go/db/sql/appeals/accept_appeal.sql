@@ -0,0 +1,3 @@
+UPDATE appeals
+  SET status = 1, by = $2
+  WHERE id = $1 AND status = 0
@@ -0,0 +1,4 @@
+SELECT id, board, coalesce(ip::text, ''), coalesce(account, ''), forPost,
+       text, status, response, created
+FROM appeals
+WHERE id = $1
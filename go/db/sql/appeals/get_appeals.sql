@@ -0,0 +1,6 @@
+SELECT a.id, a.board, coalesce(a.ip::text, ''), coalesce(a.account, ''),
+       a.forPost, b.reason, a.text, a.status, a.response, a.created
+FROM appeals a
+JOIN bans b ON b.board = a.board AND (b.ip = a.ip OR b.account = a.account)
+WHERE a.board = $1 AND a.status = 0
+ORDER BY a.created
@@ -0,0 +1,12 @@
+SELECT b.board, b.forPost, b.reason, b.expires,
+       a.id, a.text, a.status, a.response, a.created
+FROM bans b
+LEFT JOIN LATERAL (
+  SELECT * FROM appeals a
+  WHERE a.board = b.board AND (a.ip = b.ip OR a.account = b.account)
+  ORDER BY a.created DESC
+  LIMIT 1
+) a ON true
+WHERE ($1::inet <<= b.ip OR b.account = NULLIF($2, ''))
+  AND b.expires >= now() AND b.type = 0
+ORDER BY b.expires DESC
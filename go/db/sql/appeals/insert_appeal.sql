@@ -0,0 +1,3 @@
+INSERT INTO appeals (board, ip,                   account,         forPost, text)
+VALUES              ($1,    NULLIF($2, '')::inet, NULLIF($3, ''), $4,      $5)
+ON CONFLICT DO NOTHING
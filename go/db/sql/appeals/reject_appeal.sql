@@ -0,0 +1,4 @@
+UPDATE appeals
+  SET status = 2, response = $2, by = $3
+  WHERE id = $1 AND status = 0
+  RETURNING log_moderation(19::smallint, board, forPost, $3)
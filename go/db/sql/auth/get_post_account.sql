@@ -0,0 +1,2 @@
+select name from posts
+  where id = $1
@@ -0,0 +1 @@
+DELETE FROM automod_rules WHERE board = $1
@@ -0,0 +1 @@
+SELECT count(*) FROM posts WHERE ip = $1 AND time >= $2
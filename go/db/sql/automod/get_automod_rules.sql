@@ -0,0 +1,4 @@
+SELECT board, condition, pattern, action, message, dry_run
+FROM automod_rules
+WHERE board = ANY($1)
+ORDER BY board, position
@@ -0,0 +1 @@
+SELECT EXISTS (SELECT 1 FROM posts WHERE ip = $1)
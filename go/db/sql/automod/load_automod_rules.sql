@@ -0,0 +1,3 @@
+SELECT board, condition, pattern, action, message, dry_run
+FROM automod_rules
+ORDER BY board, position
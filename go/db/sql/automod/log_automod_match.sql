@@ -0,0 +1,5 @@
+WITH m AS (
+  INSERT INTO automod_matches (board, post, position, action, dry_run)
+  VALUES                      ($1,    $2,   $3,       $4,     $5)
+)
+SELECT log_moderation(23::smallint, $1, $2, 'automod')
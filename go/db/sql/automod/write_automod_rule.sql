@@ -0,0 +1,2 @@
+INSERT INTO automod_rules (board, position, condition, pattern, action, message, dry_run)
+VALUES                    ($1,    $2,       $3,        $4,      $5,     $6,      $7)
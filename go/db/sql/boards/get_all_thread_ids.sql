@@ -1,5 +1,9 @@
 select t.id from threads as t
   inner join boards as b
     on b.id = t.board
-  where NOT b.modOnly
+  inner join posts as p
+    on p.id = t.id
+  where NOT b.modOnly and b.deleted is null
+    and not p.held
+    and (not p.shadow or $1::boolean or p.ip = $2::inet)
   order by bumpTime desc
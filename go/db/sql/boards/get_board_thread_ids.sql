@@ -1,5 +1,9 @@
-select id from threads
-  where board = $1
+select t.id from threads as t
+  inner join posts as p
+    on p.id = t.id
+  where t.board = $1
+    and not p.held
+    and (not p.shadow or $2::boolean or p.ip = $3::inet)
   order by
     sticky desc,
     bumpTime desc
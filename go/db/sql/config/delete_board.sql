@@ -1,3 +1,6 @@
-delete from boards
-  where id = $1
-  returning pg_notify('board_updated', $1)
+UPDATE boards
+SET deleted = now()
+WHERE id = $1 AND deleted IS NULL
+RETURNING
+  pg_notify('board_updated', $1),
+  log_moderation(9::smallint, $1, 0::bigint, $2)
@@ -1 +1 @@
-SELECT id, modOnly, settings FROM boards WHERE id = $1
+SELECT id, modOnly, settings FROM boards WHERE id = $1 AND deleted IS NULL
@@ -1 +1 @@
-SELECT id, modOnly, settings FROM boards
+SELECT id, modOnly, settings FROM boards WHERE deleted IS NULL
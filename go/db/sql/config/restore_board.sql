@@ -0,0 +1,11 @@
+UPDATE boards
+SET deleted = NULL
+WHERE id = $1 AND deleted IS NOT NULL AND (
+  $2 = 'admin' OR EXISTS (
+    SELECT 1 FROM staff
+    WHERE board = $1 AND account = $2 AND position = 'owners'
+  )
+)
+RETURNING
+  pg_notify('board_updated', $1),
+  log_moderation(10::smallint, $1, 0::bigint, $2)
@@ -0,0 +1,8 @@
+UPDATE images
+SET restricted = image_restricted(sha1)
+WHERE sha1 IN (
+  SELECT pf.file_hash
+  FROM post_files pf
+  JOIN posts p ON p.id = pf.post_id
+  WHERE p.board = $1
+)
@@ -0,0 +1,11 @@
+CREATE OR REPLACE FUNCTION image_restricted(
+  sha1 char(40)
+) RETURNS bool AS $$
+
+  SELECT coalesce(bool_and(b.modOnly), false)
+  FROM post_files pf
+  JOIN posts p ON p.id = pf.post_id
+  JOIN boards b ON b.id = p.board
+  WHERE pf.file_hash = image_restricted.sha1;
+
+$$ LANGUAGE SQL;
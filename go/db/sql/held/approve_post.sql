@@ -0,0 +1,3 @@
+UPDATE posts SET held = false
+WHERE id = $1 AND held
+RETURNING op, board, shadow, (SELECT count(*) FROM post_files WHERE post_id = $1)
@@ -0,0 +1 @@
+SELECT bump_thread($1, true, false, true, $2)
@@ -0,0 +1,3 @@
+SELECT count(*) FROM posts
+WHERE board = $1 AND NOT held
+  AND (ip = $2::inet OR account = NULLIF($3, ''))
@@ -0,0 +1,4 @@
+SELECT id FROM posts
+WHERE board = $1 AND held
+ORDER BY id
+LIMIT 100
@@ -0,0 +1,2 @@
+SELECT op FROM posts WHERE id = $1 AND held
+FOR UPDATE
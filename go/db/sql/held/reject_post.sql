@@ -0,0 +1,3 @@
+DELETE FROM posts
+WHERE id = $1 AND held
+RETURNING log_moderation(25::smallint, board, id, $2)
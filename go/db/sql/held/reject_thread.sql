@@ -0,0 +1,4 @@
+DELETE FROM threads t
+USING posts p
+WHERE t.id = $1 AND p.id = $1 AND p.held
+RETURNING log_moderation(25::smallint, t.board, t.id, $2)
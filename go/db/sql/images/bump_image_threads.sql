@@ -0,0 +1,9 @@
+SELECT
+  bump_thread(t.op, false, false, false, 0),
+  pg_notify('thread_updated', t.op || ' ' || t.board)
+FROM (
+  SELECT DISTINCT p.op, p.board
+  FROM post_files pf
+  JOIN posts p ON p.id = pf.post_id
+  WHERE pf.file_hash = $1
+) t
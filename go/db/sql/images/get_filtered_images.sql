@@ -0,0 +1,13 @@
+SELECT * FROM images i
+WHERE i.sha1 > $1
+  AND (cardinality($2::smallint[]) = 0 OR i.fileType = ANY($2))
+  AND EXISTS (
+    SELECT 1
+    FROM post_files pf
+    JOIN posts p ON p.id = pf.post_id
+    WHERE pf.file_hash = i.sha1
+      AND ($3 = '' OR p.board = $3)
+      AND p.time >= $4 AND p.time < $5
+  )
+ORDER BY i.sha1
+LIMIT $6
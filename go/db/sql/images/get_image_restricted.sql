@@ -0,0 +1,2 @@
+SELECT restricted FROM images
+WHERE sha1 = $1
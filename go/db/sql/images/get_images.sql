@@ -0,0 +1,4 @@
+SELECT * FROM images
+WHERE sha1 > $1
+ORDER BY sha1
+LIMIT $2
@@ -0,0 +1,4 @@
+SELECT DISTINCT pf.file_hash
+FROM post_files pf
+JOIN posts p ON p.id = pf.post_id
+WHERE p.id = ANY($1) OR p.op = ANY($1)
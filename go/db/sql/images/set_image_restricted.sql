@@ -0,0 +1,4 @@
+UPDATE images
+SET restricted = image_restricted($1)
+WHERE sha1 = $1
+RETURNING restricted
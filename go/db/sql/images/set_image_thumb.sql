@@ -0,0 +1,3 @@
+UPDATE images
+SET thumbType = $2, dims = $3, thumbSizes = $4, webp = $5
+WHERE sha1 = $1
@@ -0,0 +1,3 @@
+UPDATE images
+SET thumbSizes = $2, webp = $3
+WHERE sha1 = $1
@@ -0,0 +1,5 @@
+UPDATE images
+SET restricted = image_restricted(sha1)
+WHERE sha1 = ANY($1)
+  -- Unused files keep their state until removed by upkeep.
+  AND EXISTS (SELECT 1 FROM post_files WHERE file_hash = images.sha1)
@@ -24,12 +24,16 @@ CREATE INDEX sessions_token ON sessions (token);
 
 create table bans (
   board text not null,
-  ip inet not null,
+  ip inet,
+  account varchar(20) references accounts on delete cascade,
   forPost bigint default 0,
   by varchar(20) not null,
   reason text not null,
   expires timestamp not null,
-  primary key (ip, board)
+  type smallint not null default 0,
+  unique (ip, board),
+  unique (account, board),
+  check (ip is not null or account is not null)
 );
 
 create table mod_log (
@@ -54,7 +58,10 @@ create table images (
   MD5 char(22) not null,
   SHA1 char(40) primary key,
   Title varchar(300) not null,
-  Artist varchar(100) not null
+  Artist varchar(100) not null,
+  thumbSizes smallint[] not null default '{}',
+  webp boolean not null default false,
+  restricted boolean not null default false
 );
 
 create table image_tokens (
@@ -66,7 +73,8 @@ create table image_tokens (
 CREATE TABLE boards (
   id text PRIMARY KEY,
   modOnly boolean NOT NULL,
-  settings jsonb NOT NULL
+  settings jsonb NOT NULL,
+  deleted timestamp
 );
 INSERT INTO boards VALUES ('all', FALSE, '{"title": "Aggregator metaboard"}');
 
@@ -96,7 +104,8 @@ create table threads (
   imageCtr bigint not null,
   bumpTime bigint not null,
   replyTime bigint not null,
-  subject varchar(100) not null
+  subject varchar(100) not null,
+  locked boolean not null default false
 );
 create index threads_board on threads (board);
 create index bumpTime on threads (bumpTime);
@@ -120,13 +129,18 @@ create table posts (
   password bytea,
   ip inet,
   links bigint[][2],
-  commands json[]
+  commands json[],
+  shadow boolean not null default false,
+  held boolean not null default false,
+  account varchar(20)
 );
 create index op on posts (op);
 create index image on posts (SHA1);
 create index editing on posts (editing);
 create index ip on posts (ip);
 create index posts_op_time on posts (op, time);
+CREATE INDEX posts_held ON posts (board) WHERE held;
+CREATE INDEX posts_account ON posts (account);
 
 create table news (
   id bigserial primary key,
@@ -145,7 +159,8 @@ create table post_tokens (
 CREATE TABLE post_files (
   post_id bigint REFERENCES posts ON DELETE CASCADE,
   file_hash char(40) REFERENCES images,
-  id bigserial PRIMARY KEY
+  id bigserial PRIMARY KEY,
+  spoiler boolean NOT NULL DEFAULT false
 );
 CREATE INDEX post_files_post_id ON post_files (post_id);
 CREATE INDEX post_files_file_hash ON post_files (file_hash);
@@ -168,3 +183,63 @@ CREATE TABLE idol_previews (
   id uuid PRIMARY KEY,
   image_id char(40) UNIQUE NOT NULL REFERENCES images
 );
+
+CREATE TABLE reports (
+  id bigserial PRIMARY KEY,
+  post_id bigint NOT NULL REFERENCES posts ON DELETE CASCADE,
+  ip inet NOT NULL,
+  reason smallint NOT NULL,
+  text varchar(200) NOT NULL DEFAULT '',
+  created timestamp NOT NULL DEFAULT (now() at time zone 'utc'),
+  UNIQUE (post_id, ip)
+);
+
+CREATE TABLE appeals (
+  id bigserial PRIMARY KEY,
+  board text NOT NULL,
+  ip inet,
+  account varchar(20),
+  forPost bigint NOT NULL DEFAULT 0,
+  text varchar(1000) NOT NULL,
+  status smallint NOT NULL DEFAULT 0,
+  response varchar(200) NOT NULL DEFAULT '',
+  by varchar(20),
+  created timestamp NOT NULL DEFAULT (now() at time zone 'utc'),
+  UNIQUE (ip, board),
+  UNIQUE (account, board),
+  CHECK (ip IS NOT NULL OR account IS NOT NULL)
+);
+
+CREATE TABLE staff_notes (
+  id bigserial PRIMARY KEY,
+  board text NOT NULL,
+  post bigint REFERENCES posts ON DELETE SET NULL,
+  ip_hash char(64),
+  text varchar(500) NOT NULL,
+  by varchar(20) NOT NULL,
+  created timestamp NOT NULL DEFAULT (now() at time zone 'utc')
+);
+CREATE INDEX staff_notes_board ON staff_notes (board);
+CREATE INDEX staff_notes_post ON staff_notes (post);
+CREATE INDEX staff_notes_ip_hash ON staff_notes (ip_hash);
+
+CREATE TABLE automod_rules (
+  board text NOT NULL REFERENCES boards ON DELETE CASCADE,
+  position smallint NOT NULL,
+  condition smallint NOT NULL,
+  pattern varchar(1000) NOT NULL,
+  action smallint NOT NULL,
+  message varchar(200) NOT NULL,
+  dry_run boolean NOT NULL,
+  PRIMARY KEY (board, position)
+);
+
+CREATE TABLE automod_matches (
+  board text NOT NULL,
+  post bigint NOT NULL,
+  position smallint NOT NULL,
+  action smallint NOT NULL,
+  dry_run boolean NOT NULL,
+  created timestamp NOT NULL DEFAULT (now() at time zone 'utc')
+);
+CREATE INDEX automod_matches_board ON automod_matches (board);
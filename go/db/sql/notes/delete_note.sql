@@ -0,0 +1,2 @@
+DELETE FROM staff_notes
+WHERE id = $1
@@ -0,0 +1,2 @@
+SELECT board FROM staff_notes
+WHERE id = $1
@@ -0,0 +1,4 @@
+SELECT id, board, coalesce(post, 0), ip_hash IS NOT NULL, text, by, created
+FROM staff_notes
+WHERE board = $1 AND (post = $2 OR ip_hash = NULLIF($3, ''))
+ORDER BY created DESC
@@ -0,0 +1,3 @@
+INSERT INTO staff_notes (board, post, ip_hash,        text, by)
+VALUES                  ($1,    $2,   NULLIF($3, ''), $4,   $5)
+RETURNING id, created
@@ -0,0 +1,12 @@
+SELECT id, board, coalesce(post, 0), ip_hash IS NOT NULL, text, by, created
+FROM staff_notes
+WHERE board = $1
+  AND (
+    $2 = ''
+    OR strpos(lower(text), lower($2)) > 0
+    OR by = $2
+    OR post::text = $2
+    OR ip_hash = NULLIF($3, '')
+  )
+ORDER BY created DESC
+LIMIT 200
@@ -1,4 +1,4 @@
-SELECT p.id, p.time, p.auth, a.id, a.name, p.body, p.links, p.commands, p.op, p.board
+SELECT p.id, p.time, p.auth, a.id, a.name, p.body, p.links, p.commands, p.shadow, p.op, p.board, p.held
 FROM posts p
 LEFT JOIN accounts a ON a.id = p.name
 WHERE p.id = $1
@@ -0,0 +1 @@
+UPDATE posts SET account = $2 WHERE id = $1
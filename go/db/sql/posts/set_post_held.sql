@@ -0,0 +1 @@
+UPDATE posts SET held = true WHERE id = $1
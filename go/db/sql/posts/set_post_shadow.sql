@@ -0,0 +1 @@
+UPDATE posts SET shadow = true WHERE id = $1
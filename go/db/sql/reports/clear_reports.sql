@@ -0,0 +1 @@
+DELETE FROM reports WHERE post_id = $1
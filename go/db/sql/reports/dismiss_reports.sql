@@ -0,0 +1,7 @@
+WITH d AS (
+  DELETE FROM reports WHERE post_id = $1 RETURNING post_id
+)
+
+SELECT log_moderation(16::smallint, p.board, p.id, $2::varchar(20))
+FROM posts p
+WHERE p.id = $1 AND EXISTS (SELECT 1 FROM d)
@@ -0,0 +1,5 @@
+SELECT r.post_id, p.op, p.body, r.reason, r.text, r.created
+FROM reports r
+JOIN posts p ON p.id = r.post_id
+WHERE p.board = $1
+ORDER BY r.post_id, r.created
@@ -0,0 +1,3 @@
+INSERT INTO reports (post_id, ip, reason, text)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT DO NOTHING
@@ -1,4 +1,4 @@
-SELECT pf.post_id, i.*
+SELECT pf.post_id, i.*, pf.spoiler
 FROM post_files pf
 JOIN images i ON i.sha1 = pf.file_hash
 WHERE pf.post_id = ANY($1)
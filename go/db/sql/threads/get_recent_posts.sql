@@ -1,4 +1,6 @@
 select id, time from posts
   where op = $1
     and time > floor(extract(epoch from now())) - 900
+    and not shadow
+    and not held
   order by id asc
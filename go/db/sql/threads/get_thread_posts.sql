@@ -1,8 +1,10 @@
 WITH t AS (
-  SELECT p.id AS post_id, p.time, p.auth, a.id, a.name, p.body, p.links, p.commands
+  SELECT p.id AS post_id, p.time, p.auth, a.id, a.name, p.body, p.links, p.commands,
+    p.shadow AND $3::boolean
   FROM posts p
   LEFT JOIN accounts a ON a.id = p.name
-  WHERE op = $1 AND p.id != $1
+  WHERE op = $1 AND p.id != $1 AND NOT p.held
+    AND (NOT p.shadow OR $3::boolean OR p.ip = $4::inet)
   ORDER BY p.id DESC
   LIMIT $2
 )
@@ -0,0 +1,2 @@
+select true from threads
+  where id = $1 and board = $2
@@ -1,2 +1,2 @@
-select true from threads
+select locked from threads
   where id = $1 and board = $2
@@ -0,0 +1,5 @@
+delete from appeals a
+  where not exists (
+    select 1 from bans b
+    where b.board = a.board and (b.ip = a.ip or b.account = a.account)
+  )
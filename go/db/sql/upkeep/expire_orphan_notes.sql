@@ -0,0 +1,2 @@
+delete from staff_notes
+  where post is null and ip_hash is null
@@ -0,0 +1,5 @@
+SELECT DISTINCT pf.file_hash
+FROM post_files pf
+JOIN posts p ON p.id = pf.post_id
+JOIN boards b ON b.id = p.board
+WHERE b.deleted < now() + '-7 days'
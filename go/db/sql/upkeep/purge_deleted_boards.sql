@@ -0,0 +1,2 @@
+delete from boards
+  where deleted < now() + '-7 days'
@@ -1,2 +1,4 @@
+-- Shadow banned posts are shown to their author by IP.
 UPDATE posts SET ip = NULL
 WHERE time < EXTRACT(EPOCH FROM now() - INTERVAL '7 days') and ip IS NOT NULL
+  AND NOT shadow
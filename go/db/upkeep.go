@@ -6,7 +6,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cutechan/cutechan/go/common"
 	"github.com/cutechan/cutechan/go/file"
+
+	"github.com/lib/pq"
 )
 
 // Run database clean up tasks at server start and regular intervals.
@@ -31,12 +34,35 @@ func runCleanupTasks() {
 }
 
 func runFiveMinuteTasks() {
-	runPrepared("expire_post_tokens", "expire_image_tokens", "expire_bans")
+	runPrepared(
+		"expire_post_tokens", "expire_image_tokens", "expire_bans",
+		"expire_appeals", "expire_orphan_notes",
+	)
 	logError("file cleanup", deleteUnusedFiles())
 }
 
 func runHourTasks() {
 	runPrepared("expire_user_sessions", "remove_identity_info")
+	logError("purge deleted boards", purgeDeletedBoards())
+}
+
+// Boards are purged some time after deletion so they could be restored.
+// Files shared with other boards might change their restricted state.
+func purgeDeletedBoards() (err error) {
+	tx, err := BeginTx()
+	if err != nil {
+		return
+	}
+	defer EndTx(tx, &err)
+
+	hashes, err := queryFileHashes(tx, "get_purged_board_files")
+	if err != nil {
+		return
+	}
+	if err = execPreparedTx(tx, "purge_deleted_boards"); err != nil {
+		return
+	}
+	return updateRestricted(tx, hashes)
 }
 
 func runPrepared(ids ...string) {
@@ -55,14 +81,21 @@ func deleteUnusedFiles() (err error) {
 
 	for r.Next() {
 		var (
-			sha1                string
-			fileType, thumbType uint8
+			img        common.ImageCommon
+			thumbSizes pq.Int64Array
 		)
-		err = r.Scan(&sha1, &fileType, &thumbType)
+		err = r.Scan(&img.SHA1, &img.FileType, &img.ThumbType, &thumbSizes, &img.WebP)
+		if err != nil {
+			return
+		}
+		for _, size := range thumbSizes {
+			img.ThumbSizes = append(img.ThumbSizes, uint16(size))
+		}
+		err = file.Backend.Delete(img.SHA1, img.FileType, img.ThumbType)
 		if err != nil {
 			return
 		}
-		err = file.Backend.Delete(sha1, fileType, thumbType)
+		err = file.Backend.DeleteThumbs(img.SHA1, file.Variants(&img))
 		if err != nil {
 			return
 		}
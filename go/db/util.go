@@ -119,6 +119,26 @@ func execPrepared(id string, args ...interface{}) error {
 	return err
 }
 
+// Same as execPrepared, but returns sql.ErrNoRows if nothing was
+// affected.
+func execAffected(id string, args ...interface{}) error {
+	return execAffectedTx(nil, id, args...)
+}
+
+// Same as execAffected but optionally inside transaction.
+func execAffectedTx(tx *sql.Tx, id string, args ...interface{}) error {
+	res, err := getStatement(tx, id).Exec(args...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 func execPreparedTx(tx *sql.Tx, id string, args ...interface{}) error {
 	stmt, ok := prepared[id]
 	if !ok {
@@ -219,3 +239,14 @@ func execAll(tx *sql.Tx, q ...string) error {
 	}
 	return nil
 }
+
+// ClearTables deletes the contents of specified DB tables. Only used for
+// tests.
+func ClearTables(tables ...string) error {
+	for _, t := range tables {
+		if _, err := db.Exec(`DELETE FROM ` + t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package feeds
 
 import (
 	"github.com/cutechan/cutechan/go/common"
+	"net"
 	"sync"
 )
 
@@ -87,6 +88,38 @@ func GetByIPAndBoard(ip, board string) []common.Client {
 	return cls
 }
 
+// GetByIPNetAndBoard retrieves all Clients with IP inside the network on
+// a board
+func GetByIPNetAndBoard(n *net.IPNet, board string) []common.Client {
+	clients.RLock()
+	defer clients.RUnlock()
+
+	cls := make([]common.Client, 0, 16)
+	for cl, sync := range clients.clients {
+		if !n.Contains(net.ParseIP(cl.IP())) {
+			continue
+		}
+		if board == "all" || sync.board == board {
+			cls = append(cls, cl)
+		}
+	}
+	return cls
+}
+
+// GetByThread retrieves all Clients synced to a thread
+func GetByThread(op uint64) []common.Client {
+	clients.RLock()
+	defer clients.RUnlock()
+
+	cls := make([]common.Client, 0, 16)
+	for cl, sync := range clients.clients {
+		if sync.op == op {
+			cls = append(cls, cl)
+		}
+	}
+	return cls
+}
+
 // All returns all currently connected clients
 func All() []common.Client {
 	clients.RLock()
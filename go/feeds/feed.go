@@ -16,12 +16,21 @@ const (
 	deletePost
 	ban
 	deleteImage
+	movePost
 )
 
 type postMessage struct {
 	typ postMessageType
 	id  uint64
-	msg []byte
+	// Index of the file, if message is about one
+	file int
+	msg  []byte
+}
+
+// File deleted from a post
+type deletedFile struct {
+	id   uint64
+	file int
 }
 
 type postCreationMessage struct {
@@ -29,6 +38,8 @@ type postCreationMessage struct {
 	id             uint64
 	time           int64
 	body, msg      []byte
+	// Only propagate to clients with this IP, if set
+	shadowIP string
 }
 
 type postBodyModMessage struct {
@@ -69,7 +80,9 @@ type Feed struct {
 	// Currently open posts
 	open map[uint64]openPostCacheEntry
 	// Deleted and banned posts
-	deleted, deletedImage, banned []uint64
+	deleted, banned []uint64
+	// Files deleted from posts in order of deletion
+	deletedImage []deletedFile
 }
 
 // Read existing posts into cache and start main loop
@@ -155,6 +168,16 @@ func (f *Feed) Start() (err error) {
 
 			// Insert a new post, cache and propagate
 			case p := <-f.insertPost:
+				// Shadow banned posts are not cached and only seen by
+				// their author
+				if p.shadowIP != "" {
+					for _, c := range f.clients {
+						if c.IP() == p.shadowIP {
+							c.Send(p.msg)
+						}
+					}
+					continue
+				}
 				f.startIfPaused()
 				f.recent[p.id] = p.time
 				if p.open {
@@ -180,25 +203,7 @@ func (f *Feed) Start() (err error) {
 			// Various post-related messages
 			case msg := <-f.sendPostMessage:
 				f.startIfPaused()
-				switch msg.typ {
-				case closePost:
-					delete(f.open, msg.id)
-				case insertImage:
-					p := f.open[msg.id]
-					p.hasImage = true
-					f.open[msg.id] = p
-				case spoilerImage:
-					p := f.open[msg.id]
-					p.spoilered = true
-					f.open[msg.id] = p
-				case ban:
-					f.banned = append(f.banned, msg.id)
-				case deletePost:
-					f.deleted = append(f.deleted, msg.id)
-				case deleteImage:
-					f.deletedImage = append(f.deletedImage, msg.id)
-				}
-				f.write(msg.msg)
+				f.applyPostMessage(msg)
 			}
 		}
 	}()
@@ -206,6 +211,36 @@ func (f *Feed) Start() (err error) {
 	return
 }
 
+// Update cached post state and buffer the message
+func (f *Feed) applyPostMessage(msg postMessage) {
+	switch msg.typ {
+	case closePost:
+		delete(f.open, msg.id)
+	case insertImage:
+		p := f.open[msg.id]
+		p.hasImage = true
+		f.open[msg.id] = p
+	case spoilerImage:
+		// Closed posts are not cached
+		if p, ok := f.open[msg.id]; ok {
+			p.spoilered = true
+			f.open[msg.id] = p
+		}
+	case ban:
+		f.banned = append(f.banned, msg.id)
+	case deletePost:
+		f.deleted = append(f.deleted, msg.id)
+	case deleteImage:
+		f.deletedImage = append(f.deletedImage, deletedFile{msg.id, msg.file})
+	case movePost:
+		delete(f.recent, msg.id)
+		delete(f.open, msg.id)
+	}
+	if msg.msg != nil {
+		f.write(msg.msg)
+	}
+}
+
 // Send a message to all listening clients
 func (f *Feed) Send(msg []byte) {
 	f.send <- msg
@@ -271,7 +306,18 @@ func (f *Feed) genSyncMessage() []byte {
 	}
 	encodeUints("banned", f.banned)
 	encodeUints("deleted", f.deleted)
-	encodeUints("deletedImage", f.deletedImage)
+
+	b = append(b, `,"deletedImage":[`...)
+	first = true
+	for _, d := range f.deletedImage {
+		comma()
+		b = append(b, `{"id":`...)
+		b = strconv.AppendUint(b, d.id, 10)
+		b = append(b, `,"file":`...)
+		b = strconv.AppendInt(b, int64(d.file), 10)
+		b = append(b, '}')
+	}
+	b = append(b, ']')
 
 	b = append(b, '}')
 
@@ -301,6 +347,17 @@ func (f *Feed) InsertPost(post common.StandalonePost, body, msg []byte) {
 	}
 }
 
+// Insert a post of shadow banned author and propagate only to the
+// clients with the same IP
+func (f *Feed) InsertShadowPost(post common.StandalonePost, ip string, msg []byte) {
+	f.insertPost <- postCreationMessage{
+		id:       post.ID,
+		time:     post.Time,
+		msg:      msg,
+		shadowIP: ip,
+	}
+}
+
 // Insert an image into an already allocated post
 func (f *Feed) InsertImage(id uint64, msg []byte) {
 	f._sendPostMessage(insertImage, id, msg)
@@ -331,8 +388,17 @@ func (f *Feed) deletePost(id uint64, msg []byte) {
 	f._sendPostMessage(deletePost, id, msg)
 }
 
-func (f *Feed) deleteImage(id uint64, msg []byte) {
-	f._sendPostMessage(deleteImage, id, msg)
+func (f *Feed) deleteImage(id uint64, n int, msg []byte) {
+	f.sendPostMessage <- postMessage{
+		typ:  deleteImage,
+		id:   id,
+		file: n,
+		msg:  msg,
+	}
+}
+
+func (f *Feed) movePost(id uint64, msg []byte) {
+	f._sendPostMessage(movePost, id, msg)
 }
 
 // Set body of an open post and send update message to clients
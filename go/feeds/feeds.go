@@ -22,6 +22,7 @@ func init() {
 	common.DeletePost = DeletePost
 	common.DeleteImage = DeleteImage
 	common.SpoilerImage = SpoilerImage
+	common.LockThread = LockThread
 }
 
 // Container for managing client<->update-feed assignment and interaction
@@ -102,6 +103,14 @@ func InsertPostInto(post common.StandalonePost, msg []byte) {
 	})
 }
 
+// InsertShadowPostInto inserts a post of shadow banned author into a
+// thread feed, if it exists. Post is only visible to the author's IP.
+func InsertShadowPostInto(post common.StandalonePost, ip string, msg []byte) {
+	sendIfExists(post.OP, func(f *Feed) {
+		f.InsertShadowPost(post, ip, msg)
+	})
+}
+
 // ClosePost closes a post in a feed, if it exists
 func ClosePost(id, op uint64, msg []byte) {
 	sendIfExists(op, func(f *Feed) {
@@ -132,20 +141,26 @@ func DeletePost(id, op uint64) error {
 	})
 }
 
-// Propagate a message about an image being deleted from a post
-func DeleteImage(id, op uint64) error {
-	msg, err := common.EncodeMessage(common.MessageDeleteImage, id)
+// Propagate a message about n-th file being deleted from a post
+func DeleteImage(id, op uint64, n int) error {
+	msg, err := common.EncodeMessage(common.MessageDeleteImage, struct {
+		ID   uint64 `json:"id"`
+		File int    `json:"file"`
+	}{id, n})
 	if err != nil {
 		return err
 	}
 	return sendIfExists(op, func(f *Feed) {
-		f.deleteImage(id, msg)
+		f.deleteImage(id, n, msg)
 	})
 }
 
-// Propagate a message about an image being spoilered
-func SpoilerImage(id, op uint64) error {
-	msg, err := common.EncodeMessage(common.MessageSpoiler, id)
+// Propagate a message about n-th file of the post being spoilered
+func SpoilerImage(id, op uint64, n int) error {
+	msg, err := common.EncodeMessage(common.MessageSpoiler, struct {
+		ID   uint64 `json:"id"`
+		File int    `json:"file"`
+	}{id, n})
 	if err != nil {
 		return err
 	}
@@ -154,6 +169,53 @@ func SpoilerImage(id, op uint64) error {
 	})
 }
 
+// Propagate a message about a thread being locked or unlocked
+func LockThread(id uint64, locked bool) error {
+	msg, err := common.EncodeMessage(common.MessageLockThread, struct {
+		ID     uint64 `json:"id"`
+		Locked bool   `json:"locked"`
+	}{id, locked})
+	if err != nil {
+		return err
+	}
+	return sendIfExists(id, func(f *Feed) {
+		f.Send(msg)
+	})
+}
+
+// Propagate a message about thread being moved to another board
+func MoveThread(id uint64, board string) error {
+	msg, err := common.EncodeMessage(common.MessageMoveThread, struct {
+		ID    uint64 `json:"id"`
+		Board string `json:"board"`
+	}{id, board})
+	if err != nil {
+		return err
+	}
+	return sendIfExists(id, func(f *Feed) {
+		f.Send(msg)
+	})
+}
+
+// Propagate a message about posts being split off the thread into a new
+// thread
+func SplitThread(op, newOP uint64, board string, ids []uint64) error {
+	msg, err := common.EncodeMessage(common.MessageSplitThread, struct {
+		ID    uint64   `json:"id"`
+		Board string   `json:"board"`
+		Posts []uint64 `json:"posts"`
+	}{newOP, board, ids})
+	if err != nil {
+		return err
+	}
+	return sendIfExists(op, func(f *Feed) {
+		for _, id := range ids {
+			f.movePost(id, nil)
+		}
+		f.Send(msg)
+	})
+}
+
 // Remove all existing feeds and clients. Used only in tests.
 func Clear() {
 	feeds.mu.Lock()
@@ -17,3 +17,38 @@ func TestWriteMultipleToBuffer(t *testing.T) {
 		LogUnexpected(t, std, s)
 	}
 }
+
+func TestSpoilerImageCache(t *testing.T) {
+	t.Parallel()
+
+	f := Feed{
+		open: map[uint64]openPostCacheEntry{
+			1: {hasImage: true},
+		},
+	}
+	f.applyPostMessage(postMessage{typ: spoilerImage, id: 1, msg: []byte("a")})
+	f.applyPostMessage(postMessage{typ: spoilerImage, id: 2, msg: []byte("b")})
+
+	AssertDeepEquals(t, f.open, map[uint64]openPostCacheEntry{
+		1: {hasImage: true, spoilered: true},
+	})
+	const std = "33a\u0000b"
+	if s := string(f.flush()); s != std {
+		LogUnexpected(t, std, s)
+	}
+}
+
+func TestDeleteImageCache(t *testing.T) {
+	t.Parallel()
+
+	f := Feed{}
+	f.applyPostMessage(postMessage{typ: deleteImage, id: 1, file: 2, msg: []byte("a")})
+	f.applyPostMessage(postMessage{typ: deleteImage, id: 1, file: 0, msg: []byte("b")})
+
+	AssertDeepEquals(t, f.deletedImage, []deletedFile{{1, 2}, {1, 0}})
+	const std = `30{"recent":[],"open":{},"banned":[],"deleted":[],` +
+		`"deletedImage":[{"id":1,"file":2},{"id":1,"file":0}]}`
+	if s := string(f.genSyncMessage()); s != std {
+		LogUnexpected(t, std, s)
+	}
+}
@@ -2,8 +2,11 @@
 package file
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cutechan/cutechan/go/common"
 	"github.com/cutechan/cutechan/go/config"
@@ -12,16 +15,29 @@ import (
 // Backend equals to current file backend.
 var Backend fileBackend
 
+// ErrNotExist is returned by Stat for absent objects.
+var ErrNotExist = errors.New("object doesn't exist")
+
 // Config contains parameters for all backends.
 type Config struct {
-	Backend   string
-	Dir       string
-	Address   string
-	HostKey   string
-	Username  string
-	Password  string
-	AuthURL   string
-	Container string
+	Backend    string
+	Dir        string
+	Address    string
+	HostKey    string
+	Username   string
+	Password   string
+	AuthURL    string
+	Container  string
+	Endpoint   string
+	Region     string
+	PathStyle  bool
+	PublicRead bool
+	// Mirror backend settings.
+	Mirrors []Config
+	Quorum  int
+	Queue   string
+	// Key to sign restricted file URLs, also used as Swift temp URL key.
+	SignKey string
 }
 
 type fileBackend interface {
@@ -29,6 +45,38 @@ type fileBackend interface {
 	Serve(w http.ResponseWriter, r *http.Request)
 	Write(sha1 string, fileType, thumbType uint8, src, thumb []byte) error
 	Delete(sha1 string, fileType, thumbType uint8) error
+	// Additional thumbnail renditions are stored separately so they can
+	// be backfilled for existing uploads.
+	WriteThumbs(sha1 string, thumbs []Thumb) error
+	// Overwrite existing thumbnails so they are never missing while
+	// being regenerated.
+	ReplaceThumbs(sha1 string, thumbs []Thumb) error
+	DeleteThumbs(sha1 string, thumbs []Thumb) error
+	ReadSource(sha1 string, fileType uint8) ([]byte, error)
+	ReadThumb(sha1 string, thumb Thumb) ([]byte, error)
+	// Get URL to restricted file which is valid till expiration time.
+	SignedURL(name string, expires time.Time) string
+	// Low-level access to stored objects by their names, used for
+	// consistency checks.
+	List(fn func(Object) error) error
+	Stat(name string) (Object, error)
+	Remove(name string) error
+}
+
+// Object is a stored file. Name is relative to uploads root, see
+// SourceName and ThumbName.
+type Object struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// Thumb is additional thumbnail rendition of the file. Zero size stands
+// for base size which is stored without size suffix.
+type Thumb struct {
+	Type uint8  `json:"type"`
+	Size uint16 `json:"size"`
+	Data []byte `json:"-"`
 }
 
 const (
@@ -40,12 +88,24 @@ const (
 
 // StartBackend initializes file backend.
 func StartBackend(conf Config) (err error) {
+	setSignKey(conf.SignKey)
+	Backend, err = MakeBackend(conf)
+	return
+}
+
+// MakeBackend initializes file backend without making it current, e.g.
+// to copy files between backends.
+func MakeBackend(conf Config) (b fileBackend, err error) {
 	if conf.Backend == "fs" {
-		Backend, err = makeFSBackend(conf)
+		b, err = makeFSBackend(conf)
 	} else if conf.Backend == "sftp" {
-		Backend, err = makeSFTPBackend(conf)
+		b, err = makeSFTPBackend(conf)
 	} else if conf.Backend == "swift" {
-		Backend, err = makeSwiftBackend(conf)
+		b, err = makeSwiftBackend(conf)
+	} else if conf.Backend == "s3" {
+		b, err = makeS3Backend(conf)
+	} else if conf.Backend == "mirror" {
+		b, err = makeMirrorBackend(conf)
 	} else {
 		panic("unknown backend")
 	}
@@ -64,12 +124,151 @@ func getImageURL(root string, dir string, typ uint8, sha1 string) string {
 	return strings.Join([]string{root, dir, sha1[:2], sha1[2:] + "." + common.Extensions[typ]}, "/")
 }
 
-// SourcePath returns URL to file source.
-func SourcePath(fileType uint8, sha1 string) string {
+func sourcePath(fileType uint8, sha1 string) string {
 	return getImageURL(getImageRoot(), srcDir, fileType, sha1)
 }
 
-// ThumbPath returns URL to file thumbnail.
-func ThumbPath(thumbType uint8, sha1 string) string {
+func thumbPath(thumbType uint8, sha1 string) string {
 	return getImageURL(getImageRoot(), thumbDir, thumbType, sha1)
 }
+
+// SourcePath returns URL to file source. Restricted files get signed
+// expiring URL.
+func SourcePath(img *common.ImageCommon) string {
+	if img.Restricted {
+		if img.SignedSource == "" {
+			name := SourceName(img.FileType, img.SHA1)
+			img.SignedSource = Backend.SignedURL(name, getSignExpiry(time.Now()))
+		}
+		return img.SignedSource
+	}
+	return sourcePath(img.FileType, img.SHA1)
+}
+
+// ThumbPath returns URL to file thumbnail. Restricted files get signed
+// expiring URL.
+func ThumbPath(img *common.ImageCommon) string {
+	if img.Restricted {
+		if img.SignedThumb == "" {
+			name := ThumbName(Thumb{Type: img.ThumbType}, img.SHA1)
+			img.SignedThumb = Backend.SignedURL(name, getSignExpiry(time.Now()))
+		}
+		return img.SignedThumb
+	}
+	return thumbPath(img.ThumbType, img.SHA1)
+}
+
+// SignPaths fills signed URLs of restricted file so clients could use
+// them. Does nothing if backend isn't started, e.g. in maintenance
+// commands.
+func SignPaths(img *common.ImageCommon) {
+	if img.Restricted && Backend != nil {
+		SourcePath(img)
+		ThumbPath(img)
+	}
+}
+
+// SignFiles fills signed URLs of restricted files of the posts before
+// sending them to clients.
+func SignFiles(posts ...*common.Post) {
+	for _, p := range posts {
+		if p == nil {
+			continue
+		}
+		for _, f := range p.Files {
+			SignPaths(&f.ImageCommon)
+		}
+	}
+}
+
+// SignThread fills signed URLs of restricted files of all thread posts.
+func SignThread(t *common.Thread) {
+	SignFiles(t.Post)
+	SignFiles(t.Posts...)
+}
+
+// SourceName returns object name of file source.
+func SourceName(fileType uint8, sha1 string) string {
+	return getImageURL("", srcDir, fileType, sha1)[1:]
+}
+
+// ThumbName returns object name of file thumbnail.
+func ThumbName(t Thumb, sha1 string) string {
+	return getThumbURL("", t, sha1)[1:]
+}
+
+func getThumbURL(root string, t Thumb, sha1 string) string {
+	name := sha1[2:]
+	if t.Size != 0 {
+		name += "_" + strconv.Itoa(int(t.Size))
+	}
+	return strings.Join([]string{root, thumbDir, sha1[:2], name + "." + common.Extensions[t.Type]}, "/")
+}
+
+// ObjectSHA1 extracts SHA1 from object name, e.g.
+// "thumb/da/39a3...709_400.webp". Empty if name doesn't look like
+// uploaded file.
+func ObjectSHA1(name string) string {
+	parts := strings.Split(name, "/")
+	if len(parts) != 3 {
+		return ""
+	}
+	rest := parts[2]
+	if i := strings.IndexAny(rest, "_."); i >= 0 {
+		rest = rest[:i]
+	}
+	sha1 := parts[1] + rest
+	if len(sha1) != 40 {
+		return ""
+	}
+	return sha1
+}
+
+// HasThumb reports whether file has base thumbnail. It's absent only
+// for audio records without cover art.
+func HasThumb(img *common.ImageCommon) bool {
+	return !img.Audio || img.Video
+}
+
+// Variants returns additional thumbnail renditions available for the
+// file, without data.
+func Variants(img *common.ImageCommon) (thumbs []Thumb) {
+	for i, size := range img.ThumbSizes {
+		if i == 0 {
+			size = 0
+		} else {
+			thumbs = append(thumbs, Thumb{Type: img.ThumbType, Size: size})
+		}
+		if img.WebP {
+			thumbs = append(thumbs, Thumb{Type: common.WEBP, Size: size})
+		}
+	}
+	return
+}
+
+// ThumbSrcset returns srcset attribute value with all thumbnail
+// renditions of the specified type. Empty if there is nothing to add to
+// the base thumbnail. Renditions of restricted images aren't signed so
+// they are omitted.
+func ThumbSrcset(img *common.ImageCommon, thumbType uint8) string {
+	switch {
+	case img.Restricted || len(img.ThumbSizes) == 0:
+		return ""
+	case thumbType == common.WEBP && !img.WebP:
+		return ""
+	case thumbType != common.WEBP && len(img.ThumbSizes) < 2:
+		return ""
+	}
+	root := getImageRoot()
+	base := img.ThumbSizes[0]
+	items := make([]string, len(img.ThumbSizes))
+	for i, size := range img.ThumbSizes {
+		t := Thumb{Type: thumbType, Size: size}
+		if i == 0 {
+			t.Size = 0
+		}
+		density := strconv.FormatFloat(float64(size)/float64(base), 'f', -1, 64)
+		items[i] = getThumbURL(root, t, img.SHA1) + " " + density + "x"
+	}
+	return strings.Join(items, ", ")
+}
@@ -2,9 +2,11 @@ package file
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/dimfeld/httptreemux"
@@ -39,9 +41,15 @@ func (b *fsBackend) IsServable() bool {
 }
 
 // Serve uploads directory. Only makes sense for dev server, on
-// production you normally use nginx.
+// production you normally use nginx. Note that nginx doesn't check
+// signatures so files of mod-only boards become public unless it's set
+// up to proxy them here.
 func (b *fsBackend) Serve(w http.ResponseWriter, r *http.Request) {
 	path := getParam(r, "path")
+	if !canServe(path, r) {
+		http.Error(w, "404 not found", 404)
+		return
+	}
 	file, err := os.Open(cleanJoin(b.dir, path))
 	if err != nil {
 		http.Error(w, fmt.Sprintf("404 %s", err), 404)
@@ -57,6 +65,22 @@ func (b *fsBackend) Serve(w http.ResponseWriter, r *http.Request) {
 	http.ServeContent(w, r, path, time.Time{}, file)
 }
 
+// Restricted files are only accessible with valid signature.
+func canServe(name string, r *http.Request) bool {
+	name = strings.TrimPrefix(filepath.ToSlash(cleanJoin("/", name)), "/")
+	q := r.URL.Query()
+	if q.Get("signature") != "" {
+		return checkSignature(name, q, time.Now())
+	}
+	restricted, err := isRestrictedCached(ObjectSHA1(name), time.Now())
+	return err == nil && !restricted
+}
+
+// SignedURL returns URL to file with signature checked by Serve.
+func (b *fsBackend) SignedURL(name string, expires time.Time) string {
+	return getImageRoot() + "/" + name + "?" + getSignedQuery(name, expires)
+}
+
 // Generate file paths of the source file and its thumbnail
 func fsGetPaths(root string, SHA1 string, fileType, thumbType uint8) (paths [2]string) {
 	path := getImageURL(root, srcDir, fileType, SHA1)
@@ -121,6 +145,103 @@ func (b *fsBackend) Delete(SHA1 string, fileType, thumbType uint8) error {
 	return nil
 }
 
+// WriteThumbs writes additional thumbnail renditions to disk
+func (b *fsBackend) WriteThumbs(SHA1 string, thumbs []Thumb) error {
+	for _, t := range thumbs {
+		path := filepath.FromSlash(getThumbURL(b.dir, t, SHA1))
+		if err := fsWriteFile(path, t.Data); err != nil && !os.IsExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplaceThumbs overwrites thumbnail renditions on disk. Temporary file
+// is renamed so readers never see partially written one.
+func (b *fsBackend) ReplaceThumbs(SHA1 string, thumbs []Thumb) error {
+	for _, t := range thumbs {
+		path := filepath.FromSlash(getThumbURL(b.dir, t, SHA1))
+		tmp := path + ".tmp"
+		os.Remove(tmp)
+		if err := fsWriteFile(tmp, t.Data); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteThumbs deletes additional thumbnail renditions from disk
+func (b *fsBackend) DeleteThumbs(SHA1 string, thumbs []Thumb) error {
+	for _, t := range thumbs {
+		path := filepath.FromSlash(getThumbURL(b.dir, t, SHA1))
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadSource reads source file from disk
+func (b *fsBackend) ReadSource(SHA1 string, fileType uint8) ([]byte, error) {
+	path := getImageURL(b.dir, srcDir, fileType, SHA1)
+	return ioutil.ReadFile(filepath.FromSlash(path))
+}
+
+// ReadThumb reads thumbnail rendition from disk
+func (b *fsBackend) ReadThumb(SHA1 string, t Thumb) ([]byte, error) {
+	path := getThumbURL(b.dir, t, SHA1)
+	return ioutil.ReadFile(filepath.FromSlash(path))
+}
+
+// List walks over all stored files
+func (b *fsBackend) List(fn func(Object) error) error {
+	for _, dir := range [...]string{srcDir, thumbDir} {
+		err := filepath.Walk(filepath.Join(b.dir, dir), func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			name, err := filepath.Rel(b.dir, path)
+			if err != nil {
+				return err
+			}
+			return fn(Object{
+				Name:    filepath.ToSlash(name),
+				Size:    info.Size(),
+				ModTime: info.ModTime(),
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stat returns info of the single stored file
+func (b *fsBackend) Stat(name string) (obj Object, err error) {
+	info, err := os.Stat(cleanJoin(b.dir, filepath.FromSlash(name)))
+	if os.IsNotExist(err) {
+		err = ErrNotExist
+	}
+	if err != nil {
+		return
+	}
+	obj = Object{Name: name, Size: info.Size(), ModTime: info.ModTime()}
+	return
+}
+
+// Remove deletes single stored file
+func (b *fsBackend) Remove(name string) error {
+	err := os.Remove(cleanJoin(b.dir, filepath.FromSlash(name)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
 func fsCreateDirs(root string) error {
 	for _, dir := range [...]string{srcDir, thumbDir} {
 		path := filepath.Join(root, dir)
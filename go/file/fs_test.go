@@ -0,0 +1,90 @@
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cutechan/cutechan/go/common"
+	. "github.com/cutechan/cutechan/go/test"
+)
+
+func TestFSObjects(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cutechan-uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	b, err := makeFSBackend(Config{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const sha1 = "da39a3ee5e6b4b0d3255bfef95601890afd80709"
+	err = b.Write(sha1, common.PNG, common.JPEG, []byte("source"), []byte("thumb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	variant := Thumb{Type: common.WEBP, Size: 400, Data: []byte("webp")}
+	if err := b.WriteThumbs(sha1, []Thumb{variant}); err != nil {
+		t.Fatal(err)
+	}
+
+	var objs []Object
+	err = b.List(func(obj Object) error {
+		assertModTime(t, &obj)
+		objs = append(objs, obj)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	std := []Object{
+		{Name: "src/da/39a3ee5e6b4b0d3255bfef95601890afd80709.png", Size: 6},
+		{Name: "thumb/da/39a3ee5e6b4b0d3255bfef95601890afd80709.jpg", Size: 5},
+		{Name: "thumb/da/39a3ee5e6b4b0d3255bfef95601890afd80709_400.webp", Size: 4},
+	}
+	AssertDeepEquals(t, objs, std)
+
+	// Thumbnails are overwritten only when replaced.
+	base := Thumb{Type: common.JPEG, Data: []byte("new thumb")}
+	if err := b.WriteThumbs(sha1, []Thumb{base}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := b.ReadThumb(sha1, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, data, []byte("thumb"))
+	if err := b.ReplaceThumbs(sha1, []Thumb{base}); err != nil {
+		t.Fatal(err)
+	}
+	data, err = b.ReadThumb(sha1, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, data, base.Data)
+
+	name := ThumbName(variant, sha1)
+	obj, err := b.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertModTime(t, &obj)
+	AssertDeepEquals(t, obj, std[2])
+	if err := b.Remove(name); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Stat(name); err != ErrNotExist {
+		LogUnexpected(t, ErrNotExist, err)
+	}
+}
+
+// Check modification time is recent and clear it for comparison.
+func assertModTime(t *testing.T, obj *Object) {
+	if d := time.Since(obj.ModTime); d < -time.Minute || d > time.Minute {
+		t.Fatalf("unexpected modification time of %s: %v", obj.Name, obj.ModTime)
+	}
+	obj.ModTime = time.Time{}
+}
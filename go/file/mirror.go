@@ -0,0 +1,513 @@
+package file
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	mirrorRetryInterval = time.Minute
+	// Give up after a day of retries, fsck-files should be used to
+	// repair such replica.
+	mirrorMaxAttempts  = 24 * 60
+	defaultMirrorQueue = "./mirror-queue.json"
+)
+
+// Failed replica operation to retry later. Data isn't stored, it's
+// copied from other replicas at retry time.
+type mirrorOp struct {
+	ID        uint64  `json:"id"`
+	Child     int     `json:"child"`
+	Op        string  `json:"op"`
+	SHA1      string  `json:"sha1,omitempty"`
+	FileType  uint8   `json:"fileType,omitempty"`
+	ThumbType uint8   `json:"thumbType,omitempty"`
+	Source    bool    `json:"source,omitempty"`
+	Thumb     bool    `json:"thumb,omitempty"`
+	Thumbs    []Thumb `json:"thumbs,omitempty"`
+	Name      string  `json:"name,omitempty"`
+	Attempts  int     `json:"attempts,omitempty"`
+}
+
+// Composite backend which replicates files to several children.
+// Operation succeeds if at least quorum children succeeded, the rest
+// is retried in background.
+type mirrorBackend struct {
+	children []fileBackend
+	names    []string
+	quorum   int
+	// Protects queue.
+	mu        sync.Mutex
+	queue     []mirrorOp
+	queuePath string
+	lastID    uint64
+}
+
+func (b *mirrorBackend) IsServable() bool {
+	for _, c := range b.children {
+		if c.IsServable() {
+			return true
+		}
+	}
+	return false
+}
+
+// Serve through the first servable child.
+func (b *mirrorBackend) Serve(w http.ResponseWriter, r *http.Request) {
+	for _, c := range b.children {
+		if c.IsServable() {
+			c.Serve(w, r)
+			return
+		}
+	}
+	panic("non-servable backend")
+}
+
+// Run operation on all children concurrently and queue failed ones.
+// Failed children are queued even if quorum wasn't reached, as long as
+// any child succeeded, so replicas don't diverge for good.
+func (b *mirrorBackend) fanOut(op mirrorOp, fn func(c fileBackend) error) error {
+	errs := make([]error, len(b.children))
+	var wg sync.WaitGroup
+	for i, c := range b.children {
+		wg.Add(1)
+		go func(i int, c fileBackend) {
+			defer wg.Done()
+			errs[i] = fn(c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	var failed []int
+	var msgs []string
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, i)
+			msgs = append(msgs, fmt.Sprintf("%s: %v", b.names[i], err))
+		}
+	}
+	if len(failed) == len(b.children) {
+		return fmt.Errorf("mirror quorum not reached: %s", strings.Join(msgs, "; "))
+	}
+	b.dropDeletes(op, failed)
+	if len(failed) != 0 {
+		log.Printf("[mirror] %s failed, will retry: %s", op.Op, strings.Join(msgs, "; "))
+		ops := make([]mirrorOp, len(failed))
+		for j, i := range failed {
+			ops[j] = op
+			ops[j].Child = i
+		}
+		b.enqueue(ops...)
+	}
+	if len(b.children)-len(failed) < b.quorum {
+		return fmt.Errorf("mirror quorum not reached: %s", strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// Try children in order until one succeeds.
+func (b *mirrorBackend) first(skip int, fn func(c fileBackend) error) (err error) {
+	for i, c := range b.children {
+		if i == skip {
+			continue
+		}
+		if err = fn(c); err == nil {
+			return
+		}
+	}
+	return
+}
+
+func (b *mirrorBackend) Write(sha1 string, fileType, thumbType uint8, src, thumb []byte) error {
+	op := mirrorOp{
+		Op: "write", SHA1: sha1, FileType: fileType, ThumbType: thumbType,
+		Source: src != nil, Thumb: thumb != nil,
+	}
+	return b.fanOut(op, func(c fileBackend) error {
+		return c.Write(sha1, fileType, thumbType, src, thumb)
+	})
+}
+
+func (b *mirrorBackend) Delete(sha1 string, fileType, thumbType uint8) error {
+	op := mirrorOp{Op: "delete", SHA1: sha1, FileType: fileType, ThumbType: thumbType}
+	return b.fanOut(op, func(c fileBackend) error {
+		return c.Delete(sha1, fileType, thumbType)
+	})
+}
+
+func (b *mirrorBackend) WriteThumbs(sha1 string, thumbs []Thumb) error {
+	op := mirrorOp{Op: "writeThumbs", SHA1: sha1, Thumbs: stripThumbs(thumbs)}
+	return b.fanOut(op, func(c fileBackend) error {
+		return c.WriteThumbs(sha1, thumbs)
+	})
+}
+
+func (b *mirrorBackend) ReplaceThumbs(sha1 string, thumbs []Thumb) error {
+	op := mirrorOp{Op: "replaceThumbs", SHA1: sha1, Thumbs: stripThumbs(thumbs)}
+	return b.fanOut(op, func(c fileBackend) error {
+		return c.ReplaceThumbs(sha1, thumbs)
+	})
+}
+
+func (b *mirrorBackend) DeleteThumbs(sha1 string, thumbs []Thumb) error {
+	op := mirrorOp{Op: "deleteThumbs", SHA1: sha1, Thumbs: stripThumbs(thumbs)}
+	return b.fanOut(op, func(c fileBackend) error {
+		return c.DeleteThumbs(sha1, thumbs)
+	})
+}
+
+func (b *mirrorBackend) Remove(name string) error {
+	op := mirrorOp{Op: "remove", Name: name}
+	return b.fanOut(op, func(c fileBackend) error {
+		return c.Remove(name)
+	})
+}
+
+func (b *mirrorBackend) ReadSource(sha1 string, fileType uint8) (data []byte, err error) {
+	err = b.first(-1, func(c fileBackend) (err error) {
+		data, err = c.ReadSource(sha1, fileType)
+		return
+	})
+	return
+}
+
+func (b *mirrorBackend) ReadThumb(sha1 string, t Thumb) (data []byte, err error) {
+	err = b.first(-1, func(c fileBackend) (err error) {
+		data, err = c.ReadThumb(sha1, t)
+		return
+	})
+	return
+}
+
+func (b *mirrorBackend) Stat(name string) (obj Object, err error) {
+	err = b.first(-1, func(c fileBackend) (err error) {
+		obj, err = c.Stat(name)
+		return
+	})
+	return
+}
+
+// Sign by the child which serves files.
+func (b *mirrorBackend) SignedURL(name string, expires time.Time) string {
+	for _, c := range b.children {
+		if c.IsServable() {
+			return c.SignedURL(name, expires)
+		}
+	}
+	return b.children[0].SignedURL(name, expires)
+}
+
+// List files of all children. Replicas are supposed to be the same but
+// files might be left on some of them, e.g. by failed deletions.
+func (b *mirrorBackend) List(fn func(Object) error) error {
+	seen := make(map[string]bool)
+	for _, c := range b.children {
+		err := c.List(func(obj Object) error {
+			if seen[obj.Name] {
+				return nil
+			}
+			seen[obj.Name] = true
+			return fn(obj)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Keep only info required to find thumbnails.
+func stripThumbs(thumbs []Thumb) []Thumb {
+	res := make([]Thumb, len(thumbs))
+	for i, t := range thumbs {
+		res[i] = Thumb{Type: t.Type, Size: t.Size}
+	}
+	return res
+}
+
+func (b *mirrorBackend) enqueue(ops ...mirrorOp) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, op := range ops {
+		// No need to copy files which are going to be deleted anyway.
+		if op.Op == "delete" || op.Op == "deleteThumbs" {
+			queue := b.queue[:0]
+			for _, q := range b.queue {
+				if q.Child != op.Child || q.SHA1 != op.SHA1 || !isWriteOp(q.Op) {
+					queue = append(queue, q)
+				}
+			}
+			b.queue = queue
+		}
+		b.lastID++
+		op.ID = b.lastID
+		b.queue = append(b.queue, op)
+	}
+	b.saveQueue()
+}
+
+// Drop queued deletions of files which were successfully written by
+// the operation, so retry won't wipe them. Failed children and
+// deletions newer than the queued operation are left intact.
+func (b *mirrorBackend) dropDeletes(op mirrorOp, failed []int) {
+	names := op.writtenNames()
+	if len(names) == 0 {
+		return
+	}
+	skip := make(map[int]bool, len(failed))
+	for _, i := range failed {
+		skip[i] = true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	queue := b.queue[:0]
+	changed := false
+	for _, q := range b.queue {
+		if skip[q.Child] || (op.ID != 0 && q.ID > op.ID) {
+			queue = append(queue, q)
+			continue
+		}
+		switch q.Op {
+		case "delete":
+			if names[SourceName(q.FileType, q.SHA1)] {
+				changed = true
+				continue
+			}
+		case "deleteThumbs":
+			thumbs := q.Thumbs[:0:0]
+			for _, t := range q.Thumbs {
+				if !names[ThumbName(t, q.SHA1)] {
+					thumbs = append(thumbs, t)
+				}
+			}
+			if len(thumbs) != len(q.Thumbs) {
+				changed = true
+				if len(thumbs) == 0 {
+					continue
+				}
+				q.Thumbs = thumbs
+			}
+		case "remove":
+			if names[q.Name] {
+				changed = true
+				continue
+			}
+		}
+		queue = append(queue, q)
+	}
+	b.queue = queue
+	if changed {
+		b.saveQueue()
+	}
+}
+
+// Names of files written by the operation.
+func (op mirrorOp) writtenNames() map[string]bool {
+	names := make(map[string]bool)
+	switch op.Op {
+	case "write":
+		if op.Source {
+			names[SourceName(op.FileType, op.SHA1)] = true
+		}
+		if op.Thumb {
+			names[ThumbName(Thumb{Type: op.ThumbType}, op.SHA1)] = true
+		}
+	case "writeThumbs", "replaceThumbs":
+		for _, t := range op.Thumbs {
+			names[ThumbName(t, op.SHA1)] = true
+		}
+	}
+	return names
+}
+
+func isWriteOp(op string) bool {
+	return op == "write" || op == "writeThumbs" || op == "replaceThumbs"
+}
+
+// Must be called with lock held. Write to temporary file first so queue
+// is never left truncated.
+func (b *mirrorBackend) saveQueue() {
+	data, err := json.Marshal(b.queue)
+	if err == nil {
+		tmp := b.queuePath + ".tmp"
+		err = ioutil.WriteFile(tmp, data, fileMode)
+		if err == nil {
+			err = os.Rename(tmp, b.queuePath)
+		}
+	}
+	if err != nil {
+		log.Printf("[mirror] cannot save retry queue: %v", err)
+	}
+}
+
+func (b *mirrorBackend) loadQueue() error {
+	data, err := ioutil.ReadFile(b.queuePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &b.queue)
+}
+
+func (b *mirrorBackend) retryLoop() {
+	for {
+		time.Sleep(mirrorRetryInterval)
+		b.retry()
+	}
+}
+
+// Retry queued operations in order. Queue might be changed meanwhile
+// so operations are matched by ID.
+func (b *mirrorBackend) retry() {
+	b.mu.Lock()
+	ops := append([]mirrorOp(nil), b.queue...)
+	b.mu.Unlock()
+	if len(ops) == 0 {
+		return
+	}
+
+	done := make(map[uint64]bool)
+	failed := make(map[uint64]bool)
+	for _, op := range ops {
+		if err := b.execute(op); err != nil {
+			log.Printf("[mirror] %s retry on %s failed: %v", op.Op, b.names[op.Child], err)
+			failed[op.ID] = true
+		} else {
+			done[op.ID] = true
+			b.dropDeletes(op, b.otherChildren(op.Child))
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	queue := b.queue[:0]
+	for _, op := range b.queue {
+		if done[op.ID] {
+			continue
+		}
+		if failed[op.ID] {
+			op.Attempts++
+			if op.Attempts >= mirrorMaxAttempts {
+				log.Printf("[mirror] giving up %s of %s%s on %s",
+					op.Op, op.SHA1, op.Name, b.names[op.Child])
+				continue
+			}
+		}
+		queue = append(queue, op)
+	}
+	b.queue = queue
+	b.saveQueue()
+	log.Printf("[mirror] %d operations left in retry queue", len(b.queue))
+}
+
+// Indexes of all children except the specified one.
+func (b *mirrorBackend) otherChildren(child int) (others []int) {
+	for i := range b.children {
+		if i != child {
+			others = append(others, i)
+		}
+	}
+	return
+}
+
+// Execute queued operation, copying data from other children.
+func (b *mirrorBackend) execute(op mirrorOp) (err error) {
+	c := b.children[op.Child]
+	switch op.Op {
+	case "write":
+		var src, thumb []byte
+		if op.Source {
+			err = b.first(op.Child, func(c fileBackend) (err error) {
+				src, err = c.ReadSource(op.SHA1, op.FileType)
+				return
+			})
+			if err != nil {
+				return
+			}
+		}
+		if op.Thumb {
+			err = b.first(op.Child, func(c fileBackend) (err error) {
+				thumb, err = c.ReadThumb(op.SHA1, Thumb{Type: op.ThumbType})
+				return
+			})
+			if err != nil {
+				return
+			}
+		}
+		return c.Write(op.SHA1, op.FileType, op.ThumbType, src, thumb)
+	case "writeThumbs", "replaceThumbs":
+		thumbs := make([]Thumb, len(op.Thumbs))
+		for i, t := range op.Thumbs {
+			thumbs[i] = t
+			err = b.first(op.Child, func(c fileBackend) (err error) {
+				thumbs[i].Data, err = c.ReadThumb(op.SHA1, t)
+				return
+			})
+			if err != nil {
+				return
+			}
+		}
+		if op.Op == "replaceThumbs" {
+			return c.ReplaceThumbs(op.SHA1, thumbs)
+		}
+		return c.WriteThumbs(op.SHA1, thumbs)
+	case "delete":
+		return c.Delete(op.SHA1, op.FileType, op.ThumbType)
+	case "deleteThumbs":
+		return c.DeleteThumbs(op.SHA1, op.Thumbs)
+	case "remove":
+		return c.Remove(op.Name)
+	default:
+		return fmt.Errorf("unknown operation %s", op.Op)
+	}
+}
+
+func makeMirrorBackend(conf Config) (fileBackend, error) {
+	if len(conf.Mirrors) == 0 {
+		return nil, fmt.Errorf("no mirrors configured")
+	}
+	if conf.Quorum < 1 || conf.Quorum > len(conf.Mirrors) {
+		return nil, fmt.Errorf("bad mirror quorum: %d", conf.Quorum)
+	}
+	b := &mirrorBackend{quorum: conf.Quorum, queuePath: conf.Queue}
+	if b.queuePath == "" {
+		b.queuePath = defaultMirrorQueue
+	}
+	for i, childConf := range conf.Mirrors {
+		if childConf.Backend == "mirror" {
+			return nil, fmt.Errorf("nested mirrors aren't supported")
+		}
+		if childConf.SignKey == "" {
+			childConf.SignKey = conf.SignKey
+		}
+		child, err := MakeBackend(childConf)
+		if err != nil {
+			return nil, err
+		}
+		b.children = append(b.children, child)
+		b.names = append(b.names, fmt.Sprintf("#%d (%s)", i, childConf.Backend))
+	}
+	if err := b.loadQueue(); err != nil {
+		return nil, fmt.Errorf("cannot load mirror retry queue: %v", err)
+	}
+	for _, op := range b.queue {
+		if op.Child >= len(b.children) {
+			return nil, fmt.Errorf("mirror retry queue doesn't match config")
+		}
+		if op.ID > b.lastID {
+			b.lastID = op.ID
+		}
+	}
+	go b.retryLoop()
+	return b, nil
+}
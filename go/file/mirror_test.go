@@ -0,0 +1,118 @@
+package file
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cutechan/cutechan/go/common"
+	. "github.com/cutechan/cutechan/go/test"
+)
+
+type flakyBackend struct {
+	fileBackend
+	fail, failDelete bool
+}
+
+func (b *flakyBackend) Write(sha1 string, fileType, thumbType uint8, src, thumb []byte) error {
+	if b.fail {
+		return errors.New("flaky")
+	}
+	return b.fileBackend.Write(sha1, fileType, thumbType, src, thumb)
+}
+
+func (b *flakyBackend) Delete(sha1 string, fileType, thumbType uint8) error {
+	if b.failDelete {
+		return errors.New("flaky")
+	}
+	return b.fileBackend.Delete(sha1, fileType, thumbType)
+}
+
+func TestMirrorBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cutechan-mirror")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var children []fileBackend
+	for _, name := range [...]string{"a", "b"} {
+		child, err := makeFSBackend(Config{Dir: filepath.Join(dir, name)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		children = append(children, child)
+	}
+	flaky := &flakyBackend{fileBackend: children[1], fail: true}
+	b := &mirrorBackend{
+		children:  []fileBackend{children[0], flaky},
+		names:     []string{"a", "b"},
+		quorum:    2,
+		queuePath: filepath.Join(dir, "queue.json"),
+	}
+
+	const sha1 = "da39a3ee5e6b4b0d3255bfef95601890afd80709"
+	src := []byte("source")
+	if err := b.Write(sha1, common.PNG, common.JPEG, src, nil); err == nil {
+		t.Fatal("expected quorum error")
+	}
+	// Succeeded child keeps the file so failed one is synced later.
+	AssertDeepEquals(t, len(b.queue), 1)
+
+	b.quorum = 1
+	if err := b.Write(sha1, common.PNG, common.JPEG, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, len(b.queue), 2)
+
+	// Queue is persistent.
+	b2 := &mirrorBackend{queuePath: b.queuePath}
+	if err := b2.loadQueue(); err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, b2.queue, b.queue)
+
+	// Still failing.
+	b.retry()
+	AssertDeepEquals(t, b.queue[0].Attempts, 1)
+
+	flaky.fail = false
+	b.retry()
+	AssertDeepEquals(t, len(b.queue), 0)
+	data, err := children[1].ReadSource(sha1, common.PNG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, data, src)
+
+	// Stale deletion doesn't wipe re-uploaded file.
+	flaky.failDelete = true
+	if err := b.Delete(sha1, common.PNG, common.JPEG); err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, len(b.queue), 1)
+	if err := b.Write(sha1, common.PNG, common.JPEG, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, len(b.queue), 0)
+
+	// Files left on any replica are listed.
+	var names []string
+	err = b.List(func(obj Object) error {
+		names = append(names, obj.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, names, []string{SourceName(common.PNG, sha1)})
+
+	// Hopeless operations are dropped eventually.
+	if err := b.Delete(sha1, common.PNG, common.JPEG); err != nil {
+		t.Fatal(err)
+	}
+	b.queue[0].Attempts = mirrorMaxAttempts - 1
+	b.retry()
+	AssertDeepEquals(t, len(b.queue), 0)
+}
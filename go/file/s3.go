@@ -0,0 +1,437 @@
+package file
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3Service    = "s3"
+	s3Algorithm  = "AWS4-HMAC-SHA256"
+	s3DateFormat = "20060102T150405Z"
+	s3Timeout    = time.Minute
+)
+
+type s3Backend struct {
+	endpoint   *url.URL
+	bucket     string
+	region     string
+	accessKey  string
+	secretKey  string
+	pathStyle  bool
+	publicRead bool
+	client     *http.Client
+}
+
+func (b *s3Backend) IsServable() bool {
+	return false
+}
+
+// Served by bucket website endpoint or CDN.
+func (b *s3Backend) Serve(w http.ResponseWriter, r *http.Request) {
+	panic("non-servable backend")
+}
+
+// Get URL of the object. Virtual-hosted style is used by default,
+// path style is required by most self-hosted installations (MinIO).
+func (b *s3Backend) objectURL(name string) *url.URL {
+	u := *b.endpoint
+	if b.pathStyle {
+		u.Path = path.Join("/", u.Path, b.bucket, name)
+	} else {
+		u.Host = b.bucket + "." + u.Host
+		u.Path = path.Join("/", u.Path, name)
+	}
+	return &u
+}
+
+func (b *s3Backend) do(method, name string, query url.Values, body []byte, header http.Header) (res *http.Response, err error) {
+	u := b.objectURL(name)
+	u.RawQuery = canonicalS3Query(query)
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	signS3Request(req, body, b.accessKey, b.secretKey, b.region, time.Now())
+	return b.client.Do(req)
+}
+
+// Read error message from S3 response. Body is XML document but we
+// only need it for logging so keep as is.
+func readS3Error(res *http.Response) error {
+	data, _ := ioutil.ReadAll(res.Body)
+	return fmt.Errorf("%s: %s", res.Status, bytes.TrimSpace(data))
+}
+
+func (b *s3Backend) writeFile(name string, data []byte) (err error) {
+	if data == nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cannot create S3 object %s in %s: %v", name, b.bucket, err)
+		}
+	}()
+
+	header := make(http.Header)
+	if typ := mime.TypeByExtension(path.Ext(name)); typ != "" {
+		header.Set("Content-Type", typ)
+	}
+	if b.publicRead {
+		header.Set("X-Amz-Acl", "public-read")
+	}
+	res, err := b.do("PUT", name, nil, data, header)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		err = readS3Error(res)
+	}
+	return
+}
+
+func (b *s3Backend) Write(sha1 string, fileType, thumbType uint8, src, thumb []byte) error {
+	ch := make(chan error)
+	go func() {
+		// Full path logging might be useful for later manual PURGE.
+		log.Printf("[s3] creating <%s>", sourcePath(fileType, sha1))
+		ch <- b.writeFile(getObjectSourceName(fileType, sha1), src)
+	}()
+	go func() {
+		log.Printf("[s3] creating <%s>", thumbPath(thumbType, sha1))
+		ch <- b.writeFile(getObjectThumbName(thumbType, sha1), thumb)
+	}()
+	for _, err := range [...]error{<-ch, <-ch} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *s3Backend) deleteFile(name string) (err error) {
+	res, err := b.do("DELETE", name, nil, nil, nil)
+	if err == nil {
+		defer res.Body.Close()
+		// S3 doesn't complain about absent objects but other
+		// implementations might.
+		switch res.StatusCode {
+		case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		default:
+			err = readS3Error(res)
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("cannot delete S3 object %s from %s: %v", name, b.bucket, err)
+	}
+	return
+}
+
+func (b *s3Backend) Delete(sha1 string, fileType, thumbType uint8) error {
+	ch := make(chan error)
+	go func() {
+		log.Printf("[s3] deleting <%s>", sourcePath(fileType, sha1))
+		ch <- b.deleteFile(getObjectSourceName(fileType, sha1))
+	}()
+	go func() {
+		log.Printf("[s3] deleting <%s>", thumbPath(thumbType, sha1))
+		ch <- b.deleteFile(getObjectThumbName(thumbType, sha1))
+	}()
+	for _, err := range [...]error{<-ch, <-ch} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *s3Backend) WriteThumbs(sha1 string, thumbs []Thumb) error {
+	for _, t := range thumbs {
+		name := getThumbURL("", t, sha1)
+		log.Printf("[s3] creating <%s>", getThumbURL(getImageRoot(), t, sha1))
+		if err := b.writeFile(name, t.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Objects are overwritten atomically.
+func (b *s3Backend) ReplaceThumbs(sha1 string, thumbs []Thumb) error {
+	return b.WriteThumbs(sha1, thumbs)
+}
+
+func (b *s3Backend) DeleteThumbs(sha1 string, thumbs []Thumb) error {
+	for _, t := range thumbs {
+		name := getThumbURL("", t, sha1)
+		log.Printf("[s3] deleting <%s>", getThumbURL(getImageRoot(), t, sha1))
+		if err := b.deleteFile(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *s3Backend) ReadSource(sha1 string, fileType uint8) ([]byte, error) {
+	return b.readFile(getObjectSourceName(fileType, sha1))
+}
+
+func (b *s3Backend) ReadThumb(sha1 string, t Thumb) ([]byte, error) {
+	return b.readFile(getThumbURL("", t, sha1))
+}
+
+func (b *s3Backend) readFile(name string) (data []byte, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cannot read S3 object %s from %s: %v", name, b.bucket, err)
+		}
+	}()
+
+	res, err := b.do("GET", name, nil, nil, nil)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		err = readS3Error(res)
+		return
+	}
+	data, err = ioutil.ReadAll(res.Body)
+	return
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// Walk over bucket using ListObjectsV2 API.
+func (b *s3Backend) List(fn func(Object) error) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cannot list S3 bucket %s: %v", b.bucket, err)
+		}
+	}()
+
+	query := url.Values{"list-type": {"2"}}
+	for {
+		var res *http.Response
+		res, err = b.do("GET", "", query, nil, nil)
+		if err != nil {
+			return
+		}
+		var list s3ListResult
+		if res.StatusCode != http.StatusOK {
+			err = readS3Error(res)
+		} else {
+			err = xml.NewDecoder(res.Body).Decode(&list)
+		}
+		res.Body.Close()
+		if err != nil {
+			return
+		}
+		for _, o := range list.Contents {
+			obj := Object{Name: o.Key, Size: o.Size, ModTime: o.LastModified}
+			if err = fn(obj); err != nil {
+				return
+			}
+		}
+		if !list.IsTruncated {
+			return
+		}
+		query.Set("continuation-token", list.NextContinuationToken)
+	}
+}
+
+func (b *s3Backend) Stat(name string) (obj Object, err error) {
+	res, err := b.do("HEAD", name, nil, nil, nil)
+	if err != nil {
+		return
+	}
+	res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+		obj = Object{Name: name, Size: res.ContentLength}
+		obj.ModTime, _ = http.ParseTime(res.Header.Get("Last-Modified"))
+	case http.StatusNotFound:
+		err = ErrNotExist
+	default:
+		err = fmt.Errorf("cannot stat S3 object %s in %s: %s", name, b.bucket, res.Status)
+	}
+	return
+}
+
+// Bucket is supposed to be private for restricted files to make sense
+// so presigned URL points to S3 endpoint rather than CDN.
+func (b *s3Backend) SignedURL(name string, expires time.Time) string {
+	now := time.Now()
+	return presignS3URL(b.objectURL(name), b.accessKey, b.secretKey, b.region, now, expires.Sub(now))
+}
+
+func (b *s3Backend) Remove(name string) error {
+	log.Printf("[s3] deleting <%s>", getImageRoot()+"/"+name)
+	return b.deleteFile(name)
+}
+
+// Check that bucket exists and credentials are valid.
+func (b *s3Backend) checkBucket() (err error) {
+	res, err := b.do("HEAD", "", nil, nil, nil)
+	if err != nil {
+		return
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		err = fmt.Errorf("bucket %s: %s", b.bucket, res.Status)
+	}
+	return
+}
+
+func makeS3Backend(conf Config) (b fileBackend, err error) {
+	endpoint, err := url.Parse(conf.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		err = fmt.Errorf("bad S3 endpoint: %s", conf.Endpoint)
+		return
+	}
+	s3b := &s3Backend{
+		endpoint:   endpoint,
+		bucket:     conf.Container,
+		region:     conf.Region,
+		accessKey:  conf.Username,
+		secretKey:  conf.Password,
+		pathStyle:  conf.PathStyle,
+		publicRead: conf.PublicRead,
+		client:     &http.Client{Timeout: s3Timeout},
+	}
+	if err = s3b.checkBucket(); err != nil {
+		err = fmt.Errorf("cannot connect to S3: %v", err)
+		return
+	}
+	b = s3b
+	return
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// Sign request with AWS Signature Version 4. All headers set on the
+// request are signed.
+// See: https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func signS3Request(req *http.Request, body []byte, accessKey, secretKey, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(s3DateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", sha256Hex(body))
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalS3Query(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		headers["x-amz-content-sha256"],
+	}, "\n")
+	scope, signature := s3Signature(canonicalRequest, secretKey, region, amzDate)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, accessKey, scope, signedHeaders, signature))
+}
+
+// Presign GET request of the object so it can be fetched without
+// credentials until expiration. Only host header is signed.
+// See: https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-query-string-auth.html
+func presignS3URL(u *url.URL, accessKey, secretKey, region string, now time.Time, expires time.Duration) string {
+	amzDate := now.UTC().Format(s3DateFormat)
+	query := url.Values{
+		"X-Amz-Algorithm":     {s3Algorithm},
+		"X-Amz-Credential":    {accessKey + "/" + s3Scope(amzDate[:8], region)},
+		"X-Amz-Date":          {amzDate},
+		"X-Amz-Expires":       {strconv.Itoa(int(expires.Seconds()))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	canonicalRequest := strings.Join([]string{
+		"GET",
+		u.EscapedPath(),
+		canonicalS3Query(query),
+		"host:" + u.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	_, signature := s3Signature(canonicalRequest, secretKey, region, amzDate)
+	query.Set("X-Amz-Signature", signature)
+	signed := *u
+	signed.RawQuery = canonicalS3Query(query)
+	return signed.String()
+}
+
+func s3Scope(date, region string) string {
+	return strings.Join([]string{date, region, s3Service, "aws4_request"}, "/")
+}
+
+func s3Signature(canonicalRequest, secretKey, region, amzDate string) (scope, signature string) {
+	date := amzDate[:8]
+	scope = s3Scope(date, region)
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, s3Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature = hex.EncodeToString(hmacSHA256(key, stringToSign))
+	return
+}
+
+func canonicalS3Query(q url.Values) string {
+	// Encode sorts by key, spaces must be encoded as %20.
+	return strings.Replace(q.Encode(), "+", "%20", -1)
+}
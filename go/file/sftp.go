@@ -3,10 +3,12 @@ package file
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"path"
+	"strings"
 	"sync"
 	"time"
 
@@ -106,6 +108,149 @@ func (b *sftpBackend) Delete(sha1 string, fileType, thumbType uint8) (err error)
 	return
 }
 
+func (b *sftpBackend) WriteThumbs(sha1 string, thumbs []Thumb) (err error) {
+	for _, t := range thumbs {
+		err = b.writeFile(getThumbURL(DefaultUploadsRoot, t, sha1), t.Data)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Write to temporary file first and rename it over the existing one.
+func (b *sftpBackend) replaceFile(fpath string, data []byte) error {
+	b.Lock()
+	defer b.Unlock()
+	if b.client == nil {
+		return errNoConnection
+	}
+
+	if err := b.client.MkdirAll(path.Dir(fpath)); err != nil {
+		return err
+	}
+	tmp := fpath + ".tmp"
+	file, err := b.client.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	_, err = file.ReadFrom(bytes.NewReader(data))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return b.client.PosixRename(tmp, fpath)
+}
+
+func (b *sftpBackend) ReplaceThumbs(sha1 string, thumbs []Thumb) (err error) {
+	for _, t := range thumbs {
+		err = b.replaceFile(getThumbURL(DefaultUploadsRoot, t, sha1), t.Data)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (b *sftpBackend) DeleteThumbs(sha1 string, thumbs []Thumb) (err error) {
+	for _, t := range thumbs {
+		err = b.deleteFile(getThumbURL(DefaultUploadsRoot, t, sha1))
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (b *sftpBackend) readFile(fpath string) ([]byte, error) {
+	b.Lock()
+	defer b.Unlock()
+	if b.client == nil {
+		return nil, errNoConnection
+	}
+
+	file, err := b.client.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ioutil.ReadAll(file)
+}
+
+func (b *sftpBackend) ReadSource(sha1 string, fileType uint8) ([]byte, error) {
+	return b.readFile(getSFTPSourcePath(fileType, sha1))
+}
+
+func (b *sftpBackend) ReadThumb(sha1 string, t Thumb) ([]byte, error) {
+	return b.readFile(getThumbURL(DefaultUploadsRoot, t, sha1))
+}
+
+func (b *sftpBackend) List(fn func(Object) error) error {
+	for _, dir := range [...]string{srcDir, thumbDir} {
+		// Walk over the whole tree under lock would block uploads so
+		// take it only for the single step.
+		b.Lock()
+		if b.client == nil {
+			b.Unlock()
+			return errNoConnection
+		}
+		walker := b.client.Walk(path.Join(DefaultUploadsRoot, dir))
+		b.Unlock()
+		for {
+			b.Lock()
+			ok := walker.Step()
+			b.Unlock()
+			if !ok {
+				break
+			}
+			if err := walker.Err(); err != nil {
+				return err
+			}
+			info := walker.Stat()
+			if info.IsDir() {
+				continue
+			}
+			name := strings.TrimPrefix(walker.Path(), DefaultUploadsRoot+"/")
+			obj := Object{Name: name, Size: info.Size(), ModTime: info.ModTime()}
+			if err := fn(obj); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (b *sftpBackend) Stat(name string) (obj Object, err error) {
+	b.Lock()
+	defer b.Unlock()
+	if b.client == nil {
+		err = errNoConnection
+		return
+	}
+
+	info, err := b.client.Stat(path.Join(DefaultUploadsRoot, name))
+	if os.IsNotExist(err) {
+		err = ErrNotExist
+	}
+	if err != nil {
+		return
+	}
+	obj = Object{Name: name, Size: info.Size(), ModTime: info.ModTime()}
+	return
+}
+
+func (b *sftpBackend) Remove(name string) error {
+	return b.deleteFile(path.Join(DefaultUploadsRoot, name))
+}
+
+// Uploads server is supposed to check signatures the same way as fs
+// backend does.
+func (b *sftpBackend) SignedURL(name string, expires time.Time) string {
+	return getImageRoot() + "/" + name + "?" + getSignedQuery(name, expires)
+}
+
 func connect(addr string, conf *ssh.ClientConfig) (*sftp.Client, error) {
 	sshClient, err := ssh.Dial("tcp", addr, conf)
 	if err != nil {
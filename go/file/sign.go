@@ -0,0 +1,111 @@
+package file
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"log"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// Signed URLs are valid for at least this time. Expiration is rounded
+	// to hours so URLs of the same file are stable for a while and could
+	// be cached by browsers.
+	signedURLTTL = 24 * time.Hour
+	// Restricted state of files is cached for this time so unsigned
+	// requests don't hit DB every time.
+	restrictedCacheTTL = time.Minute
+	// Cache is dropped entirely when reaching this size.
+	maxRestrictedCache = 10000
+)
+
+var (
+	signKey []byte
+
+	// IsRestricted reports whether file must be served only via signed
+	// URL. Set by the server since file package can't access DB.
+	IsRestricted = func(sha1 string) (bool, error) {
+		return false, nil
+	}
+
+	restrictedMu    sync.Mutex
+	restrictedCache = make(map[string]restrictedEntry)
+)
+
+type restrictedEntry struct {
+	restricted bool
+	expires    time.Time
+}
+
+// Cached version of IsRestricted.
+func isRestrictedCached(sha1 string, now time.Time) (bool, error) {
+	restrictedMu.Lock()
+	e, ok := restrictedCache[sha1]
+	restrictedMu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.restricted, nil
+	}
+
+	restricted, err := IsRestricted(sha1)
+	if err != nil {
+		return false, err
+	}
+	restrictedMu.Lock()
+	defer restrictedMu.Unlock()
+	if len(restrictedCache) >= maxRestrictedCache {
+		restrictedCache = make(map[string]restrictedEntry)
+	}
+	restrictedCache[sha1] = restrictedEntry{
+		restricted: restricted,
+		expires:    now.Add(restrictedCacheTTL),
+	}
+	return restricted, nil
+}
+
+// Use random key if nothing configured, signed URLs will become invalid
+// after restart.
+func setSignKey(key string) {
+	if key != "" {
+		signKey = []byte(key)
+		return
+	}
+	log.Print("File sign key isn't set, using random one")
+	signKey = make([]byte, 32)
+	if _, err := rand.Read(signKey); err != nil {
+		panic(err)
+	}
+}
+
+func getSignExpiry(now time.Time) time.Time {
+	return now.Truncate(time.Hour).Add(signedURLTTL + time.Hour)
+}
+
+func getSignature(name string, expires int64) string {
+	h := hmac.New(sha256.New, signKey)
+	h.Write([]byte(name + "\n" + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Generic signed URL query for backends without own mechanism.
+func getSignedQuery(name string, expires time.Time) string {
+	exp := expires.Unix()
+	q := url.Values{
+		"expires":   {strconv.FormatInt(exp, 10)},
+		"signature": {getSignature(name, exp)},
+	}
+	return q.Encode()
+}
+
+func checkSignature(name string, q url.Values, now time.Time) bool {
+	exp, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil || exp < now.Unix() {
+		return false
+	}
+	sig := getSignature(name, exp)
+	return hmac.Equal([]byte(sig), []byte(q.Get("signature")))
+}
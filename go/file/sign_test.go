@@ -0,0 +1,91 @@
+package file
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSignedURL(t *testing.T) {
+	signKey = []byte("secret")
+	defer func() { IsRestricted = func(string) (bool, error) { return false, nil } }()
+	IsRestricted = func(sha1 string) (bool, error) {
+		return sha1 == "da39a3ee5e6b4b0d3255bfef95601890afd80709", nil
+	}
+
+	const name = "src/da/39a3ee5e6b4b0d3255bfef95601890afd80709.png"
+	now := time.Date(2018, 5, 1, 10, 30, 0, 0, time.UTC)
+	expires := getSignExpiry(now)
+	if expires.Sub(now) < signedURLTTL {
+		t.Fatalf("expiry is too soon: %v", expires)
+	}
+	q, err := url.ParseQuery(getSignedQuery(name, expires))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := [...]struct {
+		name string
+		path string
+		now  time.Time
+		ok   bool
+	}{
+		{"valid", name, now, true},
+		{"expired", name, expires.Add(time.Second), false},
+		{"other file", "src/da/39a3ee5e6b4b0d3255bfef95601890afd80709.jpg", now, false},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if ok := checkSignature(c.path, q, c.now); ok != c.ok {
+				t.Fatalf("expected %v, got %v", c.ok, ok)
+			}
+		})
+	}
+
+	r := httptest.NewRequest("GET", "/uploads/"+name, nil)
+	if canServe(name, r) {
+		t.Fatal("restricted file served without signature")
+	}
+	query := getSignedQuery(name, getSignExpiry(time.Now()))
+	r = httptest.NewRequest("GET", "/uploads/"+name+"?"+query, nil)
+	if !canServe("/"+name, r) {
+		t.Fatal("restricted file not served with signature")
+	}
+	public := "src/ab/cdef.png"
+	r = httptest.NewRequest("GET", "/uploads/"+public, nil)
+	if !canServe(public, r) {
+		t.Fatal("public file not served")
+	}
+}
+
+func TestRestrictedCache(t *testing.T) {
+	defer func() { IsRestricted = func(string) (bool, error) { return false, nil } }()
+	queries := 0
+	IsRestricted = func(sha1 string) (bool, error) {
+		queries++
+		return true, nil
+	}
+
+	const sha1 = "356a192b7913b04c5465c4f1b6c1f3b1e2c5ad8f"
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		restricted, err := isRestrictedCached(sha1, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !restricted {
+			t.Fatal("expected restricted")
+		}
+	}
+	if queries != 1 {
+		t.Fatalf("expected 1 query, got %d", queries)
+	}
+	if _, err := isRestrictedCached(sha1, now.Add(restrictedCacheTTL)); err != nil {
+		t.Fatal(err)
+	}
+	if queries != 2 {
+		t.Fatalf("expected cache to expire, got %d queries", queries)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/ncw/swift"
 )
@@ -11,6 +13,7 @@ import (
 type swiftBackend struct {
 	container string
 	conn      *swift.Connection
+	signKey   string
 }
 
 func (b *swiftBackend) IsServable() bool {
@@ -22,11 +25,12 @@ func (b *swiftBackend) Serve(w http.ResponseWriter, r *http.Request) {
 	panic("non-servable backend")
 }
 
-func getSwiftSourceName(fileType uint8, sha1 string) string {
+// Object storage names are the same as URL paths without root.
+func getObjectSourceName(fileType uint8, sha1 string) string {
 	return getImageURL("", srcDir, fileType, sha1)
 }
 
-func getSwiftThumbName(thumbType uint8, sha1 string) string {
+func getObjectThumbName(thumbType uint8, sha1 string) string {
 	return getImageURL("", thumbDir, thumbType, sha1)
 }
 
@@ -55,12 +59,12 @@ func (b *swiftBackend) Write(sha1 string, fileType, thumbType uint8, src, thumb
 	ch := make(chan error)
 	go func() {
 		// Full path logging might be useful for later manual PURGE.
-		log.Printf("[swift] creating <%s>", SourcePath(fileType, sha1))
-		ch <- b.writeFile(getSwiftSourceName(fileType, sha1), src)
+		log.Printf("[swift] creating <%s>", sourcePath(fileType, sha1))
+		ch <- b.writeFile(getObjectSourceName(fileType, sha1), src)
 	}()
 	go func() {
-		log.Printf("[swift] creating <%s>", ThumbPath(thumbType, sha1))
-		ch <- b.writeFile(getSwiftThumbName(thumbType, sha1), thumb)
+		log.Printf("[swift] creating <%s>", thumbPath(thumbType, sha1))
+		ch <- b.writeFile(getObjectThumbName(thumbType, sha1), thumb)
 	}()
 	for _, err := range [...]error{<-ch, <-ch} {
 		if err != nil {
@@ -86,12 +90,12 @@ func (b *swiftBackend) deleteFile(name string) (err error) {
 func (b *swiftBackend) Delete(sha1 string, fileType, thumbType uint8) error {
 	ch := make(chan error)
 	go func() {
-		log.Printf("[swift] deleting <%s>", SourcePath(fileType, sha1))
-		ch <- b.deleteFile(getSwiftSourceName(fileType, sha1))
+		log.Printf("[swift] deleting <%s>", sourcePath(fileType, sha1))
+		ch <- b.deleteFile(getObjectSourceName(fileType, sha1))
 	}()
 	go func() {
-		log.Printf("[swift] deleting <%s>", ThumbPath(thumbType, sha1))
-		ch <- b.deleteFile(getSwiftThumbName(thumbType, sha1))
+		log.Printf("[swift] deleting <%s>", thumbPath(thumbType, sha1))
+		ch <- b.deleteFile(getObjectThumbName(thumbType, sha1))
 	}()
 	for _, err := range [...]error{<-ch, <-ch} {
 		if err != nil {
@@ -101,6 +105,92 @@ func (b *swiftBackend) Delete(sha1 string, fileType, thumbType uint8) error {
 	return nil
 }
 
+func (b *swiftBackend) WriteThumbs(sha1 string, thumbs []Thumb) error {
+	for _, t := range thumbs {
+		name := getThumbURL("", t, sha1)
+		log.Printf("[swift] creating <%s>", getThumbURL(getImageRoot(), t, sha1))
+		if err := b.writeFile(name, t.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Objects are overwritten atomically.
+func (b *swiftBackend) ReplaceThumbs(sha1 string, thumbs []Thumb) error {
+	return b.WriteThumbs(sha1, thumbs)
+}
+
+func (b *swiftBackend) DeleteThumbs(sha1 string, thumbs []Thumb) error {
+	for _, t := range thumbs {
+		name := getThumbURL("", t, sha1)
+		log.Printf("[swift] deleting <%s>", getThumbURL(getImageRoot(), t, sha1))
+		if err := b.deleteFile(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *swiftBackend) readFile(name string) (data []byte, err error) {
+	data, err = b.conn.ObjectGetBytes(b.container, name)
+	if err != nil {
+		err = fmt.Errorf("cannot read Swift object %s from %s: %v", name, b.container, err)
+	}
+	return
+}
+
+func (b *swiftBackend) ReadSource(sha1 string, fileType uint8) ([]byte, error) {
+	return b.readFile(getObjectSourceName(fileType, sha1))
+}
+
+func (b *swiftBackend) ReadThumb(sha1 string, t Thumb) ([]byte, error) {
+	return b.readFile(getThumbURL("", t, sha1))
+}
+
+func (b *swiftBackend) List(fn func(Object) error) error {
+	return b.conn.ObjectsWalk(b.container, nil, func(opts *swift.ObjectsOpts) (interface{}, error) {
+		objs, err := b.conn.Objects(b.container, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range objs {
+			obj := Object{
+				Name:    strings.TrimPrefix(o.Name, "/"),
+				Size:    o.Bytes,
+				ModTime: o.LastModified,
+			}
+			if err := fn(obj); err != nil {
+				return nil, err
+			}
+		}
+		return objs, nil
+	})
+}
+
+func (b *swiftBackend) Stat(name string) (obj Object, err error) {
+	info, _, err := b.conn.Object(b.container, "/"+name)
+	if err == swift.ObjectNotFound {
+		err = ErrNotExist
+	}
+	if err != nil {
+		return
+	}
+	obj = Object{Name: name, Size: info.Bytes, ModTime: info.LastModified}
+	return
+}
+
+func (b *swiftBackend) Remove(name string) error {
+	log.Printf("[swift] deleting <%s>", getImageRoot()+"/"+name)
+	return b.deleteFile("/" + name)
+}
+
+// Use Swift temp URL, sign key must be set as temp URL key of the
+// account.
+func (b *swiftBackend) SignedURL(name string, expires time.Time) string {
+	return b.conn.ObjectTempUrl(b.container, "/"+name, b.signKey, "GET", expires)
+}
+
 func makeSwiftBackend(conf Config) (b fileBackend, err error) {
 	c := swift.Connection{
 		UserName: conf.Username,
@@ -111,6 +201,6 @@ func makeSwiftBackend(conf Config) (b fileBackend, err error) {
 		err = fmt.Errorf("cannot authenticate for Swift: %v", err)
 		return
 	}
-	b = &swiftBackend{container: conf.Container, conn: &c}
+	b = &swiftBackend{container: conf.Container, conn: &c, signKey: conf.SignKey}
 	return
 }
@@ -3,6 +3,7 @@
 package ipc
 
 import (
+	"context"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
@@ -11,12 +12,17 @@ import (
 	"os"
 	"os/exec"
 	"syscall"
+	"time"
 )
 
 const (
 	THUMB_CMD             = "cutethumb"
 	THUMB_ERROR_EXIT_CODE = 100
 	MAX_OBJ_LEN           = 65535
+	// Limit of a single job of HTTP worker including the time spent in
+	// queue. Must be less than client timeout so worker reports it as
+	// thumbnailer error instead of being marked down.
+	THUMB_JOB_TIMEOUT = 90 * time.Second
 )
 
 var (
@@ -24,6 +30,7 @@ var (
 	ErrThumbUnsupported = errors.New("unsupported file format")
 	ErrThumbDimensions  = errors.New("unsupported file dimensions")
 	ErrThumbTracks      = errors.New("unsupported track set")
+	ErrThumbTimeout     = errors.New("file processing timed out")
 )
 
 type Thumb struct {
@@ -37,13 +44,30 @@ type Thumb struct {
 	Height    uint16
 	Duration  uint32
 	Title     string
-	Data      []byte `json:"-"`
+	// Box size of the base thumbnail.
+	Size     uint16
+	Variants []ThumbVariant
+	Data     []byte `json:"-"`
+}
+
+// Additional rendition of the thumbnail, e.g. retina size or WebP copy
+// of the base one.
+type ThumbVariant struct {
+	Mime   string
+	Size   uint16
+	Width  uint16
+	Height uint16
+	Len    int
+	Data   []byte `json:"-"`
 }
 
 // Use LOB-alike encoding:
 // [ VARUINT JSON LENGTH ] [ JSON ] [ DATA ]
 // See: https://github.com/telehash/telehash.github.io/blob/master/v3/lob.md
 func (t *Thumb) Marshal() (data []byte, err error) {
+	for i := range t.Variants {
+		t.Variants[i].Len = len(t.Variants[i].Data)
+	}
 	objData, err := json.Marshal(t)
 	if err != nil {
 		err = fmt.Errorf("thumbnailer marshal error: %v", err)
@@ -56,6 +80,9 @@ func (t *Thumb) Marshal() (data []byte, err error) {
 	data = data[:n]
 	data = append(data, objData...)
 	data = append(data, t.Data...)
+	for _, v := range t.Variants {
+		data = append(data, v.Data...)
+	}
 	return
 }
 
@@ -73,7 +100,23 @@ func unmarshalThumb(data []byte) (thumb *Thumb, err error) {
 		err = fmt.Errorf("thumbnailer unmarshal error: %v", err)
 		return
 	}
-	thumb.Data = data[objLen+n:]
+	// Variants are stored after the base thumbnail, in order.
+	data = data[objLen+n:]
+	tailLen := 0
+	for _, v := range thumb.Variants {
+		tailLen += v.Len
+	}
+	if tailLen > len(data) {
+		err = fmt.Errorf("thumbnailer variants too long: %d", tailLen)
+		return
+	}
+	thumb.Data = data[:len(data)-tailLen]
+	data = data[len(data)-tailLen:]
+	for i := range thumb.Variants {
+		v := &thumb.Variants[i]
+		v.Data = data[:v.Len]
+		data = data[v.Len:]
+	}
 	return
 }
 
@@ -84,6 +127,7 @@ func decodeThumbError(s string) error {
 		ErrThumbUnsupported,
 		ErrThumbDimensions,
 		ErrThumbTracks,
+		ErrThumbTimeout,
 	} {
 		if s == e.Error() {
 			return e
@@ -112,11 +156,36 @@ func getCmdLine(user string) (name string, args []string) {
 	return
 }
 
-// Abstract thumbnailer IPC.
+// Abstract thumbnailer IPC. Remote workers are tried first if
+// configured, local process is used if all of them are down.
 func GetThumbnail(user string, srcData []byte) (thumb *Thumb, err error) {
-	// Start process.
+	if remote != nil {
+		thumb, err = remote.getThumbnail(srcData)
+		if err != errWorkerDown {
+			return
+		}
+	}
+	return runThumbnailer(user, srcData)
+}
+
+func runThumbnailer(user string, srcData []byte) (thumb *Thumb, err error) {
 	name, args := getCmdLine(user)
-	cmd := exec.Command(name, args...)
+	return runThumbnailerCmd(exec.Command(name, args...), srcData)
+}
+
+// RunThumbnailerContext processes file in a separate thumbnailer process
+// at path which is killed once context is done. Used by HTTP workers so
+// malicious file can't hang or crash the worker itself.
+func RunThumbnailerContext(ctx context.Context, path string, srcData []byte) (thumb *Thumb, err error) {
+	thumb, err = runThumbnailerCmd(exec.CommandContext(ctx, path), srcData)
+	if ctx.Err() != nil {
+		thumb, err = nil, ErrThumbTimeout
+	}
+	return
+}
+
+func runThumbnailerCmd(cmd *exec.Cmd, srcData []byte) (thumb *Thumb, err error) {
+	// Start process.
 	cmd.Stderr = os.Stderr
 	in, err := cmd.StdinPipe()
 	if err != nil {
@@ -0,0 +1,75 @@
+package ipc
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/cutechan/cutechan/go/test"
+)
+
+func TestThumbMarshal(t *testing.T) {
+	t.Parallel()
+
+	thumb := Thumb{
+		Mime:  "image/png",
+		Size:  200,
+		Title: "test",
+		Data:  []byte{1, 2, 3},
+		Variants: []ThumbVariant{
+			{Mime: "image/png", Size: 400, Data: []byte{4, 5, 6, 7}},
+			{Mime: "image/webp", Size: 200, Data: []byte{8}},
+		},
+	}
+	data, err := thumb.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := unmarshalThumb(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, *res, thumb)
+}
+
+func TestThumbUnmarshalNoVariants(t *testing.T) {
+	t.Parallel()
+
+	thumb := Thumb{Mime: "image/jpeg", Data: []byte{1, 2, 3}}
+	data, err := thumb.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := unmarshalThumb(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertBufferEquals(t, res.Data, thumb.Data)
+	if len(res.Variants) != 0 {
+		LogUnexpected(t, 0, len(res.Variants))
+	}
+}
+
+func TestRunThumbnailerTimeout(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "cutechan-ipc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "hang")
+	err = ioutil.WriteFile(path, []byte("#!/bin/sh\nexec sleep 10\n"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := RunThumbnailerContext(ctx, path, []byte{1}); err != ErrThumbTimeout {
+		LogUnexpected(t, ErrThumbTimeout, err)
+	}
+}
@@ -0,0 +1,218 @@
+package ipc
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	THUMB_HTTP_PATH    = "/thumb"
+	HEALTH_HTTP_PATH   = "/health"
+	SECRET_HTTP_HEADER = "X-Thumb-Secret"
+	// Source files are limited by the web server, this is just a guard.
+	MAX_HTTP_SRC_LEN = 256 << 20
+
+	remoteTimeout       = 2 * time.Minute
+	healthCheckInterval = 10 * time.Second
+	healthCheckTimeout  = 5 * time.Second
+)
+
+var (
+	errWorkerDown = errors.New("thumbnail worker is down")
+
+	// Pool of remote thumbnailers, local process is used if nil.
+	remote *workerPool
+)
+
+// Remote thumbnailers serving jobs over HTTP. Workers are picked in
+// round-robin order, failed ones are skipped until health check marks
+// them alive again.
+type workerPool struct {
+	urls   []string
+	secret string
+	client *http.Client
+	// Protects fields below.
+	mu      sync.Mutex
+	healthy []bool
+	next    int
+}
+
+// StartRemoteWorkers makes GetThumbnail to send jobs to the specified
+// worker URLs, falling back to the local process if none is available.
+func StartRemoteWorkers(urls []string, secret string) {
+	if len(urls) == 0 {
+		return
+	}
+	remote = newWorkerPool(urls, secret)
+	go remote.healthLoop()
+}
+
+func newWorkerPool(urls []string, secret string) *workerPool {
+	p := &workerPool{
+		urls:    make([]string, len(urls)),
+		secret:  secret,
+		client:  &http.Client{Timeout: remoteTimeout},
+		healthy: make([]bool, len(urls)),
+	}
+	for i, u := range urls {
+		p.urls[i] = strings.TrimSuffix(u, "/")
+		// Optimistically assume alive until first check.
+		p.healthy[i] = true
+	}
+	return p
+}
+
+// Get order of workers to try for the next job. Healthy ones go first in
+// round-robin order.
+func (p *workerPool) order() (order []int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := len(p.urls)
+	start := p.next
+	p.next = (p.next + 1) % n
+	for i := 0; i < n; i++ {
+		j := (start + i) % n
+		if p.healthy[j] {
+			order = append(order, j)
+		}
+	}
+	return
+}
+
+func (p *workerPool) setHealthy(i int, healthy bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.healthy[i] != healthy {
+		state := "down"
+		if healthy {
+			state = "up"
+		}
+		log.Printf("thumbnail worker %s is %s", p.urls[i], state)
+	}
+	p.healthy[i] = healthy
+}
+
+// Try healthy workers in order. Thumbnailer errors are returned as is
+// since another worker would fail the same way.
+func (p *workerPool) getThumbnail(srcData []byte) (thumb *Thumb, err error) {
+	err = errWorkerDown
+	for _, i := range p.order() {
+		thumb, err = p.request(p.urls[i], srcData)
+		if err != errWorkerDown {
+			return
+		}
+		p.setHealthy(i, false)
+	}
+	return
+}
+
+func (p *workerPool) request(url string, srcData []byte) (thumb *Thumb, err error) {
+	req, err := http.NewRequest("POST", url+THUMB_HTTP_PATH, bytes.NewReader(srcData))
+	if err != nil {
+		return
+	}
+	req.Header.Set(SECRET_HTTP_HEADER, p.secret)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	res, err := p.client.Do(req)
+	if err != nil {
+		log.Printf("thumbnail worker %s error: %v", url, err)
+		return nil, errWorkerDown
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		log.Printf("thumbnail worker %s error: %v", url, err)
+		return nil, errWorkerDown
+	}
+	switch res.StatusCode {
+	case http.StatusOK:
+		return unmarshalThumb(data)
+	case http.StatusUnprocessableEntity:
+		return nil, decodeThumbError(string(data))
+	default:
+		log.Printf("thumbnail worker %s error: %s", url, res.Status)
+		return nil, errWorkerDown
+	}
+}
+
+func (p *workerPool) healthLoop() {
+	for {
+		time.Sleep(healthCheckInterval)
+		p.checkHealth()
+	}
+}
+
+func (p *workerPool) checkHealth() {
+	client := &http.Client{Timeout: healthCheckTimeout}
+	for i, url := range p.urls {
+		req, err := http.NewRequest("GET", url+HEALTH_HTTP_PATH, nil)
+		if err != nil {
+			p.setHealthy(i, false)
+			continue
+		}
+		req.Header.Set(SECRET_HTTP_HEADER, p.secret)
+		res, err := client.Do(req)
+		if err != nil {
+			p.setHealthy(i, false)
+			continue
+		}
+		res.Body.Close()
+		p.setHealthy(i, res.StatusCode == http.StatusOK)
+	}
+}
+
+// ThumbHandler serves thumbnailing jobs for remote clients. Source file
+// is passed as request body, response uses the same encoding as local
+// process output. Known thumbnailer errors are reported with 422 code.
+func ThumbHandler(secret string, getThumbnail func([]byte) (*Thumb, error)) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(HEALTH_HTTP_PATH, func(w http.ResponseWriter, r *http.Request) {
+		if !checkSecret(r, secret) {
+			http.Error(w, "403 forbidden", 403)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc(THUMB_HTTP_PATH, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "405 method not allowed", 405)
+			return
+		}
+		if !checkSecret(r, secret) {
+			http.Error(w, "403 forbidden", 403)
+			return
+		}
+		srcData, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MAX_HTTP_SRC_LEN))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("400 %v", err), 400)
+			return
+		}
+		thumb, err := getThumbnail(srcData)
+		if err != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		data, err := thumb.Marshal()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("500 %v", err), 500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(data)
+	})
+	return mux
+}
+
+func checkSecret(r *http.Request, secret string) bool {
+	got := r.Header.Get(SECRET_HTTP_HEADER)
+	return subtle.ConstantTimeCompare([]byte(got), []byte(secret)) == 1
+}
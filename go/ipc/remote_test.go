@@ -0,0 +1,65 @@
+package ipc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/cutechan/cutechan/go/test"
+)
+
+func fakeThumbnail(srcData []byte) (*Thumb, error) {
+	switch string(srcData) {
+	case "bad":
+		return nil, ErrThumbUnsupported
+	case "slow":
+		return nil, ErrThumbTimeout
+	}
+	return &Thumb{Mime: "image/png", Size: 200, Data: srcData}, nil
+}
+
+func TestRemoteWorkers(t *testing.T) {
+	t.Parallel()
+
+	const secret = "secret"
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "502 bad gateway", 502)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(ThumbHandler(secret, fakeThumbnail))
+	defer up.Close()
+
+	p := newWorkerPool([]string{down.URL, up.URL + "/"}, secret)
+	for i := 0; i < 2; i++ {
+		thumb, err := p.getThumbnail([]byte{1, 2, 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		AssertDeepEquals(t, thumb.Data, []byte{1, 2, 3})
+	}
+	AssertDeepEquals(t, p.healthy, []bool{false, true})
+
+	// Known errors aren't retried on other workers.
+	if _, err := p.getThumbnail([]byte("bad")); err != ErrThumbUnsupported {
+		LogUnexpected(t, ErrThumbUnsupported, err)
+	}
+	AssertDeepEquals(t, p.healthy, []bool{false, true})
+
+	// Timed out job isn't rerun either.
+	if _, err := p.getThumbnail([]byte("slow")); err != ErrThumbTimeout {
+		LogUnexpected(t, ErrThumbTimeout, err)
+	}
+	AssertDeepEquals(t, p.healthy, []bool{false, true})
+
+	p.checkHealth()
+	AssertDeepEquals(t, p.healthy, []bool{false, true})
+
+	// Wrong secret makes worker unavailable so caller falls back to local
+	// thumbnailer.
+	p = newWorkerPool([]string{up.URL}, "wrong")
+	if _, err := p.getThumbnail([]byte{1}); err != errWorkerDown {
+		LogUnexpected(t, errWorkerDown, err)
+	}
+	p.checkHealth()
+	AssertDeepEquals(t, p.healthy, []bool{false})
+}
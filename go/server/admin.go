@@ -5,25 +5,30 @@ package server
 import (
 	"database/sql"
 	"fmt"
+	"net"
 	"net/http"
 	"reflect"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cutechan/cutechan/go/auth"
+	"github.com/cutechan/cutechan/go/automod"
 	"github.com/cutechan/cutechan/go/common"
 	"github.com/cutechan/cutechan/go/config"
 	"github.com/cutechan/cutechan/go/db"
 	"github.com/cutechan/cutechan/go/feeds"
+	"github.com/cutechan/cutechan/go/file"
 	"github.com/cutechan/cutechan/go/lang"
+	"github.com/cutechan/cutechan/go/parser"
 	"github.com/cutechan/cutechan/go/templates"
 )
 
 var (
 	boardNameValidation = regexp.MustCompile(`^[a-z0-9]{1,10}$`)
 	reservedBoards      = [...]string{
-		"all", "stickers", "admin",
+		"all", "stickers", "admin", "banned",
 		"html", "api",
 		"static", "uploads",
 	}
@@ -195,19 +200,55 @@ func createBoard(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// Delete a board owned by the client
+// Delete a board owned by the client. Board is only hidden at first and
+// can be restored until it's purged.
 func deleteBoard(w http.ResponseWriter, r *http.Request) {
 	var msg boardActionRequest
-	if !decodeJSON(w, r, &msg) {
+	if err := readJSON(r, &msg); err != nil {
+		serveErrorJSON(w, r, err)
 		return
 	}
-	_, ok := assertCanPerform(w, r, msg.Board, auth.BoardOwner)
-	if !ok {
+	if msg.Board == "all" || !config.IsBoard(msg.Board) {
+		serveErrorJSON(w, r, aerrInvalidBoard)
+		return
+	}
+	ss, _ := getSession(r, msg.Board)
+	if !canPerform(ss, auth.BoardOwner) {
+		serveErrorJSON(w, r, aerrBoardOwnersOnly)
 		return
 	}
 
-	if err := db.DeleteBoard(msg.Board); err != nil {
-		text500(w, r, err)
+	switch err := db.DeleteBoard(msg.Board, ss.UserID); err {
+	case nil:
+		serveEmptyJSON(w, r)
+	case sql.ErrNoRows:
+		serveErrorJSON(w, r, aerrInvalidBoard)
+	default:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+	}
+}
+
+// Restore a deleted board. Board config is gone at this point so
+// ownership is checked by the query.
+func restoreBoard(w http.ResponseWriter, r *http.Request) {
+	var msg boardActionRequest
+	if err := readJSON(r, &msg); err != nil {
+		serveErrorJSON(w, r, err)
+		return
+	}
+	ss, _ := getSession(r, "")
+	if ss == nil {
+		serveErrorJSON(w, r, aerrBoardOwnersOnly)
+		return
+	}
+
+	switch err := db.RestoreBoard(msg.Board, ss.UserID); err {
+	case nil:
+		serveEmptyJSON(w, r)
+	case sql.ErrNoRows:
+		serveErrorJSON(w, r, aerrNoDeletedBoard)
+	default:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
 	}
 }
 
@@ -228,6 +269,55 @@ func deletePost(w http.ResponseWriter, r *http.Request) {
 	moderatePosts(w, r, auth.Moderator, db.DeletePost)
 }
 
+// Spoiler a single file of the post
+func spoilerFile(w http.ResponseWriter, r *http.Request) {
+	moderateFile(w, r, auth.Moderator, db.SpoilerImage)
+}
+
+// Delete a single file of the post keeping the post text
+func deleteFile(w http.ResponseWriter, r *http.Request) {
+	moderateFile(w, r, auth.Moderator, db.DeleteImage)
+}
+
+// Perform a moderation action on n-th file of the post
+func moderateFile(
+	w http.ResponseWriter,
+	r *http.Request,
+	level auth.ModerationLevel,
+	fn func(id uint64, n int, userID string) error,
+) {
+	var msg struct {
+		ID   uint64
+		File int
+	}
+	if !decodeJSON(w, r, &msg) {
+		return
+	}
+	if msg.File < 0 {
+		text400(w, errNoImage)
+		return
+	}
+	board, userID, can := canModeratePost(w, r, msg.ID, level)
+	if !can {
+		return
+	}
+	switch err := fn(msg.ID, msg.File, userID); err {
+	case nil:
+	case sql.ErrNoRows:
+		text400(w, errNoImage)
+		return
+	default:
+		text500(w, r, err)
+		return
+	}
+	// Counters have one second precision so changes made right after
+	// caching might be missed otherwise.
+	if op, err := db.GetPostOP(msg.ID); err == nil {
+		invalidateThread(op, board)
+	}
+	serveEmptyJSON(w, r)
+}
+
 // Perform a moderation action an a single post. If ok == false, the caller
 // should return.
 func moderatePost(
@@ -284,6 +374,7 @@ func ban(w http.ResponseWriter, r *http.Request) {
 		Global   bool
 		Duration uint64
 		Reason   string
+		Shadow   bool
 		IDs      []uint64
 	}
 
@@ -335,23 +426,259 @@ func ban(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Apply bans
+	typ := auth.RegularBan
+	if msg.Shadow {
+		typ = auth.ShadowBan
+	}
 	expires := time.Now().Add(time.Duration(msg.Duration) * time.Minute)
 	for board, ids := range byBoard {
-		ips, err := db.Ban(board, msg.Reason, ss.UserID, expires, ids...)
+		err := applyBan(board, msg.Reason, ss.UserID, expires, typ, ids...)
 		if err != nil {
 			text500(w, r, err)
 			return
 		}
+	}
 
-		// Redirect all banned connected clients to the /all/ board
-		for ip := range ips {
-			for _, cl := range common.GetByIPAndBoard(ip, board) {
-				cl.Redirect("all")
-			}
+	serveEmptyJSON(w, r)
+}
+
+// Ban authors of the posts on the board and kick them out of it.
+// Shadow banned authors are left in place to not reveal the ban.
+func applyBan(
+	board, reason, by string,
+	expires time.Time,
+	typ auth.BanType,
+	ids ...uint64,
+) error {
+	ips, err := db.Ban(board, reason, by, expires, typ, ids...)
+	if err != nil || typ == auth.ShadowBan {
+		return err
+	}
+
+	// Redirect all banned connected clients to the /all/ board
+	for ip := range ips {
+		n := auth.DefaultBanRange(net.ParseIP(ip))
+		if n == nil {
+			continue
+		}
+		for _, cl := range feeds.GetByIPNetAndBoard(n, board) {
+			cl.Redirect("all")
 		}
 	}
+	return nil
+}
 
-	serveEmptyJSON(w, r)
+// Assert user can manage bans of the board. Global bans are only for
+// admin.
+func assertCanBanAPI(
+	w http.ResponseWriter,
+	r *http.Request,
+	board string,
+) (ss *auth.Session, ok bool) {
+	level := auth.Moderator
+	if board == "all" {
+		level = auth.Admin
+	} else if !config.IsBoard(board) {
+		serveErrorJSON(w, r, aerrInvalidBoard)
+		return
+	}
+	ss, _ = getSession(r, board)
+	if !canPerform(ss, level) {
+		serveErrorJSON(w, r, aerrAccessDenied)
+		return
+	}
+	ok = true
+	return
+}
+
+// Serve ban list of the board.
+func serveBans(w http.ResponseWriter, r *http.Request) {
+	board := getParam(r, "board")
+	if _, ok := assertCanBanAPI(w, r, board); !ok {
+		return
+	}
+	bans, err := db.GetBans(nil, []string{board})
+	if err != nil {
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	serveJSON(w, r, bans)
+}
+
+// Ban IP address or CIDR range directly, without targeting posts.
+func banRange(w http.ResponseWriter, r *http.Request) {
+	var msg struct {
+		Board    string
+		Range    string
+		Reason   string
+		Duration uint64
+		Shadow   bool
+	}
+	if err := readJSON(r, &msg); err != nil {
+		serveErrorJSON(w, r, err)
+		return
+	}
+	n, err := auth.ParseBanRange(msg.Range)
+	if err != nil {
+		serveErrorJSON(w, r, aerrInvalidBanRange)
+		return
+	}
+	if msg.Reason == "" || len(msg.Reason) > common.MaxBanReasonLength {
+		serveErrorJSON(w, r, aerrInvalidReason)
+		return
+	}
+	if msg.Duration == 0 {
+		serveErrorJSON(w, r, aerrNoDuration)
+		return
+	}
+	ss, ok := assertCanBanAPI(w, r, msg.Board)
+	if !ok {
+		return
+	}
+
+	typ := auth.RegularBan
+	if msg.Shadow {
+		typ = auth.ShadowBan
+	}
+	rec := auth.BanRecord{
+		Ban: auth.Ban{
+			IP:    auth.FormatBanRange(n),
+			Board: msg.Board,
+			Type:  typ,
+		},
+		By:      ss.UserID,
+		Expires: time.Now().Add(time.Duration(msg.Duration) * time.Minute).Unix(),
+		Reason:  msg.Reason,
+	}
+	expires := time.Unix(rec.Expires, 0)
+	err = db.BanRange(rec.Board, rec.IP, rec.Reason, rec.By, expires, typ)
+	if err != nil {
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	if typ == auth.RegularBan {
+		for _, cl := range feeds.GetByIPNetAndBoard(n, msg.Board) {
+			cl.Redirect("all")
+		}
+	}
+	serveJSON(w, r, rec)
+}
+
+// Ban registered account, so it can't post from any IP.
+func banAccount(w http.ResponseWriter, r *http.Request) {
+	var msg struct {
+		Board    string
+		Account  string
+		Reason   string
+		Duration uint64
+		Shadow   bool
+	}
+	if err := readJSON(r, &msg); err != nil {
+		serveErrorJSON(w, r, err)
+		return
+	}
+	if !checkUserID(msg.Account) {
+		serveErrorJSON(w, r, aerrInvalidUserID)
+		return
+	}
+	if msg.Reason == "" || len(msg.Reason) > common.MaxBanReasonLength {
+		serveErrorJSON(w, r, aerrInvalidReason)
+		return
+	}
+	if msg.Duration == 0 {
+		serveErrorJSON(w, r, aerrNoDuration)
+		return
+	}
+	ss, ok := assertCanBanAPI(w, r, msg.Board)
+	if !ok {
+		return
+	}
+
+	typ := auth.RegularBan
+	if msg.Shadow {
+		typ = auth.ShadowBan
+	}
+	rec := auth.BanRecord{
+		Ban: auth.Ban{
+			Account: msg.Account,
+			Board:   msg.Board,
+			Type:    typ,
+		},
+		By:      ss.UserID,
+		Expires: time.Now().Add(time.Duration(msg.Duration) * time.Minute).Unix(),
+		Reason:  msg.Reason,
+	}
+	expires := time.Unix(rec.Expires, 0)
+	err := db.BanAccount(rec.Board, rec.Account, rec.Reason, rec.By, expires, typ)
+	switch {
+	case err == nil:
+		serveJSON(w, r, rec)
+	case db.IsForeignKeyViolationError(err):
+		serveErrorJSON(w, r, aerrNoAccount)
+	default:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+	}
+}
+
+// Lift ban of IP address or CIDR range, exactly as it's stored in ban
+// list.
+func unbanRange(w http.ResponseWriter, r *http.Request) {
+	var msg struct {
+		Board string
+		Range string
+	}
+	if err := readJSON(r, &msg); err != nil {
+		serveErrorJSON(w, r, err)
+		return
+	}
+	if _, _, err := net.ParseCIDR(msg.Range); err != nil {
+		if net.ParseIP(msg.Range) == nil {
+			serveErrorJSON(w, r, aerrInvalidBanRange)
+			return
+		}
+	}
+	ss, ok := assertCanBanAPI(w, r, msg.Board)
+	if !ok {
+		return
+	}
+
+	switch err := db.UnbanRange(msg.Board, msg.Range, ss.UserID); err {
+	case nil:
+		serveEmptyJSON(w, r)
+	case sql.ErrNoRows:
+		serveErrorJSON(w, r, aerrNoBan)
+	default:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+	}
+}
+
+// Lift ban of the account regardless of IP.
+func unbanAccount(w http.ResponseWriter, r *http.Request) {
+	var msg struct {
+		Board   string
+		Account string
+	}
+	if err := readJSON(r, &msg); err != nil {
+		serveErrorJSON(w, r, err)
+		return
+	}
+	if !checkUserID(msg.Account) {
+		serveErrorJSON(w, r, aerrInvalidUserID)
+		return
+	}
+	ss, ok := assertCanBanAPI(w, r, msg.Board)
+	if !ok {
+		return
+	}
+
+	switch err := db.UnbanAccount(msg.Board, msg.Account, ss.UserID); err {
+	case nil:
+		serveEmptyJSON(w, r)
+	case sql.ErrNoRows:
+		serveErrorJSON(w, r, aerrNoBan)
+	default:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+	}
 }
 
 // Unban a specific board -> banned post combination
@@ -401,59 +728,281 @@ func unban(w http.ResponseWriter, r *http.Request) {
 // Send a textual message to all connected clients
 func sendNotification(w http.ResponseWriter, r *http.Request) {
 	var msg string
-	if !decodeJSON(w, r, &msg) || !isAdmin(w, r) {
+	if err := readJSON(r, &msg); err != nil {
+		serveErrorJSON(w, r, err)
+		return
+	}
+	ss, _ := getSession(r, "")
+	if ss == nil || ss.UserID != "admin" {
+		serveErrorJSON(w, r, aerrAdminOnly)
+		return
+	}
+	msg = strings.TrimSpace(msg)
+	if msg == "" || len(msg) > common.MaxLenNotification {
+		serveErrorJSON(w, r, aerrBadNotification)
 		return
 	}
 
 	data, err := common.EncodeMessage(common.MessageNotification, msg)
 	if err != nil {
-		text500(w, r, err)
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	if err := db.LogNotification(ss.UserID); err != nil {
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
 		return
 	}
 	for _, cl := range feeds.All() {
 		cl.Send(data)
 	}
+	serveEmptyJSON(w, r)
 }
 
 // Retrieve posts with the same IP on the target board
 func getSameIPPosts(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseUint(getParam(r, "id"), 10, 64)
 	if err != nil {
-		text400(w, err)
+		serveErrorJSON(w, r, aerrNoPost)
 		return
 	}
-	board, _, ok := canModeratePost(w, r, id, auth.Moderator)
+	board, _, ok := assertCanModeratePostAPI(w, r, id, auth.Moderator)
 	if !ok {
 		return
 	}
 
 	posts, err := db.GetSameIPPosts(id, board)
 	if err != nil {
-		text500(w, r, err)
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
 		return
 	}
+	for i := range posts {
+		file.SignFiles(&posts[i].Post)
+	}
 	serveJSON(w, r, posts)
 }
 
+// Delete and/or ban all recent posts from the IP of the target post, on
+// its board or across all boards for admin.
+func purgePosts(w http.ResponseWriter, r *http.Request) {
+	var msg struct {
+		ID       uint64
+		Global   bool
+		Window   uint64
+		Delete   bool
+		Ban      bool
+		Reason   string
+		Duration uint64
+	}
+	if err := readJSON(r, &msg); err != nil {
+		serveErrorJSON(w, r, err)
+		return
+	}
+	if (!msg.Delete && !msg.Ban) ||
+		msg.Window == 0 || msg.Window > common.MaxPurgeWindow {
+		serveErrorJSON(w, r, aerrInvalidPurge)
+		return
+	}
+	if msg.Ban {
+		if msg.Reason == "" || len(msg.Reason) > common.MaxBanReasonLength {
+			serveErrorJSON(w, r, aerrInvalidReason)
+			return
+		}
+		if msg.Duration == 0 {
+			serveErrorJSON(w, r, aerrNoDuration)
+			return
+		}
+	}
+	board, ss, ok := assertCanModeratePostAPI(w, r, msg.ID, auth.Moderator)
+	if !ok {
+		return
+	}
+	if msg.Global {
+		board = "all"
+		if ss, ok = assertCanBanAPI(w, r, board); !ok {
+			return
+		}
+	}
+	// Post is gone after the purge
+	ip, err := db.GetIP(msg.ID)
+	if err != nil {
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+
+	now := time.Now()
+	req := db.PurgeRequest{
+		ID:      msg.ID,
+		Board:   board,
+		Since:   now.Add(-time.Duration(msg.Window) * time.Minute),
+		Delete:  msg.Delete,
+		Ban:     msg.Ban,
+		Reason:  msg.Reason,
+		By:      ss.UserID,
+		Expires: now.Add(time.Duration(msg.Duration) * time.Minute),
+	}
+	res, err := db.PurgePosts(req)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		serveErrorJSON(w, r, aerrNothingToPurge)
+		return
+	default:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+
+	if len(res.Threads) != 0 {
+		invalidateBoards(res.Boards...)
+	}
+	if n := auth.DefaultBanRange(net.ParseIP(ip)); res.Banned && n != nil {
+		for _, cl := range feeds.GetByIPNetAndBoard(n, board) {
+			cl.Redirect("all")
+		}
+	}
+	serveJSON(w, r, res)
+}
+
 // Set the sticky flag of a thread
 func setThreadSticky(w http.ResponseWriter, r *http.Request) {
 	var msg struct {
 		ID     uint64
 		Sticky bool
 	}
-	if !decodeJSON(w, r, &msg) {
+	if err := readJSON(r, &msg); err != nil {
+		serveErrorJSON(w, r, err)
 		return
 	}
-	if _, _, ok := canModeratePost(w, r, msg.ID, auth.Moderator); !ok {
+	board, ss, ok := assertCanModeratePostAPI(w, r, msg.ID, auth.Moderator)
+	if !ok {
 		return
 	}
 
-	switch err := db.SetThreadSticky(msg.ID, msg.Sticky); err {
+	switch err := db.SetThreadSticky(msg.ID, msg.Sticky, ss.UserID); err {
 	case nil:
+		// Sticky threads are ordered first so board pages must be
+		// rebuilt right away.
+		invalidateThread(msg.ID, board)
+		serveEmptyJSON(w, r)
 	case sql.ErrNoRows:
-		text400(w, err)
+		serveErrorJSON(w, r, aerrNoThread)
 	default:
-		text500(w, r, err)
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+	}
+}
+
+// Lock or unlock thread, so no new posts can be made in it.
+func setThreadLocked(w http.ResponseWriter, r *http.Request) {
+	var msg struct {
+		ID     uint64
+		Locked bool
+	}
+	if err := readJSON(r, &msg); err != nil {
+		serveErrorJSON(w, r, err)
+		return
+	}
+	board, ss, ok := assertCanModeratePostAPI(w, r, msg.ID, auth.Moderator)
+	if !ok {
+		return
+	}
+
+	switch err := db.SetThreadLocked(msg.ID, msg.Locked, ss.UserID); err {
+	case nil:
+		invalidateThread(msg.ID, board)
+		serveEmptyJSON(w, r)
+	case sql.ErrNoRows:
+		serveErrorJSON(w, r, aerrNoThread)
+	default:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+	}
+}
+
+// Move thread to another board. Moderator must have rights on both
+// boards.
+func moveThread(w http.ResponseWriter, r *http.Request) {
+	var msg struct {
+		ID    uint64
+		Board string
+	}
+	if err := readJSON(r, &msg); err != nil {
+		serveErrorJSON(w, r, err)
+		return
+	}
+	if msg.Board == "all" || !config.IsBoard(msg.Board) {
+		serveErrorJSON(w, r, aerrInvalidBoard)
+		return
+	}
+	from, ss, ok := assertCanModeratePostAPI(w, r, msg.ID, auth.Moderator)
+	if !ok {
+		return
+	}
+	if from == msg.Board {
+		serveErrorJSON(w, r, aerrSameBoard)
+		return
+	}
+	if dst, _ := getSession(r, msg.Board); !canPerform(dst, auth.Moderator) {
+		serveErrorJSON(w, r, aerrAccessDenied)
+		return
+	}
+
+	switch err := db.MoveThread(msg.ID, from, msg.Board, ss.UserID); err {
+	case nil:
+		invalidateThread(msg.ID, from)
+		invalidateThread(msg.ID, msg.Board)
+		feeds.MoveThread(msg.ID, msg.Board)
+		serveEmptyJSON(w, r)
+	case sql.ErrNoRows:
+		serveErrorJSON(w, r, aerrNoThread)
+	default:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+	}
+}
+
+// Split posts off the thread into a new thread on the same or another
+// board.
+func splitThread(w http.ResponseWriter, r *http.Request) {
+	var msg struct {
+		ID      uint64
+		Posts   []uint64
+		Board   string
+		Subject string
+	}
+	if err := readJSON(r, &msg); err != nil {
+		serveErrorJSON(w, r, err)
+		return
+	}
+	if msg.Board == "all" || !config.IsBoard(msg.Board) {
+		serveErrorJSON(w, r, aerrInvalidBoard)
+		return
+	}
+	subject, err := parser.ParseSubject(msg.Subject)
+	if err != nil {
+		serveErrorJSON(w, r, aerrorFrom(400, err))
+		return
+	}
+	if len(msg.Posts) == 0 || len(msg.Posts) > common.MaxLenSplitPosts {
+		serveErrorJSON(w, r, aerrInvalidSplit)
+		return
+	}
+	from, ss, ok := assertCanModeratePostAPI(w, r, msg.ID, auth.Moderator)
+	if !ok {
+		return
+	}
+	if dst, _ := getSession(r, msg.Board); !canPerform(dst, auth.Moderator) {
+		serveErrorJSON(w, r, aerrAccessDenied)
+		return
+	}
+
+	newOP, err := db.SplitThread(msg.ID, msg.Posts, msg.Board, subject, ss.UserID)
+	switch err {
+	case nil:
+		invalidateThread(msg.ID, from)
+		invalidateThread(newOP, msg.Board)
+		feeds.SplitThread(msg.ID, newOP, msg.Board, msg.Posts)
+		serveJSON(w, r, map[string]uint64{"id": newOP})
+	case sql.ErrNoRows:
+		serveErrorJSON(w, r, aerrInvalidSplit)
+	default:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
 	}
 }
 
@@ -483,6 +1032,12 @@ func serveAdmin(
 		return
 	}
 
+	rules, err := db.GetAutomodRules(nil, boards)
+	if err != nil {
+		text500(w, r, err)
+		return
+	}
+
 	log, err := db.GetModLog(boards)
 	if err != nil {
 		text500(w, r, err)
@@ -491,7 +1046,8 @@ func serveAdmin(
 
 	l := lang.FromReq(r)
 	cs := config.GetBoardConfigsByID(boards)
-	html := templates.Admin(templates.Params{r, ss, l}, cs, staff, bans, log)
+	html := templates.Admin(
+		templates.Params{r, ss, l}, cs, staff, bans, rules, log)
 	serveHTML(w, r, html)
 }
 
@@ -509,6 +1065,15 @@ func checkBoardState(board string, state db.BoardState) (err error) {
 		err = aerrTitleTooLong
 		return
 	}
+	if !checkUploadPolicy(state.Settings) {
+		err = aerrBadUploadPolicy
+		return
+	}
+	if state.Settings.HoldPosts < 0 ||
+		state.Settings.HoldPosts > common.MaxHoldPosts {
+		err = aerrBadHoldPosts
+		return
+	}
 	if len(state.Staff) > common.MaxLenStaffList {
 		err = aerrTooManyStaff
 		return
@@ -537,6 +1102,14 @@ func checkBoardState(board string, state db.BoardState) (err error) {
 			err = aerrInvalidState
 			return
 		}
+		if rec.IP == "" && rec.Account == "" {
+			err = aerrInvalidState
+			return
+		}
+		if rec.Account != "" && !checkUserID(rec.Account) {
+			err = aerrInvalidUserID
+			return
+		}
 		if !checkUserID(rec.By) {
 			err = aerrInvalidUserID
 			return
@@ -546,9 +1119,41 @@ func checkBoardState(board string, state db.BoardState) (err error) {
 			return
 		}
 	}
+	if len(state.Rules) > common.MaxLenAutomodRules {
+		err = aerrTooManyRules
+		return
+	}
+	for _, rule := range state.Rules {
+		if rule.Board != board {
+			err = aerrInvalidState
+			return
+		}
+		if err = automod.Validate(rule); err != nil {
+			err = aerrorFrom(400, err)
+			return
+		}
+	}
 	return
 }
 
+// Board limits can't exceed server-wide ones.
+func checkUploadPolicy(c config.BoardConfig) bool {
+	conf := config.Get()
+	if c.MaxSize < 0 || c.MaxSize > conf.MaxSize {
+		return false
+	}
+	if c.MaxFiles < 0 || c.MaxFiles > conf.MaxFiles {
+		return false
+	}
+	for _, ext := range c.FileTypes {
+		t, ok := common.FileTypeByExt(ext)
+		if !ok || !isUploadable(t) {
+			return false
+		}
+	}
+	return true
+}
+
 func equalStates(oldState, newState db.BoardState) bool {
 	return reflect.DeepEqual(oldState, newState)
 }
@@ -584,7 +1189,11 @@ func configureBoard(r *http.Request, ss *auth.Session, board string) (err error)
 	}
 
 	if err = db.SetBoardState(tx, req.NewState, ss.UserID); err != nil {
-		err = aerrInternal.Hide(err)
+		if db.IsForeignKeyViolationError(err) {
+			err = aerrNoAccount
+		} else {
+			err = aerrInternal.Hide(err)
+		}
 		return
 	}
 
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/cutechan/cutechan/go/auth"
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/db"
+	"github.com/cutechan/cutechan/go/lang"
+	"github.com/cutechan/cutechan/go/templates"
+)
+
+type appealRequest struct {
+	Board string `json:"board"`
+	Text  string `json:"text"`
+}
+
+type resolveAppealRequest struct {
+	ID       uint64 `json:"id"`
+	Accept   bool   `json:"accept"`
+	Response string `json:"response"`
+}
+
+// Account of the logged in client, if any. Bans of the account apply
+// regardless of IP.
+func getSessionAccount(r *http.Request) string {
+	if ss, _ := getSession(r, ""); ss != nil {
+		return ss.UserID
+	}
+	return ""
+}
+
+// Serve page with all active bans of the client.
+func serveBanned(w http.ResponseWriter, r *http.Request) {
+	ip, err := auth.GetIP(r)
+	if err != nil {
+		text400(w, err)
+		return
+	}
+	ss, _ := getSession(r, "")
+	var account string
+	if ss != nil {
+		account = ss.UserID
+	}
+	bans, err := db.GetClientBans(ip, account)
+	if err != nil {
+		text500(w, r, err)
+		return
+	}
+	html := templates.Banned(templates.Params{r, ss, lang.FromReq(r)}, bans)
+	serveHTML(w, r, html)
+}
+
+// Serve all active bans of the client as JSON.
+func serveBanInfo(w http.ResponseWriter, r *http.Request) {
+	ip, err := auth.GetIP(r)
+	if err != nil {
+		serveErrorJSON(w, r, aerrorFrom(400, err))
+		return
+	}
+	bans, err := db.GetClientBans(ip, getSessionAccount(r))
+	if err != nil {
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	serveJSON(w, r, bans)
+}
+
+// Appeal client's ban on the board. Only one appeal per ban is allowed.
+func createAppeal(w http.ResponseWriter, r *http.Request) {
+	var req appealRequest
+	if err := readJSON(r, &req); err != nil {
+		serveErrorJSON(w, r, err)
+		return
+	}
+	req.Text = strings.TrimSpace(req.Text)
+	if req.Text == "" || len(req.Text) > common.MaxLenAppealText {
+		serveErrorJSON(w, r, aerrInvalidAppeal)
+		return
+	}
+	ip, err := auth.GetIP(r)
+	if err != nil {
+		serveErrorJSON(w, r, aerrorFrom(400, err))
+		return
+	}
+	ban, err := db.GetBanInfo(ip, getSessionAccount(r), req.Board)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		serveErrorJSON(w, r, aerrNotBanned)
+		return
+	default:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+
+	inserted, err := db.InsertAppeal(
+		ban.Board, ban.IP, ban.Account, ban.ID, req.Text)
+	switch {
+	case err != nil:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+	case !inserted:
+		serveErrorJSON(w, r, aerrDupAppeal)
+	default:
+		serveEmptyJSON(w, r)
+	}
+}
+
+// Serve moderator queue of pending appeals on the board.
+func serveAppeals(w http.ResponseWriter, r *http.Request) {
+	board := getParam(r, "board")
+	if _, ok := assertCanBanAPI(w, r, board); !ok {
+		return
+	}
+	appeals, err := db.GetAppeals(board)
+	if err != nil {
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	serveJSON(w, r, appeals)
+}
+
+// Accept appeal lifting the ban or reject it with a message to the
+// banned user.
+func resolveAppeal(w http.ResponseWriter, r *http.Request) {
+	var req resolveAppealRequest
+	if err := readJSON(r, &req); err != nil {
+		serveErrorJSON(w, r, err)
+		return
+	}
+	req.Response = strings.TrimSpace(req.Response)
+	if len(req.Response) > common.MaxLenAppealReply {
+		serveErrorJSON(w, r, aerrInvalidAppeal)
+		return
+	}
+	appeal, err := db.GetAppeal(req.ID)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		serveErrorJSON(w, r, aerrNoAppeal)
+		return
+	default:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	ss, ok := assertCanBanAPI(w, r, appeal.Board)
+	if !ok {
+		return
+	}
+
+	if req.Accept {
+		err = db.AcceptAppeal(appeal, ss.UserID)
+	} else {
+		err = db.RejectAppeal(appeal.ID, req.Response, ss.UserID)
+	}
+	switch err {
+	case nil:
+		serveEmptyJSON(w, r)
+	case sql.ErrNoRows:
+		serveErrorJSON(w, r, aerrNoAppeal)
+	default:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+	}
+}
@@ -1,23 +1,31 @@
 package server
 
 import (
+	"database/sql"
 	"fmt"
 	"net/http"
 
 	"github.com/cutechan/cutechan/go/auth"
 	"github.com/cutechan/cutechan/go/config"
+	"github.com/cutechan/cutechan/go/db"
 	"github.com/cutechan/cutechan/go/util"
 )
 
-// Ensure API user is not banned.
-func assertNotBannedAPI(w http.ResponseWriter, r *http.Request, board string) (ip string, ok bool) {
+// Ensure API user is not banned, neither by IP nor by account.
+func assertNotBannedAPI(
+	w http.ResponseWriter,
+	r *http.Request,
+	board string,
+	ss *auth.Session,
+) (ip string, ok bool) {
 	ip, err := auth.GetIP(r)
 	if err != nil {
 		text400(w, err)
 		return
 	}
-	if auth.IsBanned(board, ip) {
-		text403(w, errBanned)
+	if auth.IsBanned(board, ip) ||
+		(ss != nil && auth.IsAccountBanned(board, ss.UserID)) {
+		serveErrorJSON(w, r, aerrBanned)
 		return
 	}
 	ok = true
@@ -139,6 +147,33 @@ func assertBoardOwner(h AdminBoardHandler) http.HandlerFunc {
 	}
 }
 
+// Ensure client can perform moderation on the post's board and return
+// that board.
+func assertCanModeratePostAPI(
+	w http.ResponseWriter,
+	r *http.Request,
+	id uint64,
+	level auth.ModerationLevel,
+) (board string, ss *auth.Session, ok bool) {
+	board, err := db.GetPostBoard(id)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		serveErrorJSON(w, r, aerrNoPost)
+		return
+	default:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	ss, _ = getSession(r, board)
+	if !canPerform(ss, level) {
+		serveErrorJSON(w, r, aerrAccessDenied)
+		return
+	}
+	ok = true
+	return
+}
+
 type AdminBoardAPIHandler func(r *http.Request, ss *auth.Session, board string) error
 
 func assertBoardOwnerAPI(h AdminBoardAPIHandler) http.HandlerFunc {
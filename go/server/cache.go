@@ -4,20 +4,49 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/cutechan/cutechan/go/auth"
 	"github.com/cutechan/cutechan/go/cache"
 	"github.com/cutechan/cutechan/go/common"
 	"github.com/cutechan/cutechan/go/db"
+	"github.com/cutechan/cutechan/go/file"
 	"github.com/cutechan/cutechan/go/lang"
 	"github.com/cutechan/cutechan/go/templates"
 )
 
+// Drop cached thread and pages it's shown on after moderation changes.
+func invalidateThread(id uint64, board string) {
+	cache.Delete(func(k cache.Key) bool {
+		return k.ID == id || k.Board == board || k.Board == "all"
+	})
+}
+
+// Drop board pages after threads were removed from them
+func invalidateBoards(boards ...string) {
+	cache.Delete(func(k cache.Key) bool {
+		if k.Board == "all" {
+			return true
+		}
+		for _, b := range boards {
+			if k.Board == b {
+				return true
+			}
+		}
+		return false
+	})
+}
+
 var threadCache = cache.FrontEnd{
 	GetCounter: func(k cache.Key) (uint64, error) {
 		return db.ThreadCounter(k.ID)
 	},
 
 	GetFresh: func(k cache.Key) (interface{}, error) {
-		return db.GetThread(k.ID, k.LastN)
+		t, err := db.GetThread(k.ID, k.LastN, k.Shadow)
+		if err != nil {
+			return nil, err
+		}
+		file.SignThread(&t)
+		return t, nil
 	},
 
 	RenderHTML: func(data interface{}, json []byte, k cache.Key) []byte {
@@ -34,11 +63,20 @@ var catalogCache = cache.FrontEnd{
 		return db.BoardCounter(k.Board)
 	},
 
-	GetFresh: func(k cache.Key) (interface{}, error) {
+	GetFresh: func(k cache.Key) (data interface{}, err error) {
+		var b common.Board
 		if k.Board == "all" {
-			return db.GetAllBoardCatalog()
+			b, err = db.GetAllBoardCatalog(k.Shadow)
+		} else {
+			b, err = db.GetBoardCatalog(k.Board, k.Shadow)
 		}
-		return db.GetBoardCatalog(k.Board)
+		if err != nil {
+			return
+		}
+		for i := range b {
+			file.SignThread(&b[i])
+		}
+		return b, nil
 	},
 
 	RenderHTML: func(data interface{}, json []byte, k cache.Key) []byte {
@@ -65,9 +103,9 @@ var boardPageCache = cache.FrontEnd{
 	GetFresh: func(k cache.Key) (data interface{}, err error) {
 		var ids []uint64
 		if k.Board == "all" {
-			ids, err = db.GetAllThreadsIDs()
+			ids, err = db.GetAllThreadsIDs(k.Shadow)
 		} else {
-			ids, err = db.GetThreadIDs(k.Board)
+			ids, err = db.GetThreadIDs(k.Board, k.Shadow)
 		}
 		if err != nil {
 			return
@@ -96,8 +134,9 @@ var boardPageCache = cache.FrontEnd{
 		}
 		pageIDs := ids[lowIdx:highIdx]
 		for i, id := range pageIDs {
-			k := cache.ThreadKey(k.Lang, id, common.NumPostsAtIndex)
-			tjson, tdata, _, terr := cache.GetJSONAndData(k, threadCache)
+			tk := cache.ThreadKey(k.Lang, id, common.NumPostsAtIndex)
+			tk.Shadow = k.Shadow
+			tjson, tdata, _, terr := cache.GetJSONAndData(tk, threadCache)
 			if terr != nil {
 				return nil, terr
 			}
@@ -121,8 +160,41 @@ var boardPageCache = cache.FrontEnd{
 	},
 }
 
+// Get visibility of shadow banned posts for the client. Moderators see
+// all of them while shadow banned users only see their own, /all/ shows
+// posts from every board.
+func shadowViewer(r *http.Request, board string, ss *auth.Session) string {
+	if board != "all" && canPerform(ss, auth.Moderator) {
+		return db.ShadowAll
+	}
+	ip, err := auth.GetIP(r)
+	if err != nil {
+		return ""
+	}
+	var account string
+	if ss != nil {
+		account = ss.UserID
+	}
+	var shadow bool
+	if board == "all" {
+		shadow = auth.IsShadowBannedAnywhere(ip, account)
+	} else {
+		shadow = auth.IsShadowBanned(board, ip) ||
+			auth.IsAccountShadowBanned(board, account)
+	}
+	if shadow {
+		return ip
+	}
+	return ""
+}
+
 // Returns arguments for accessing the board page JSON/HTML cache
-func boardCacheArgs(r *http.Request, board string, catalog bool) (
+func boardCacheArgs(
+	r *http.Request,
+	board string,
+	catalog bool,
+	ss *auth.Session,
+) (
 	k cache.Key, f cache.FrontEnd,
 ) {
 	page := 0
@@ -134,6 +206,7 @@ func boardCacheArgs(r *http.Request, board string, catalog bool) (
 		}
 	}
 	k = cache.BoardKey(lang.FromReq(r), board, page, catalog)
+	k.Shadow = shadowViewer(r, board, ss)
 	if catalog {
 		f = catalogCache
 	} else {
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/cutechan/cutechan/go/auth"
 	"github.com/cutechan/cutechan/go/ipc"
 )
 
@@ -94,6 +95,47 @@ var (
 	aerrUnsupported     = aerrorFrom(400, ipc.ErrThumbUnsupported)
 	aerrBadDimensions   = aerrorFrom(400, ipc.ErrThumbDimensions)
 	aerrNoTracks        = aerrorFrom(400, ipc.ErrThumbTracks)
+	aerrThumbTimeout    = aerrorFrom(400, ipc.ErrThumbTimeout)
+	aerrBadURL          = aerrorNew(400, "bad url")
+	aerrForbiddenURL    = aerrorNew(400, "url not allowed")
+	aerrFetchURL        = aerrorNew(400, "error fetching url")
+	aerrBadContentType  = aerrorNew(400, "unsupported content type")
+	aerrTooManyFetches  = aerrorNew(429, "too many remote uploads")
+	aerrTextOnly        = aerrorNew(400, "text only board")
+	aerrFileNotAllowed  = aerrorNew(400, "file type not allowed")
+	aerrBadUploadPolicy = aerrorNew(400, "invalid upload policy")
+	aerrAccessDenied    = aerrorNew(403, "access denied")
+	aerrAdminOnly       = aerrorNew(403, "only for admin")
+	aerrNoPost          = aerrorNew(404, "no such post")
+	aerrNoThread        = aerrorNew(404, "no such thread")
+	aerrInvalidBoard    = aerrorNew(400, "invalid board")
+	aerrNoDeletedBoard  = aerrorNew(404, "no such deleted board")
+	aerrBadNotification = aerrorNew(400, "invalid notification")
+	aerrThreadLocked    = aerrorNew(403, "thread is locked")
+	aerrSameBoard       = aerrorNew(400, "thread is already on this board")
+	aerrInvalidSplit    = aerrorNew(400, "invalid posts to split")
+	aerrInvalidReport   = aerrorNew(400, "invalid report")
+	aerrDupReport       = aerrorNew(400, "post already reported")
+	aerrTooManyReports  = aerrorNew(429, "too many reports")
+	aerrNoReports       = aerrorNew(404, "no reports on post")
+	aerrInvalidBanRange = aerrorFrom(400, auth.ErrInvalidBanRange)
+	aerrNoDuration      = aerrorNew(400, "no ban duration provided")
+	aerrNoBan           = aerrorNew(404, "no such ban")
+	aerrNoAccount       = aerrorNew(404, "no such account")
+	aerrBanned          = aerrorNew(403, "you are banned, see /banned")
+	aerrNotBanned       = aerrorNew(404, "you are not banned")
+	aerrInvalidAppeal   = aerrorNew(400, "invalid appeal")
+	aerrDupAppeal       = aerrorNew(400, "ban already appealed")
+	aerrNoAppeal        = aerrorNew(404, "no such appeal")
+	aerrInvalidPurge    = aerrorNew(400, "invalid purge")
+	aerrNothingToPurge  = aerrorNew(404, "nothing to purge")
+	aerrInvalidNote     = aerrorNew(400, "invalid note")
+	aerrNoPostIP        = aerrorNew(400, "post IP is already wiped")
+	aerrNoNote          = aerrorNew(404, "no such note")
+	aerrTooManyRules    = aerrorNew(400, "too many automod rules")
+	aerrCaptchaRequired = aerrorNew(403, "captcha required")
+	aerrBadHoldPosts    = aerrorNew(400, "invalid number of held posts")
+	aerrNotHeld         = aerrorNew(404, "post is not held")
 )
 
 // Legacy errors.
@@ -101,7 +143,6 @@ var (
 var (
 	errInvalidBoard     = errors.New("invalid board")
 	errReadOnly         = errors.New("read only board")
-	errBanned           = errors.New("you are banned")
 	errNoImage          = errors.New("post has no image")
 	errPageOverflow     = errors.New("page not found")
 	errInvalidBoardName = errors.New("invalid board name")
@@ -0,0 +1,211 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/db"
+	"github.com/cutechan/cutechan/go/file"
+	"github.com/cutechan/cutechan/go/ipc"
+)
+
+// Uploads are written to file backend before their transaction commits,
+// so recent orphans might still get claimed by the image.
+const orphanGrace = time.Minute * 10
+
+type fsckStats struct {
+	images, missing, mismatched, orphans, regenerated, deleted int
+}
+
+// CheckFiles cross-checks images table against file backend contents
+// and reports missing, orphaned and size-mismatched files. Optionally
+// regenerates missing and broken thumbnails from sources and deletes
+// orphans created before the check started.
+func CheckFiles(user string, regen, deleteOrphans bool) (err error) {
+	var stats fsckStats
+	start := time.Now()
+
+	log.Print("fsck: listing stored files")
+	objects := make(map[string]file.Object)
+	err = file.Backend.List(func(obj file.Object) error {
+		objects[obj.Name] = obj
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	after := ""
+	for {
+		var imgs []common.ImageCommon
+		imgs, err = db.GetImages(after, backfillBatchSize)
+		if err != nil {
+			return
+		}
+		if len(imgs) == 0 {
+			break
+		}
+		for _, img := range imgs {
+			after = img.SHA1
+			if err = checkImageFiles(user, img, objects, regen, &stats); err != nil {
+				return
+			}
+		}
+		stats.images += len(imgs)
+	}
+
+	// Everything left wasn't claimed by any image. Files might have been
+	// uploaded after the check started so look at the DB again.
+	for name, obj := range objects {
+		sha1 := file.ObjectSHA1(name)
+		if sha1 != "" {
+			img, err := db.GetImage(sha1)
+			switch err {
+			case nil:
+				if _, ok := getImageObjects(&img)[name]; ok {
+					continue
+				}
+			case sql.ErrNoRows:
+			default:
+				return err
+			}
+		}
+		log.Printf("fsck: orphan %s", name)
+		stats.orphans++
+		switch {
+		case !deleteOrphans:
+		case !isOldOrphan(obj, start):
+			log.Printf("fsck: keeping recent orphan %s", name)
+		default:
+			if err = file.Backend.Remove(name); err != nil {
+				return
+			}
+			stats.deleted++
+		}
+	}
+
+	log.Printf("fsck: %d images checked, %d missing, %d size mismatched, %d orphaned files",
+		stats.images, stats.missing, stats.mismatched, stats.orphans)
+	if regen {
+		log.Printf("fsck: %d thumbnails regenerated", stats.regenerated)
+	}
+	if deleteOrphans {
+		log.Printf("fsck: %d orphans deleted", stats.deleted)
+	}
+	return
+}
+
+// Orphan might belong to upload in progress unless it was stored well
+// before the check. Unknown modification time is never old enough.
+func isOldOrphan(obj file.Object, start time.Time) bool {
+	return !obj.ModTime.IsZero() &&
+		obj.ModTime.Before(start.Add(-orphanGrace))
+}
+
+// Get names of files belonging to the image with expected sizes. Size
+// is known only for the source, zero for thumbnails.
+func getImageObjects(img *common.ImageCommon) map[string]int64 {
+	objects := map[string]int64{file.SourceName(img.FileType, img.SHA1): int64(img.Size)}
+	if file.HasThumb(img) {
+		objects[file.ThumbName(file.Thumb{Type: img.ThumbType}, img.SHA1)] = 0
+	}
+	for _, t := range file.Variants(img) {
+		objects[file.ThumbName(t, img.SHA1)] = 0
+	}
+	return objects
+}
+
+func checkImageFiles(user string, img common.ImageCommon, objects map[string]file.Object, regen bool, stats *fsckStats) error {
+	var brokenThumbs []string
+	sourceOK := true
+	for name, expected := range getImageObjects(&img) {
+		obj, ok := objects[name]
+		size := obj.Size
+		delete(objects, name)
+		if !ok {
+			// Listings of object storages are eventually consistent.
+			obj, err := file.Backend.Stat(name)
+			switch err {
+			case nil:
+				size = obj.Size
+			case file.ErrNotExist:
+				log.Printf("fsck: missing %s", name)
+				stats.missing++
+				if expected != 0 {
+					sourceOK = false
+				} else {
+					brokenThumbs = append(brokenThumbs, name)
+				}
+				continue
+			default:
+				return err
+			}
+		}
+		if (expected != 0 && size != expected) || size == 0 {
+			log.Printf("fsck: size mismatch %s: expected %d, got %d", name, expected, size)
+			stats.mismatched++
+			if expected != 0 {
+				sourceOK = false
+			} else {
+				brokenThumbs = append(brokenThumbs, name)
+			}
+		}
+	}
+
+	if regen && sourceOK && len(brokenThumbs) != 0 {
+		if err := regenThumbs(user, img, brokenThumbs); err != nil {
+			log.Printf("fsck: cannot regenerate thumbnails of %s: %v", img.SHA1, err)
+		} else {
+			stats.regenerated += len(brokenThumbs)
+		}
+	}
+	return nil
+}
+
+// Regenerate thumbnails and overwrite only the given ones, which are
+// either missing or broken.
+func regenThumbs(user string, img common.ImageCommon, names []string) error {
+	srcData, err := file.Backend.ReadSource(img.SHA1, img.FileType)
+	if err != nil {
+		return err
+	}
+	thumb, err := ipc.GetThumbnail(user, srcData)
+	if err != nil {
+		return err
+	}
+	thumbType := uint8(common.JPEG)
+	if thumb.HasAlpha {
+		thumbType = common.PNG
+	}
+	if thumbType != img.ThumbType {
+		return fmt.Errorf("thumbnail type changed")
+	}
+
+	isBroken := make(map[string]bool, len(names))
+	for _, name := range names {
+		isBroken[name] = true
+	}
+	// Don't touch recorded renditions info.
+	variants := img
+	all := append(
+		[]file.Thumb{{Type: img.ThumbType, Data: thumb.Data}},
+		mapThumbVariants(&variants, thumb)...)
+	var thumbs []file.Thumb
+	for _, t := range all {
+		name := file.ThumbName(t, img.SHA1)
+		if isBroken[name] {
+			thumbs = append(thumbs, t)
+			delete(isBroken, name)
+		}
+	}
+	if err = file.Backend.ReplaceThumbs(img.SHA1, thumbs); err != nil {
+		return err
+	}
+	if len(isBroken) != 0 {
+		return fmt.Errorf("%d renditions weren't generated", len(isBroken))
+	}
+	return nil
+}
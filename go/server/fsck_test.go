@@ -0,0 +1,31 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cutechan/cutechan/go/file"
+)
+
+func TestIsOldOrphan(t *testing.T) {
+	start := time.Now()
+	cases := [...]struct {
+		name    string
+		modTime time.Time
+		old     bool
+	}{
+		{"unknown", time.Time{}, false},
+		{"in flight", start.Add(time.Second), false},
+		{"within grace", start.Add(-time.Minute), false},
+		{"old", start.Add(-time.Hour), true},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			obj := file.Object{Name: "src/x.jpg", ModTime: c.modTime}
+			if isOldOrphan(obj, start) != c.old {
+				t.Fatalf("expected old=%v", c.old)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/cutechan/cutechan/go/auth"
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/db"
+	"github.com/cutechan/cutechan/go/feeds"
+	"github.com/cutechan/cutechan/go/file"
+)
+
+type resolveHeldRequest struct {
+	ID      uint64 `json:"id"`
+	Approve bool   `json:"approve"`
+}
+
+// Serve moderator queue of posts awaiting approval on the board.
+func serveHeldPosts(w http.ResponseWriter, r *http.Request) {
+	board := getParam(r, "board")
+	if !assertBoardAPI(w, board) {
+		return
+	}
+	ss, _ := getSession(r, board)
+	if !canPerform(ss, auth.Moderator) {
+		serveErrorJSON(w, r, aerrAccessDenied)
+		return
+	}
+	posts, err := db.GetHeldPosts(board)
+	if err != nil {
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	for i := range posts {
+		file.SignFiles(&posts[i].Post)
+	}
+	serveJSON(w, r, posts)
+}
+
+// Approve held post, publishing it live, or reject it.
+func resolveHeldPost(w http.ResponseWriter, r *http.Request) {
+	var req resolveHeldRequest
+	if err := readJSON(r, &req); err != nil {
+		serveErrorJSON(w, r, err)
+		return
+	}
+	_, ss, ok := assertCanModeratePostAPI(w, r, req.ID, auth.Moderator)
+	if !ok {
+		return
+	}
+
+	var err error
+	if req.Approve {
+		err = db.ApprovePost(req.ID, ss.UserID)
+	} else {
+		err = db.RejectPost(req.ID, ss.UserID)
+	}
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		serveErrorJSON(w, r, aerrNotHeld)
+		return
+	default:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+
+	if req.Approve {
+		if err = publishApprovedPost(req.ID); err != nil {
+			serveErrorJSON(w, r, aerrInternal.Hide(err))
+			return
+		}
+	}
+	serveEmptyJSON(w, r)
+}
+
+// Send approved post to the thread feed as if it was just created,
+// keeping its original timestamp.
+func publishApprovedPost(id uint64) (err error) {
+	post, err := db.GetPost(id)
+	if err != nil {
+		return
+	}
+	if post.ID == post.OP {
+		invalidateBoards(post.Board)
+		return
+	}
+
+	shadow := post.Shadow
+	post.Shadow = false
+	file.SignFiles(&post.Post)
+	msg, err := common.EncodeMessage(common.MessageInsertPost, post.Post)
+	if err != nil {
+		return
+	}
+	if shadow {
+		var ip string
+		ip, err = db.GetIP(id)
+		if err != nil {
+			return
+		}
+		feeds.InsertShadowPostInto(post, ip, msg)
+	} else {
+		feeds.InsertPostInto(post, msg)
+	}
+	invalidateThread(post.OP, post.Board)
+	return
+}
@@ -52,7 +52,7 @@ func boardHTML(w http.ResponseWriter, r *http.Request, b string, catalog bool) {
 		return
 	}
 
-	html, data, _, err := cache.GetHTML(boardCacheArgs(r, b, catalog))
+	html, data, _, err := cache.GetHTML(boardCacheArgs(r, b, catalog, ss))
 	switch err {
 	case nil:
 		// Do nothing.
@@ -89,14 +89,15 @@ func threadHTML(w http.ResponseWriter, r *http.Request) {
 
 	l := lang.FromReq(r)
 	lastN := detectLastN(r)
+	b := getParam(r, "board")
 	k := cache.ThreadKey(l, id, lastN)
+	k.Shadow = shadowViewer(r, b, ss)
 	html, data, _, err := cache.GetHTML(k, threadCache)
 	if err != nil {
 		respondToJSONError(w, r, err)
 		return
 	}
 
-	b := getParam(r, "board")
 	title := data.(common.Thread).Subject
 	html = templates.Thread(templates.Params{r, ss, l}, id, b, title, lastN != 0, html)
 	serveHTML(w, r, html)
@@ -158,6 +159,27 @@ func crossRedirect(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Thread might have been moved to another board, so keep old URLs
+// working.
+func redirectMovedThread(w http.ResponseWriter, r *http.Request, id uint64) {
+	board, op, err := db.GetPostParenthood(id)
+	switch {
+	case err == sql.ErrNoRows || err == nil && op != id:
+		serve404(w, r)
+	case err != nil:
+		text500(w, r, err)
+	default:
+		ss, _ := getSession(r, board)
+		if !assertNotModOnly(w, r, board, ss) {
+			return
+		}
+		url := r.URL
+		url.Path = fmt.Sprintf("/%s/%d", board, id)
+		// Not permanent since thread can be moved again.
+		http.Redirect(w, r, url.String(), 302)
+	}
+}
+
 func serveStickers(w http.ResponseWriter, r *http.Request) {
 	ss, _ := getSession(r, "")
 	stickHTML := []byte{}
@@ -187,13 +209,13 @@ func validateThread(w http.ResponseWriter, r *http.Request) (
 		return
 	}
 
-	valid, err := db.ValidateOP(id, b)
+	valid, err := db.ThreadExists(id, b)
 	if err != nil {
 		text500(w, r, err)
 		return
 	}
 	if !valid {
-		serve404(w, r)
+		redirectMovedThread(w, r, id)
 		return
 	}
 
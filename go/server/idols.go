@@ -5,6 +5,7 @@ import (
 	"regexp"
 
 	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/config"
 	"github.com/cutechan/cutechan/go/db"
 )
 
@@ -43,7 +44,7 @@ func setIdolPreview(w http.ResponseWriter, r *http.Request) (answer map[string]s
 		return
 	}
 
-	res, err := uploadFile(fhs[0])
+	res, err := uploadFile(fhs[0], config.ServerUploadPolicy())
 	if err != nil {
 		return
 	}
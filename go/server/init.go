@@ -7,6 +7,8 @@ import (
 	"runtime/debug"
 	"time"
 
+	"github.com/cutechan/cutechan/go/auth"
+	"github.com/cutechan/cutechan/go/db"
 	"github.com/cutechan/cutechan/go/file"
 	"github.com/cutechan/cutechan/go/websockets"
 
@@ -28,6 +30,8 @@ var (
 func Start(conf Config) (err error) {
 	// TODO(Kagami): Use config structs instead of globals.
 	secureCookie = conf.SecureCookie
+	file.IsRestricted = db.IsImageRestricted
+	db.OnThreadUpdated = invalidateThread
 
 	startThumbWorkers(conf.ThumbUser)
 	router := createRouter(conf)
@@ -67,6 +71,7 @@ func createRouter(conf Config) http.Handler {
 	r.GET("/", serveLanding)
 	r.GET("/404.html", serve404)
 	r.GET("/stickers/", serveStickers)
+	r.GET("/banned", serveBanned)
 	r.GET("/:board/", func(w http.ResponseWriter, r *http.Request) {
 		boardHTML(w, r, getParam(r, "board"), false)
 	})
@@ -103,8 +108,14 @@ func createRouter(conf Config) http.Handler {
 	// Posts.
 	api.GET("/post/:post", servePost)
 	api.POST("/post/token", createPostToken)
+	api.POST("/captcha", auth.NewCaptchaID)
+	api.GET("/captcha/:id", auth.ServeCaptcha)
 	api.POST("/post", createPost)
 	api.POST("/thread", createThread)
+	api.POST("/upload-url", serveUploadURL)
+	api.POST("/report", createReport)
+	api.GET("/banned", serveBanInfo)
+	api.POST("/appeal", createAppeal)
 	// Account.
 	api.POST("/register", register)
 	api.POST("/login", login)
@@ -115,13 +126,37 @@ func createRouter(conf Config) http.Handler {
 	// Mod.
 	api.POST("/ban", ban)
 	api.POST("/unban/:board", unban)
+	api.GET("/bans/:board", serveBans)
+	api.POST("/ban-range", banRange)
+	api.POST("/unban-range", unbanRange)
+	api.POST("/ban-account", banAccount)
+	api.POST("/unban-account", unbanAccount)
 	api.POST("/delete-post", deletePost)
+	api.POST("/spoiler-file", spoilerFile)
+	api.POST("/delete-file", deleteFile)
+	api.POST("/sticky", setThreadSticky)
+	api.POST("/lock", setThreadLocked)
+	api.POST("/move", moveThread)
+	api.POST("/split", splitThread)
+	api.GET("/reports/:board", serveReports)
+	api.POST("/reports/resolve", resolveReport)
+	api.GET("/held/:board", serveHeldPosts)
+	api.POST("/held/resolve", resolveHeldPost)
+	api.GET("/appeals/:board", serveAppeals)
+	api.POST("/appeals/resolve", resolveAppeal)
+	api.GET("/same-ip/:id", getSameIPPosts)
+	api.POST("/purge", purgePosts)
+	api.GET("/notes/:board", serveNotes)
+	api.GET("/post-notes/:id", servePostNotes)
+	api.POST("/notes", createNote)
+	api.POST("/notes/delete", deleteNote)
 	api.PUT("/boards/:board", assertBoardOwnerAPI(configureBoard))
+	api.POST("/delete-board", deleteBoard)
+	api.POST("/restore-board", restoreBoard)
 	// Admin.
 	api.POST("/create-board", createBoard)
-	// Too dangerous.
-	// api.POST("/delete-board", deleteBoard)
 	api.POST("/configure-server", configureServer)
+	api.POST("/notification", sendNotification)
 
 	// Partials.
 	// TODO(Kagami): Rewrite client to JSON API.
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cutechan/cutechan/go/auth"
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/db"
+)
+
+type noteRequest struct {
+	Post uint64 `json:"post"`
+	ByIP bool   `json:"byIP"`
+	Text string `json:"text"`
+}
+
+// Search staff notes of the board. Query can be IP address, it's
+// matched against hashed IPs of notes then.
+func serveNotes(w http.ResponseWriter, r *http.Request) {
+	board := getParam(r, "board")
+	if _, ok := assertCanBanAPI(w, r, board); !ok {
+		return
+	}
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	notes, err := db.SearchNotes(board, q, auth.HashIP(q))
+	if err != nil {
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	serveJSON(w, r, notes)
+}
+
+// Serve notes of the post and its author.
+func servePostNotes(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(getParam(r, "id"), 10, 64)
+	if err != nil {
+		serveErrorJSON(w, r, aerrNoPost)
+		return
+	}
+	board, _, ok := assertCanModeratePostAPI(w, r, id, auth.Moderator)
+	if !ok {
+		return
+	}
+	ip, err := db.GetIP(id)
+	if err != nil {
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	notes, err := db.GetPostNotes(board, id, auth.HashIP(ip))
+	if err != nil {
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	serveJSON(w, r, notes)
+}
+
+// Attach staff note to the post or to its author's IP.
+func createNote(w http.ResponseWriter, r *http.Request) {
+	var req noteRequest
+	if err := readJSON(r, &req); err != nil {
+		serveErrorJSON(w, r, err)
+		return
+	}
+	req.Text = strings.TrimSpace(req.Text)
+	if req.Text == "" || len(req.Text) > common.MaxLenStaffNote {
+		serveErrorJSON(w, r, aerrInvalidNote)
+		return
+	}
+	board, ss, ok := assertCanModeratePostAPI(w, r, req.Post, auth.Moderator)
+	if !ok {
+		return
+	}
+	var ipHash string
+	if req.ByIP {
+		ip, err := db.GetIP(req.Post)
+		if err != nil {
+			serveErrorJSON(w, r, aerrInternal.Hide(err))
+			return
+		}
+		if ipHash = auth.HashIP(ip); ipHash == "" {
+			serveErrorJSON(w, r, aerrNoPostIP)
+			return
+		}
+	}
+
+	note, err := db.InsertNote(board, req.Post, ipHash, req.Text, ss.UserID)
+	if err != nil {
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	serveJSON(w, r, note)
+}
+
+// Remove staff note.
+func deleteNote(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID uint64 `json:"id"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		serveErrorJSON(w, r, err)
+		return
+	}
+	board, err := db.GetNoteBoard(req.ID)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		serveErrorJSON(w, r, aerrNoNote)
+		return
+	default:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	if _, ok := assertCanBanAPI(w, r, board); !ok {
+		return
+	}
+
+	switch err := db.DeleteNote(req.ID); err {
+	case nil:
+		serveEmptyJSON(w, r)
+	case sql.ErrNoRows:
+		serveErrorJSON(w, r, aerrNoNote)
+	default:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+	}
+}
@@ -13,6 +13,8 @@ import (
 	"github.com/cutechan/cutechan/go/config"
 	"github.com/cutechan/cutechan/go/db"
 	"github.com/cutechan/cutechan/go/feeds"
+	"github.com/cutechan/cutechan/go/file"
+	"github.com/cutechan/cutechan/go/geoip"
 	"github.com/cutechan/cutechan/go/websockets"
 )
 
@@ -30,6 +32,18 @@ func servePost(w http.ResponseWriter, r *http.Request) {
 		if !assertNotModOnly(w, r, post.Board, ss) {
 			return
 		}
+		if post.Shadow && !canSeeShadowPost(r, post.ID, post.Board, ss) {
+			serve404(w, r)
+			return
+		}
+		if post.Held && !canPerform(ss, auth.Moderator) {
+			serve404(w, r)
+			return
+		}
+		if !canPerform(ss, auth.Moderator) {
+			post.Shadow = false
+		}
+		file.SignFiles(&post.Post)
 		serveJSON(w, r, post)
 	case sql.ErrNoRows:
 		serve404(w, r)
@@ -38,6 +52,24 @@ func servePost(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Shadow banned posts are only visible to moderators and the author.
+func canSeeShadowPost(
+	r *http.Request,
+	id uint64,
+	board string,
+	ss *auth.Session,
+) bool {
+	if canPerform(ss, auth.Moderator) {
+		return true
+	}
+	ip, err := auth.GetIP(r)
+	if err != nil {
+		return false
+	}
+	postIP, err := db.GetIP(id)
+	return err == nil && postIP == ip
+}
+
 // Client should get token and solve challenge in order to post.
 func createPostToken(w http.ResponseWriter, r *http.Request) {
 	ip, err := auth.GetIP(r)
@@ -77,14 +109,11 @@ func createThread(w http.ResponseWriter, r *http.Request) {
 
 	post, err := websockets.CreateThread(req)
 	if err != nil {
-		// TODO(Kagami): Not all errors are 400.
-		// TODO(Kagami): Write JSON errors instead.
-		text400(w, err)
+		serveCreationError(w, r, err)
 		return
 	}
 
-	res := map[string]uint64{"id": post.ID}
-	serveJSON(w, r, res)
+	serveCreated(w, r, post)
 }
 
 // Create post.
@@ -102,7 +131,12 @@ func createPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	ok, err = db.ValidateOP(op, req.Board)
-	if err != nil {
+	switch err {
+	case nil:
+	case db.ErrThreadLocked:
+		serveErrorJSON(w, r, aerrThreadLocked)
+		return
+	default:
 		text500(w, r, err)
 		return
 	}
@@ -113,15 +147,46 @@ func createPost(w http.ResponseWriter, r *http.Request) {
 
 	post, msg, err := websockets.CreatePost(req, op)
 	if err != nil {
-		text400(w, err)
+		serveCreationError(w, r, err)
 		return
 	}
-	feeds.InsertPostInto(post.StandalonePost, msg)
+	switch {
+	case post.Held:
+		// Published on approval.
+	case post.Shadow:
+		feeds.InsertShadowPostInto(post.StandalonePost, post.IP, msg)
+	default:
+		feeds.InsertPostInto(post.StandalonePost, msg)
+	}
 
-	res := map[string]uint64{"id": post.ID}
+	serveCreated(w, r, post)
+}
+
+// Held posts aren't visible yet so client should notify the author.
+func serveCreated(w http.ResponseWriter, r *http.Request, post db.Post) {
+	res := map[string]interface{}{"id": post.ID}
+	if post.Held {
+		res["held"] = true
+	}
 	serveJSON(w, r, res)
 }
 
+// Automod errors are shown to the user as is so they can act on them.
+func serveCreationError(w http.ResponseWriter, r *http.Request, err error) {
+	if _, ok := err.(websockets.AutomodError); ok {
+		serveErrorJSON(w, r, aerrorFrom(403, err))
+		return
+	}
+	switch err {
+	case websockets.ErrCaptchaRequired:
+		serveErrorJSON(w, r, aerrCaptchaRequired)
+	default:
+		// TODO(Kagami): Not all errors are 400.
+		// TODO(Kagami): Write JSON errors instead.
+		text400(w, err)
+	}
+}
+
 // ok = false if failed and caller should return.
 func parsePostCreationForm(w http.ResponseWriter, r *http.Request) (
 	req websockets.PostCreationRequest, ok bool,
@@ -148,24 +213,42 @@ func parsePostCreationForm(w http.ResponseWriter, r *http.Request) (
 	if !assertNotReadOnlyAPI(w, board, ss) {
 		return
 	}
-	ip, allowed := assertNotBannedAPI(w, r, board)
+	ip, allowed := assertNotBannedAPI(w, r, board, ss)
 	if !allowed {
 		return
 	}
 
+	// Files fetched by URL are passed as tokens.
 	fhs := m.File["files[]"]
-	if len(fhs) > config.Get().MaxFiles {
+	urlTokens := f["tokens[]"]
+	policy := config.GetUploadPolicy(board)
+	if policy.TextOnly && len(fhs)+len(urlTokens) != 0 {
+		serveErrorJSON(w, r, aerrTextOnly)
+		return
+	}
+	if len(fhs)+len(urlTokens) > policy.MaxFiles {
 		serveErrorJSON(w, r, aerrTooManyFiles)
 		return
 	}
-	tokens := make([]string, len(fhs))
-	for i, fh := range fhs {
-		res, err := uploadFile(fh)
+	tokens := make([]string, 0, len(fhs)+len(urlTokens))
+	for _, fh := range fhs {
+		res, err := uploadFile(fh, policy)
 		if err != nil {
 			serveErrorJSON(w, r, err)
 			return
 		}
-		tokens[i] = res.token
+		tokens = append(tokens, res.token)
+	}
+	tokens = append(tokens, urlTokens...)
+	// Indexes of attachments in the same order.
+	spoilers := make([]bool, len(tokens))
+	for _, v := range f["spoilers[]"] {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n >= len(spoilers) {
+			serveErrorJSON(w, r, aerrParseForm)
+			return
+		}
+		spoilers[n] = true
 	}
 
 	// NOTE(Kagami): Browsers use CRLF newlines in form-data requests,
@@ -177,15 +260,20 @@ func parsePostCreationForm(w http.ResponseWriter, r *http.Request) (
 
 	modOnly := config.IsModOnlyBoard(board)
 	req = websockets.PostCreationRequest{
-		FilesRequest: websockets.FilesRequest{tokens},
+		FilesRequest: websockets.FilesRequest{Tokens: tokens, Spoilers: spoilers},
 		Board:        board,
 		Ip:           ip,
+		Country:      geoip.CountryFromReq(r),
 		Body:         body,
 		Token:        f.Get("token"),
 		Sign:         f.Get("sign"),
 		ShowBadge:    f.Get("showBadge") == "on" || modOnly,
 		ShowName:     modOnly,
 		Session:      ss,
+		Captcha: auth.Captcha{
+			CaptchaID: f.Get("captchaID"),
+			Solution:  f.Get("captcha"),
+		},
 	}
 	ok = true
 	return
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cutechan/cutechan/go/auth"
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/db"
+)
+
+type reportRequest struct {
+	ID     uint64              `json:"id"`
+	Reason common.ReportReason `json:"reason"`
+	Text   string              `json:"text"`
+}
+
+type resolveReportRequest struct {
+	ID         uint64                  `json:"id"`
+	Resolution common.ReportResolution `json:"resolution"`
+	// Ban options, same as in ban request.
+	Duration uint64 `json:"duration"`
+	Reason   string `json:"reason"`
+}
+
+// Report rule-breaking post to the board moderators.
+func createReport(w http.ResponseWriter, r *http.Request) {
+	var req reportRequest
+	if err := readJSON(r, &req); err != nil {
+		serveErrorJSON(w, r, err)
+		return
+	}
+	req.Text = strings.TrimSpace(req.Text)
+	if !req.Reason.IsValid() || len(req.Text) > common.MaxLenReportText {
+		serveErrorJSON(w, r, aerrInvalidReport)
+		return
+	}
+	board, err := db.GetPostBoard(req.ID)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		serveErrorJSON(w, r, aerrNoPost)
+		return
+	default:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	ss, _ := getSession(r, board)
+	if !checkModOnly(board, ss) {
+		serveErrorJSON(w, r, aerrNoPost)
+		return
+	}
+	ip, err := auth.GetIP(r)
+	if err != nil {
+		serveErrorJSON(w, r, aerrorFrom(400, err))
+		return
+	}
+	if exceeded, err := auth.IncrementReportScore(ip); exceeded || err != nil {
+		serveErrorJSON(w, r, aerrTooManyReports)
+		return
+	}
+
+	switch inserted, err := db.InsertReport(req.ID, ip, req.Reason, req.Text); {
+	case err != nil:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+	case !inserted:
+		serveErrorJSON(w, r, aerrDupReport)
+	default:
+		serveEmptyJSON(w, r)
+	}
+}
+
+// Serve moderator queue of pending reports on the board.
+func serveReports(w http.ResponseWriter, r *http.Request) {
+	board := getParam(r, "board")
+	if !assertBoardAPI(w, board) {
+		return
+	}
+	ss, _ := getSession(r, board)
+	if !canPerform(ss, auth.Moderator) {
+		serveErrorJSON(w, r, aerrAccessDenied)
+		return
+	}
+	posts, err := db.GetReports(board)
+	if err != nil {
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	serveJSON(w, r, posts)
+}
+
+// Resolve reports on the post by dismissing them or taking action on
+// the post.
+func resolveReport(w http.ResponseWriter, r *http.Request) {
+	var req resolveReportRequest
+	if err := readJSON(r, &req); err != nil {
+		serveErrorJSON(w, r, err)
+		return
+	}
+	board, ss, ok := assertCanModeratePostAPI(w, r, req.ID, auth.Moderator)
+	if !ok {
+		return
+	}
+
+	var err error
+	switch req.Resolution {
+	case common.ReportDismiss:
+		err = db.DismissReports(req.ID, ss.UserID)
+	case common.ReportDelete:
+		// Reports are removed together with the post.
+		err = db.DeletePost(req.ID, ss.UserID)
+	case common.ReportBan:
+		if req.Reason == "" || len(req.Reason) > common.MaxBanReasonLength {
+			serveErrorJSON(w, r, aerrInvalidReason)
+			return
+		}
+		if req.Duration == 0 {
+			serveErrorJSON(w, r, aerrorFrom(400, errNoDuration))
+			return
+		}
+		expires := time.Now().Add(time.Duration(req.Duration) * time.Minute)
+		err = applyBan(
+			board, req.Reason, ss.UserID, expires, auth.RegularBan, req.ID)
+		if err == nil {
+			err = db.ClearReports(req.ID)
+		}
+	default:
+		serveErrorJSON(w, r, aerrInvalidReport)
+		return
+	}
+
+	switch err {
+	case nil:
+		serveEmptyJSON(w, r)
+	case sql.ErrNoRows:
+		serveErrorJSON(w, r, aerrNoReports)
+	default:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+	}
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/db"
+	"github.com/cutechan/cutechan/go/file"
+	"github.com/cutechan/cutechan/go/ipc"
+)
+
+// Rethumb regenerates thumbnails of images matching the filter, e.g.
+// after thumbnailer settings were changed. Images are processed by jobs
+// concurrent thumbnailer processes. Threads containing updated images
+// are bumped so cached pages are rebuilt.
+func Rethumb(user string, jobs int, filter db.ImageFilter) (err error) {
+	var mu sync.Mutex
+	var done, failed int
+	after := ""
+	for {
+		var imgs []common.ImageCommon
+		imgs, err = db.GetFilteredImages(after, filter, backfillBatchSize)
+		if err != nil || len(imgs) == 0 {
+			break
+		}
+		after = imgs[len(imgs)-1].SHA1
+
+		ch := make(chan common.ImageCommon)
+		var wg sync.WaitGroup
+		for i := 0; i < jobs; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for img := range ch {
+					err := rethumb(user, img)
+					if err != nil {
+						log.Printf("rethumb: %s: %v", img.SHA1, err)
+					}
+					mu.Lock()
+					if err == nil {
+						done++
+					} else {
+						failed++
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+		for _, img := range imgs {
+			ch <- img
+		}
+		close(ch)
+		wg.Wait()
+		log.Printf("rethumb: %d done, %d failed", done, failed)
+	}
+	return
+}
+
+// Existing thumbnails are replaced in place so they are never missing.
+// Renditions which are gone are deleted only after the new ones are
+// recorded.
+func rethumb(user string, img common.ImageCommon) error {
+	if !file.HasThumb(&img) {
+		return nil
+	}
+	srcData, err := file.Backend.ReadSource(img.SHA1, img.FileType)
+	if err != nil {
+		return err
+	}
+	thumb, err := ipc.GetThumbnail(user, srcData)
+	if err != nil {
+		return err
+	}
+
+	updated := img
+	updated.ThumbType = common.JPEG
+	if thumb.HasAlpha {
+		updated.ThumbType = common.PNG
+	}
+	updated.Dims[2] = thumb.Width
+	updated.Dims[3] = thumb.Height
+	updated.ThumbSizes = nil
+	updated.WebP = false
+	thumbs := []file.Thumb{{Type: updated.ThumbType, Data: thumb.Data}}
+	for _, t := range mapThumbVariants(&updated, thumb) {
+		// Already added as the base thumbnail.
+		if t.Size == 0 && t.Type == updated.ThumbType {
+			continue
+		}
+		thumbs = append(thumbs, t)
+	}
+	if err = file.Backend.ReplaceThumbs(img.SHA1, thumbs); err != nil {
+		return err
+	}
+	if err = db.SetImageThumb(updated); err != nil {
+		return err
+	}
+
+	current := getImageObjects(&updated)
+	for name := range getImageObjects(&img) {
+		if _, ok := current[name]; ok {
+			continue
+		}
+		if err = file.Backend.Remove(name); err != nil && err != file.ErrNotExist {
+			return fmt.Errorf("cannot remove stale %s: %v", name, err)
+		}
+	}
+	return nil
+}
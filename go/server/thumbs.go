@@ -0,0 +1,73 @@
+package server
+
+import (
+	"log"
+
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/db"
+	"github.com/cutechan/cutechan/go/file"
+	"github.com/cutechan/cutechan/go/ipc"
+)
+
+const backfillBatchSize = 100
+
+// BackfillThumbs generates missing thumbnail renditions for uploads
+// made before they were introduced. Base thumbnails are left intact.
+// Safe to interrupt and run again.
+func BackfillThumbs(user string) (err error) {
+	var done, failed int
+	after := ""
+	for {
+		var imgs []common.ImageCommon
+		imgs, err = db.GetImagesWithoutVariants(after, backfillBatchSize)
+		if err != nil || len(imgs) == 0 {
+			break
+		}
+		for _, img := range imgs {
+			after = img.SHA1
+			if backfillThumb(user, img) {
+				done++
+			} else {
+				failed++
+			}
+		}
+		log.Printf("backfill: %d done, %d failed", done, failed)
+	}
+	return
+}
+
+// Errors are only logged so single broken file won't stop the whole
+// process. Such files will be retried on the next run.
+func backfillThumb(user string, img common.ImageCommon) bool {
+	srcData, err := file.Backend.ReadSource(img.SHA1, img.FileType)
+	if err != nil {
+		log.Printf("backfill: %s: %v", img.SHA1, err)
+		return false
+	}
+	thumb, err := ipc.GetThumbnail(user, srcData)
+	if err != nil {
+		log.Printf("backfill: %s: %v", img.SHA1, err)
+		return false
+	}
+	var thumbs []file.Thumb
+	for _, t := range mapThumbVariants(&img, thumb) {
+		// Already stored.
+		if t.Size == 0 && t.Type != common.WEBP {
+			continue
+		}
+		thumbs = append(thumbs, t)
+	}
+	// Nothing to add, e.g. file without thumbnail, but it's still marked
+	// as processed.
+	if len(thumbs) != 0 {
+		if err := file.Backend.WriteThumbs(img.SHA1, thumbs); err != nil {
+			log.Printf("backfill: %s: %v", img.SHA1, err)
+			return false
+		}
+	}
+	if err := db.SetThumbVariants(img); err != nil {
+		log.Printf("backfill: %s: %v", img.SHA1, err)
+		return false
+	}
+	return true
+}
@@ -1,21 +1,27 @@
 package server
 
 import (
+	"bytes"
 	"crypto/sha1"
 	"database/sql"
 	"encoding/hex"
+	"io"
 	"io/ioutil"
 	"mime/multipart"
+	"net/http"
 
 	"github.com/cutechan/cutechan/go/common"
 	"github.com/cutechan/cutechan/go/config"
 	"github.com/cutechan/cutechan/go/db"
+	"github.com/cutechan/cutechan/go/file"
 	"github.com/cutechan/cutechan/go/ipc"
 )
 
 const (
 	// Maximum number of thumbnailer processes executing at the same time.
 	thumbProcesses = 1
+	// Number of leading bytes used to detect file type.
+	sniffLen = 512
 )
 
 var (
@@ -32,10 +38,17 @@ var (
 		"video/mp4":       common.MP4,
 		"audio/mpeg":      common.MP3,
 	}
+
+	// Map of thumbnail MIME types to the constants used internally.
+	thumbMimeTypes = map[string]uint8{
+		"image/jpeg": common.JPEG,
+		"image/png":  common.PNG,
+		"image/webp": common.WEBP,
+	}
 )
 
 type jobRequest struct {
-	fd       multipart.File
+	fd       io.Reader
 	jresults chan<- jobResult
 }
 
@@ -49,8 +62,11 @@ type uploadResult struct {
 	token string
 }
 
-func uploadFile(fh *multipart.FileHeader) (res uploadResult, err error) {
-	if fh.Size > config.Get().MaxSize*1024*1024 {
+// Process uploaded file according to the policy. File type is sniffed
+// before thumbnailing but is known for sure only after it, so files
+// which weren't recognized early are left to be removed by upkeep task.
+func uploadFile(fh *multipart.FileHeader, policy config.UploadPolicy) (res uploadResult, err error) {
+	if fh.Size > policy.MaxSize {
 		err = aerrTooLarge
 		return
 	}
@@ -61,7 +77,59 @@ func uploadFile(fh *multipart.FileHeader) (res uploadResult, err error) {
 		return
 	}
 	defer fd.Close()
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(fd, head)
+	switch err {
+	case nil, io.EOF, io.ErrUnexpectedEOF:
+	default:
+		err = aerrUploadRead.Hide(err)
+		return
+	}
+	head = head[:n]
+	if err = sniffFileType(head, policy); err != nil {
+		return
+	}
+	if res, err = processUpload(io.MultiReader(bytes.NewReader(head), fd)); err != nil {
+		return
+	}
+	err = checkFileType(res, policy)
+	return
+}
+
+// Reports whether files of the type can be uploaded. Other known types
+// are only used for thumbnails.
+func isUploadable(fileType uint8) bool {
+	for _, t := range mimeTypes {
+		if t == fileType {
+			return true
+		}
+	}
+	return false
+}
+
+// Reject files which are recognized as disallowed by their contents
+// without running thumbnailer on them.
+func sniffFileType(head []byte, policy config.UploadPolicy) error {
+	t, ok := mimeTypes[http.DetectContentType(head)]
+	if ok && !policy.IsAllowed(t) {
+		return aerrFileNotAllowed
+	}
+	return nil
+}
+
+// Drop image token of disallowed file so it can't be attached anyway.
+func checkFileType(res uploadResult, policy config.UploadPolicy) error {
+	if policy.IsAllowed(res.file.FileType) {
+		return nil
+	}
+	if err := db.DeleteImageToken(res.token); err != nil {
+		return aerrInternal.Hide(err)
+	}
+	return aerrFileNotAllowed
+}
 
+// Pass file to thumbnailer workers.
+func processUpload(fd io.Reader) (res uploadResult, err error) {
 	jresults := make(chan jobResult)
 	jreq := jobRequest{fd, jresults}
 	jobs <- jreq
@@ -115,7 +183,7 @@ func newFileToken(file *common.ImageCommon) (res uploadResult, err error) {
 
 // Create a new thumbnail, commit its resources to the DB and
 // filesystem, and return resulting token.
-func saveFile(user string, srcData []byte, file *common.ImageCommon) (res uploadResult, err error) {
+func saveFile(user string, srcData []byte, img *common.ImageCommon) (res uploadResult, err error) {
 	thumb, err := ipc.GetThumbnail(user, srcData)
 	switch err {
 	case nil:
@@ -129,6 +197,9 @@ func saveFile(user string, srcData []byte, file *common.ImageCommon) (res upload
 	case ipc.ErrThumbTracks:
 		err = aerrNoTracks
 		return
+	case ipc.ErrThumbTimeout:
+		err = aerrThumbTimeout
+		return
 	case ipc.ErrThumbProcess:
 		err = aerrCorrupted
 		return
@@ -138,24 +209,46 @@ func saveFile(user string, srcData []byte, file *common.ImageCommon) (res upload
 	}
 
 	// Map fields.
-	file.Size = len(srcData)
-	file.Video = thumb.HasVideo
-	file.Audio = thumb.HasAudio
-	file.FileType = mimeTypes[thumb.Mime]
+	img.Size = len(srcData)
+	img.Video = thumb.HasVideo
+	img.Audio = thumb.HasAudio
+	img.FileType = mimeTypes[thumb.Mime]
 	if thumb.HasAlpha {
-		file.ThumbType = common.PNG
+		img.ThumbType = common.PNG
 	} else {
-		file.ThumbType = common.JPEG
+		img.ThumbType = common.JPEG
 	}
-	file.Length = thumb.Duration
-	file.Title = thumb.Title
-	file.Dims = [4]uint16{thumb.SrcWidth, thumb.SrcHeight, thumb.Width, thumb.Height}
+	img.Length = thumb.Duration
+	img.Title = thumb.Title
+	img.Dims = [4]uint16{thumb.SrcWidth, thumb.SrcHeight, thumb.Width, thumb.Height}
+	thumbs := mapThumbVariants(img, thumb)
 
-	if err = db.AllocateImage(srcData, thumb.Data, *file); err != nil {
+	if err = db.AllocateImage(srcData, thumb.Data, thumbs, *img); err != nil {
 		err = aerrInternal.Hide(err)
 		return
 	}
-	return newFileToken(file)
+	return newFileToken(img)
+}
+
+// Fill image's rendition info and return additional thumbnails to store
+// along with the base one. Base size is recorded even if there are no
+// other renditions, so file isn't picked up by backfill.
+func mapThumbVariants(img *common.ImageCommon, thumb *ipc.Thumb) (thumbs []file.Thumb) {
+	img.ThumbSizes = []uint16{thumb.Size}
+	for _, v := range thumb.Variants {
+		t := file.Thumb{Type: thumbMimeTypes[v.Mime], Size: v.Size, Data: v.Data}
+		if t.Type == common.WEBP {
+			img.WebP = true
+		} else {
+			img.ThumbSizes = append(img.ThumbSizes, v.Size)
+		}
+		// Base size is stored without suffix.
+		if t.Size == thumb.Size {
+			t.Size = 0
+		}
+		thumbs = append(thumbs, t)
+	}
+	return
 }
 
 // Start thumbnailer workers.
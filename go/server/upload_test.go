@@ -0,0 +1,62 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/config"
+)
+
+func TestSniffFileType(t *testing.T) {
+	policy := config.UploadPolicy{FileTypes: map[uint8]bool{common.PNG: true}}
+	cases := [...]struct {
+		name string
+		head []byte
+		err  error
+	}{
+		{"allowed", []byte("\x89PNG\r\n\x1a\n"), nil},
+		{"disallowed", []byte("GIF89a"), aerrFileNotAllowed},
+		{"unknown", []byte{0xff, 0xfb, 0x90}, nil},
+		{"empty", nil, nil},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if err := sniffFileType(c.head, policy); err != c.err {
+				t.Fatalf("expected %v, got %v", c.err, err)
+			}
+		})
+	}
+}
+
+func TestCheckUploadPolicy(t *testing.T) {
+	defer config.Set(config.ServerConfig{})
+	config.Set(config.ServerConfig{
+		ServerPublic: config.ServerPublic{MaxSize: 10, MaxFiles: 4},
+	})
+	cases := [...]struct {
+		name  string
+		types []string
+		valid bool
+	}{
+		{"any", nil, true},
+		{"uploadable", []string{"png", "webm", "mp3"}, true},
+		{"unknown", []string{"exe"}, false},
+		{"thumbnail only", []string{"webp"}, false},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			conf := config.BoardConfig{
+				BoardPublic: config.BoardPublic{
+					MaxSize:   5,
+					MaxFiles:  2,
+					FileTypes: c.types,
+				},
+			}
+			if checkUploadPolicy(conf) != c.valid {
+				t.Fatalf("expected valid=%v", c.valid)
+			}
+		})
+	}
+}
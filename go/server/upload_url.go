@@ -0,0 +1,241 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cutechan/cutechan/go/auth"
+	"github.com/cutechan/cutechan/go/config"
+	"github.com/cutechan/cutechan/go/util"
+)
+
+const (
+	fetchTimeout      = 30 * time.Second
+	fetchDialTimeout  = 5 * time.Second
+	fetchMaxRedirects = 3
+)
+
+var (
+	errForbiddenAddr = errors.New("forbidden address")
+
+	// Shared address ranges not covered by net.IP methods.
+	forbiddenNets = mustParseCIDRs(
+		"0.0.0.0/8",
+		"100.64.0.0/10",
+		"192.0.0.0/24",
+		"198.18.0.0/15",
+		"240.0.0.0/4",
+		"64:ff9b::/96",
+	)
+
+	fetchClient = &http.Client{
+		Timeout: fetchTimeout,
+		Transport: &http.Transport{
+			// Don't go through environment proxies, address check must be
+			// done on the actual target.
+			Proxy:                 nil,
+			DialContext:           (&net.Dialer{Timeout: fetchDialTimeout, Control: checkDialAddr}).DialContext,
+			TLSHandshakeTimeout:   fetchDialTimeout,
+			ResponseHeaderTimeout: fetchTimeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       time.Minute,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= fetchMaxRedirects {
+				return errors.New("too many redirects")
+			}
+			return checkFetchURL(req.URL)
+		},
+	}
+)
+
+type uploadURLRequest struct {
+	Board string `json:"board"`
+	URL   string `json:"url"`
+}
+
+func mustParseCIDRs(cidrs ...string) (nets []*net.IPNet) {
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return
+}
+
+// Fetch remote file on behalf of the poster and return image token to
+// attach to the post, same as file uploaded with the post form. Tokens
+// expire quickly so clients should request it right before posting.
+func serveUploadURL(w http.ResponseWriter, r *http.Request) {
+	var req uploadURLRequest
+	if err := readJSON(r, &req); err != nil {
+		serveErrorJSON(w, r, err)
+		return
+	}
+	if !assertBoardAPI(w, req.Board) {
+		return
+	}
+	if req.Board == "all" {
+		text400(w, errInvalidBoard)
+		return
+	}
+	ss, _ := getSession(r, req.Board)
+	if !assertNotModOnlyAPI(w, req.Board, ss) {
+		return
+	}
+	if !assertNotReadOnlyAPI(w, req.Board, ss) {
+		return
+	}
+	ip, ok := assertNotBannedAPI(w, r, req.Board, ss)
+	if !ok {
+		return
+	}
+
+	policy := config.GetUploadPolicy(req.Board)
+	if policy.TextOnly {
+		serveErrorJSON(w, r, aerrTextOnly)
+		return
+	}
+	if exceeded, err := auth.IncrementFetchScore(ip); exceeded || err != nil {
+		serveErrorJSON(w, r, aerrTooManyFetches)
+		return
+	}
+	res, err := uploadURL(req.URL, policy)
+	if err != nil {
+		serveErrorJSON(w, r, err)
+		return
+	}
+	serveJSON(w, r, map[string]interface{}{
+		"token": res.token,
+		"file":  res.file,
+	})
+}
+
+func uploadURL(rawURL string, policy config.UploadPolicy) (res uploadResult, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		err = aerrBadURL
+		return
+	}
+	if err = checkFetchURL(u); err != nil {
+		return
+	}
+
+	httpRes, err := fetchClient.Get(u.String())
+	if err != nil {
+		// Redirect check errors are wrapped by client.
+		var aerr ApiError
+		switch {
+		case errors.As(err, &aerr):
+			err = aerr
+		case errors.Is(err, errForbiddenAddr):
+			err = aerrForbiddenURL
+		default:
+			err = aerrFetchURL.Hide(err)
+		}
+		return
+	}
+	defer httpRes.Body.Close()
+	if httpRes.StatusCode != 200 {
+		err = aerrFetchURL.Hide(fmt.Errorf("bad response code: %d", httpRes.StatusCode))
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(httpRes.Header.Get("Content-Type"))
+	if _, ok := mimeTypes[mediaType]; !ok {
+		err = aerrBadContentType
+		return
+	}
+
+	maxSize := policy.MaxSize
+	if httpRes.ContentLength > maxSize {
+		err = aerrTooLarge
+		return
+	}
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(httpRes.Body, maxSize+1))
+	if err != nil {
+		err = aerrFetchURL.Hide(err)
+		return
+	}
+	if n > maxSize {
+		err = aerrTooLarge
+		return
+	}
+	if err = sniffFileType(buf.Bytes(), policy); err != nil {
+		return
+	}
+	if res, err = processUpload(&buf); err != nil {
+		return
+	}
+	err = checkFileType(res, policy)
+	return
+}
+
+// Only plain HTTP(S) URLs with allowed domains are fetched. Target
+// address is checked separately at connect time, so hostnames resolving
+// to private addresses are caught too.
+func checkFetchURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return aerrBadURL
+	}
+	if u.User != nil {
+		return aerrBadURL
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return aerrBadURL
+	}
+	conf := config.Get()
+	if util.MatchDomain(host, conf.UploadURLDeny) {
+		return aerrForbiddenURL
+	}
+	if strings.TrimSpace(conf.UploadURLAllow) != "" && !util.MatchDomain(host, conf.UploadURLAllow) {
+		return aerrForbiddenURL
+	}
+	if ip := net.ParseIP(host); ip != nil && isForbiddenIP(ip) {
+		return aerrForbiddenURL
+	}
+	return nil
+}
+
+func isForbiddenIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, n := range forbiddenNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Called for every connection attempt with resolved address, which
+// protects against DNS rebinding and redirects to internal hosts.
+func checkDialAddr(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || isForbiddenIP(ip) {
+		return errForbiddenAddr
+	}
+	return nil
+}
@@ -46,6 +46,7 @@ type FileContext struct {
 	HasLength  bool
 	Length     string
 	Record     bool
+	Spoiler    bool
 	Size       string
 	TWidth     uint16
 	THeight    uint16
@@ -54,6 +55,11 @@ type FileContext struct {
 	DName      string
 	SourcePath string
 	ThumbPath  string
+	// Optional thumbnail renditions for high-density screens and
+	// browsers with WebP support.
+	ThumbSrcset string
+	HasWebP     bool
+	WebPSrcset  string
 }
 
 type PostLinkContext struct {
@@ -109,6 +115,9 @@ func (ctx *PostContext) PostClass() string {
 			break
 		}
 	}
+	if ctx.post.Shadow {
+		classes = append(classes, "post_shadow")
+	}
 	if ctx.post.UserID == "" {
 		classes = append(classes, getByAnonCls())
 	} else {
@@ -185,23 +194,27 @@ func (ctx *PostContext) Files() (files []string) {
 
 func (ctx *PostContext) renderFile(img *common.Image, n int) string {
 	fileCtx := FileContext{
-		SHA1:       img.SHA1,
-		HasTitle:   img.Title != "",
-		LCopy:      lang.Get(ctx.Lang, "clickToCopy"),
-		Title:      img.Title,
-		HasVideo:   img.Video,
-		HasAudio:   img.Audio,
-		HasLength:  img.Video || img.Audio,
-		Length:     duration(img.Length),
-		Record:     img.Audio && !img.Video,
-		Size:       fileSize(ctx.Lang, img.Size),
-		Width:      img.Dims[0],
-		Height:     img.Dims[1],
-		TWidth:     img.Dims[2],
-		THeight:    img.Dims[3],
-		DName:      ctx.getDownloadName(n, img),
-		SourcePath: file.SourcePath(img.FileType, img.SHA1),
-		ThumbPath:  file.ThumbPath(img.ThumbType, img.SHA1),
+		SHA1:        img.SHA1,
+		HasTitle:    img.Title != "",
+		LCopy:       lang.Get(ctx.Lang, "clickToCopy"),
+		Title:       img.Title,
+		HasVideo:    img.Video,
+		HasAudio:    img.Audio,
+		HasLength:   img.Video || img.Audio,
+		Length:      duration(img.Length),
+		Record:      img.Audio && !img.Video,
+		Spoiler:     img.Spoiler,
+		Size:        fileSize(ctx.Lang, img.Size),
+		Width:       img.Dims[0],
+		Height:      img.Dims[1],
+		TWidth:      img.Dims[2],
+		THeight:     img.Dims[3],
+		DName:       ctx.getDownloadName(n, img),
+		SourcePath:  file.SourcePath(&img.ImageCommon),
+		ThumbPath:   file.ThumbPath(&img.ImageCommon),
+		ThumbSrcset: file.ThumbSrcset(&img.ImageCommon, img.ThumbType),
+		HasWebP:     img.WebP && !img.Restricted,
+		WebPSrcset:  file.ThumbSrcset(&img.ImageCommon, common.WEBP),
 	}
 	return renderMustache("post-file", &fileCtx)
 }
@@ -117,6 +117,14 @@ var specs = map[string][]inputSpec{
 			ID:   "kpopnetRootOverride",
 			Type: _string,
 		},
+		{
+			ID:   "uploadURLAllow",
+			Type: _string,
+		},
+		{
+			ID:   "uploadURLDeny",
+			Type: _string,
+		},
 	},
 }
 
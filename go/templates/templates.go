@@ -11,6 +11,8 @@ import (
 	"strings"
 
 	"github.com/cutechan/cutechan/go/auth"
+	"github.com/cutechan/cutechan/go/automod"
+	"github.com/cutechan/cutechan/go/common"
 	"github.com/cutechan/cutechan/go/config"
 	"github.com/cutechan/cutechan/go/lang"
 
@@ -73,14 +75,21 @@ func Stickers(p Params, stickHTML []byte) []byte {
 	return Page(p, title, html, false)
 }
 
+func Banned(p Params, bans []common.BanInfo) []byte {
+	html := renderBanned(p.Lang, bans)
+	title := lang.Get(p.Lang, "banned")
+	return Page(p, title, html, false)
+}
+
 func Admin(
 	p Params,
 	cs config.BoardConfigs,
 	staff auth.Staff,
 	bans auth.BanRecords,
+	rules automod.Rules,
 	log auth.ModLogRecords,
 ) []byte {
-	html := renderAdmin(cs, staff, bans, log)
+	html := renderAdmin(cs, staff, bans, rules, log)
 	title := lang.Get(p.Lang, "Admin")
 	return Page(p, title, html, false)
 }
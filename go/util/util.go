@@ -6,6 +6,7 @@ import (
 	"crypto/md5"
 	"encoding/base64"
 	"math/rand"
+	"strings"
 	"time"
 )
 
@@ -96,3 +97,15 @@ func RunTasks(tasks [][]Task) (err error) {
 	}
 	return
 }
+
+// MatchDomain reports whether host is one of space-separated domains or
+// their subdomain.
+func MatchDomain(host, domains string) bool {
+	for _, domain := range strings.Fields(strings.ToLower(domains)) {
+		domain = strings.TrimPrefix(domain, ".")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package websockets
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cutechan/cutechan/go/auth"
+	"github.com/cutechan/cutechan/go/automod"
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/config"
+	"github.com/cutechan/cutechan/go/db"
+	. "github.com/cutechan/cutechan/go/test"
+
+	"github.com/dchest/captcha"
+)
+
+// Captcha store which remembers digits to solve captchas in tests.
+type digitStore struct {
+	captcha.Store
+	digits map[string][]byte
+}
+
+func (s digitStore) Set(id string, digits []byte) {
+	s.digits[id] = digits
+	s.Store.Set(id, digits)
+}
+
+// Solve new captcha created by the API handler.
+func solveCaptcha(s digitStore) auth.Captcha {
+	w := httptest.NewRecorder()
+	auth.NewCaptchaID(w, httptest.NewRequest("POST", "/api/captcha", nil))
+	id := w.Body.String()
+	solution := make([]byte, 0, len(s.digits[id]))
+	for _, d := range s.digits[id] {
+		solution = append(solution, '0'+d)
+	}
+	return auth.Captcha{CaptchaID: id, Solution: string(solution)}
+}
+
+func assertAutomodLog(t *testing.T, ids ...uint64) {
+	log, err := db.GetModLog([]string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, len(log), len(ids))
+	for i, rec := range log {
+		AssertDeepEquals(t, rec.Type, auth.AutomodMatch)
+		AssertDeepEquals(t, rec.ID, ids[i])
+	}
+}
+
+func TestCheckAutomod(t *testing.T) {
+	assertTableClear(t, "automod_matches", "mod_log")
+	store := digitStore{
+		Store:  captcha.NewMemoryStore(16, time.Minute),
+		digits: make(map[string][]byte),
+	}
+	captcha.SetCustomStore(store)
+	defer automod.SetRules()
+	automod.SetRules(
+		automod.Rule{
+			Board:     "a",
+			Condition: automod.BodyMatch,
+			Pattern:   "reject",
+			Action:    automod.Reject,
+			Message:   "no",
+		},
+		automod.Rule{
+			Board:     "a",
+			Condition: automod.BodyMatch,
+			Pattern:   "dry",
+			Action:    automod.Reject,
+			DryRun:    true,
+		},
+		automod.Rule{
+			Board:     "a",
+			Condition: automod.BodyMatch,
+			Pattern:   "hold",
+			Action:    automod.Hold,
+		},
+		automod.Rule{
+			Board:     "a",
+			Condition: automod.BodyMatch,
+			Pattern:   "shadow",
+			Action:    automod.ShadowBan,
+		},
+		automod.Rule{
+			Board:     "a",
+			Condition: automod.BodyMatch,
+			Pattern:   "captcha",
+			Action:    automod.RequireCaptcha,
+		},
+		automod.Rule{
+			Board:     "a",
+			Condition: automod.BodyMatch,
+			Pattern:   "link",
+			Action:    automod.RequireCaptcha,
+		},
+	)
+
+	cases := [...]struct {
+		name, body   string
+		captcha      bool
+		matched      int
+		held, shadow bool
+		err          error
+	}{
+		{"clean", "hello", false, 0, false, false, nil},
+		{"reject", "reject", false, 1, false, false, AutomodError{"no"}},
+		{"dry run", "dry", false, 1, false, false, nil},
+		{"hold", "hold", false, 1, true, false, nil},
+		{"shadow", "shadow", false, 1, false, true, nil},
+		{"no captcha", "captcha", false, 1, false, false, ErrCaptchaRequired},
+		{"captcha", "captcha", true, 1, false, false, nil},
+		{"two captcha rules", "captcha link", true, 2, false, false, nil},
+		{"hold and reject", "hold reject", false, 2, false, false,
+			AutomodError{"no"}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			req := PostCreationRequest{Board: "a", Body: c.body}
+			if c.captcha {
+				req.Captcha = solveCaptcha(store)
+			}
+			var post db.Post
+			matched, err := checkAutomod(&post, req)
+			if err != c.err {
+				UnexpectedError(t, err)
+			}
+			AssertDeepEquals(t, len(matched), c.matched)
+			AssertDeepEquals(t, post.Held, c.held)
+			AssertDeepEquals(t, post.Shadow, c.shadow)
+		})
+	}
+
+	// Only matches of rejected posts are logged before insertion
+	assertAutomodLog(t, 0, 0, 0, 0)
+}
+
+func TestApplyAutomod(t *testing.T) {
+	assertTableClear(t, "boards", "automod_matches", "mod_log")
+	b := config.BoardConfig{BoardPublic: config.BoardPublic{ID: "a"}}
+	if err := db.WriteBoard(nil, b); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.StartTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.RollbackOnError(tx, &err)
+	post := db.Post{
+		StandalonePost: common.StandalonePost{
+			Post: common.Post{
+				Time: time.Now().Unix(),
+				Body: "spam",
+			},
+			Board: "a",
+		},
+		IP: "::1",
+	}
+	if post.ID, err = db.NewPostID(tx); err != nil {
+		t.Fatal(err)
+	}
+	post.OP = post.ID
+	if err = db.InsertThread(tx, post, "subject"); err != nil {
+		t.Fatal(err)
+	}
+	matched := automod.Rules{
+		{Board: "a", Action: automod.Report, Message: "spam"},
+		{Board: "a", Action: automod.Report, DryRun: true, Position: 1},
+		{Board: "a", Action: automod.Hold, Position: 2},
+	}
+	if err = applyAutomod(tx, post, matched); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	reports, err := db.GetReports("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, len(reports), 1)
+	AssertDeepEquals(t, reports[0].ID, post.ID)
+	AssertDeepEquals(t, len(reports[0].Reports), 1)
+	AssertDeepEquals(t, reports[0].Reports[0].Reason, common.ReportOther)
+	AssertDeepEquals(t, reports[0].Reports[0].Text, "spam")
+	assertAutomodLog(t, post.ID, post.ID, post.ID)
+}
@@ -15,8 +15,11 @@ import (
 	"unsafe"
 
 	"github.com/cutechan/cutechan/go/auth"
+	"github.com/cutechan/cutechan/go/automod"
 	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/config"
 	"github.com/cutechan/cutechan/go/db"
+	"github.com/cutechan/cutechan/go/file"
 	"github.com/cutechan/cutechan/go/parser"
 )
 
@@ -26,8 +29,28 @@ var (
 	errInvalidImageToken = errors.New("invalid image token")
 	errNoTextOrFiles     = errors.New("no text or files")
 	errTooManyLines      = errors.New("too many lines in post body")
+	errTextOnly          = errors.New("text only board")
+	errTooManyFiles      = errors.New("too many files")
+	errFileTooLarge      = errors.New("file too large")
+	errFileNotAllowed    = errors.New("file type not allowed")
+
+	// ErrCaptchaRequired is returned when automod rule demands captcha
+	// and it's not solved.
+	ErrCaptchaRequired = errors.New("captcha required")
 )
 
+// AutomodError is returned when post is rejected by automod rule.
+type AutomodError struct {
+	Message string
+}
+
+func (e AutomodError) Error() string {
+	if e.Message == "" {
+		return "post rejected by automod"
+	}
+	return e.Message
+}
+
 // ThreadCreationRequest contains data for creating a new thread.
 type ThreadCreationRequest struct {
 	PostCreationRequest
@@ -40,16 +63,22 @@ type PostCreationRequest struct {
 	FilesRequest FilesRequest
 	Board        string
 	Ip           string
+	Country      string
 	Body         string
 	Token        string
 	Sign         string
 	ShowBadge    bool
 	ShowName     bool
 	Session      *auth.Session
+	// Only checked if automod requires it.
+	Captcha auth.Captcha
 }
 
+// FilesRequest contains tokens of uploaded files. Spoilers, if set, has
+// the same length and marks files to be spoilered.
 type FilesRequest struct {
-	Tokens []string
+	Tokens   []string
+	Spoilers []bool
 }
 
 // CreateThread creates a new thread and writes it to the database.
@@ -73,7 +102,7 @@ func CreateThread(req ThreadCreationRequest) (
 		return
 	}
 
-	post, err = constructPost(tx, req.PostCreationRequest)
+	post, matched, err := constructPost(tx, req.PostCreationRequest)
 	if err != nil {
 		return
 	}
@@ -89,6 +118,11 @@ func CreateThread(req ThreadCreationRequest) (
 		return
 	}
 
+	err = applyAutomod(tx, post, matched)
+	if err != nil {
+		return
+	}
+
 	err = tx.Commit()
 	return
 }
@@ -109,7 +143,7 @@ func CreatePost(req PostCreationRequest, op uint64) (
 	}
 	defer db.RollbackOnError(tx, &err)
 
-	post, err = constructPost(tx, req)
+	post, matched, err := constructPost(tx, req)
 	if err != nil {
 		return
 	}
@@ -120,12 +154,19 @@ func CreatePost(req PostCreationRequest, op uint64) (
 	}
 	post.OP = op
 
+	err = db.InsertPost(tx, post)
+	if err != nil {
+		return
+	}
+
+	// Files are known to be restricted only after insertion.
+	file.SignFiles(&post.Post)
 	msg, err = common.EncodeMessage(common.MessageInsertPost, post.Post)
 	if err != nil {
 		return
 	}
 
-	err = db.InsertPost(tx, post)
+	err = applyAutomod(tx, post, matched)
 	if err != nil {
 		return
 	}
@@ -134,8 +175,11 @@ func CreatePost(req PostCreationRequest, op uint64) (
 	return
 }
 
-// Construct the common parts of the new post.
-func constructPost(tx *sql.Tx, req PostCreationRequest) (post db.Post, err error) {
+// Construct the common parts of the new post. Automod rules matched by
+// the post are returned to be applied once it's inserted.
+func constructPost(tx *sql.Tx, req PostCreationRequest) (
+	post db.Post, matched automod.Rules, err error,
+) {
 	if req.Body == "" && len(req.FilesRequest.Tokens) == 0 {
 		err = errNoTextOrFiles
 		return
@@ -149,7 +193,12 @@ func constructPost(tx *sql.Tx, req PostCreationRequest) (post db.Post, err error
 			},
 			Board: req.Board,
 		},
-		IP: req.Ip,
+		IP:     req.Ip,
+		Shadow: auth.IsShadowBanned(req.Board, req.Ip),
+	}
+	if req.Session != nil &&
+		auth.IsAccountShadowBanned(req.Board, req.Session.UserID) {
+		post.Shadow = true
 	}
 
 	// Check token and its signature.
@@ -174,6 +223,7 @@ func constructPost(tx *sql.Tx, req PostCreationRequest) (post db.Post, err error
 
 	ss := req.Session
 	if ss != nil {
+		post.Account = ss.UserID
 		// Attach staff badge if requested after validation.
 		if req.ShowBadge {
 			if ss.Positions.CurBoard >= auth.Moderator {
@@ -192,7 +242,122 @@ func constructPost(tx *sql.Tx, req PostCreationRequest) (post db.Post, err error
 		return
 	}
 
-	err = setPostFiles(tx, &post, req.FilesRequest)
+	policy := config.GetUploadPolicy(req.Board)
+	err = setPostFiles(tx, &post, req.FilesRequest, policy)
+	if err != nil {
+		return
+	}
+
+	matched, err = checkAutomod(&post, req)
+	if err != nil || post.Held {
+		return
+	}
+
+	post.Held, err = isNewPoster(req)
+	return
+}
+
+// First posts from new posters are held if the board requires so.
+// Staff is trusted.
+func isNewPoster(req PostCreationRequest) (bool, error) {
+	n := config.GetBoardConfig(req.Board).HoldPosts
+	if n == 0 {
+		return false, nil
+	}
+	account := ""
+	if ss := req.Session; ss != nil {
+		if ss.Positions.CurBoard >= auth.Moderator {
+			return false, nil
+		}
+		account = ss.UserID
+	}
+	return db.IsNewPoster(req.Board, req.Ip, account, n)
+}
+
+// Check post against automod rules of the board and take actions which
+// must precede insertion.
+func checkAutomod(post *db.Post, req PostCreationRequest) (
+	matched automod.Rules, err error,
+) {
+	p := automod.Post{
+		Board:   req.Board,
+		Body:    req.Body,
+		Country: req.Country,
+		IsNewIP: func() (bool, error) {
+			return db.IsNewIP(req.Ip)
+		},
+		RecentPosts: func() (int, error) {
+			return db.CountRecentPosts(req.Ip, time.Minute)
+		},
+	}
+	for _, img := range post.Files {
+		p.SHA1s = append(p.SHA1s, img.SHA1)
+	}
+	matched, err = automod.Check(p)
+	if err != nil {
+		return
+	}
+
+	// Captcha is single-use so it's verified once for all rules.
+	var captchaChecked, captchaOK bool
+	for _, r := range matched {
+		if r.DryRun {
+			continue
+		}
+		switch r.Action {
+		case automod.Reject:
+			err = logRejected(matched, AutomodError{r.Message})
+			return
+		case automod.RequireCaptcha:
+			if !captchaChecked {
+				captchaOK = auth.VerifyCaptcha(req.Captcha)
+				captchaChecked = true
+			}
+			if !captchaOK {
+				err = logRejected(matched, ErrCaptchaRequired)
+				return
+			}
+		case automod.Hold:
+			post.Held = true
+		case automod.ShadowBan:
+			post.Shadow = true
+		}
+	}
+	return
+}
+
+// Rejected post is never inserted, so its matches are logged outside of
+// the transaction which is rolled back.
+func logRejected(matched automod.Rules, reason error) error {
+	for _, r := range matched {
+		if err := db.LogAutomodMatch(nil, 0, r); err != nil {
+			return err
+		}
+	}
+	return reason
+}
+
+// Log automod matches on the inserted post and report it if requested.
+func applyAutomod(tx *sql.Tx, post db.Post, matched automod.Rules) (
+	err error,
+) {
+	for _, r := range matched {
+		err = db.LogAutomodMatch(tx, post.ID, r)
+		if err != nil {
+			return
+		}
+		if r.DryRun || r.Action != automod.Report {
+			continue
+		}
+		text := r.Message
+		if text == "" {
+			text = "automod"
+		}
+		err = db.InsertAutomodReport(tx, post.ID, post.IP, text)
+		if err != nil {
+			return
+		}
+	}
 	return
 }
 
@@ -208,13 +373,30 @@ func checkSign(token, sign string) bool {
 	return C.check_sign(cToken, cSign) >= 0
 }
 
-func setPostFiles(tx *sql.Tx, post *db.Post, freq FilesRequest) (err error) {
-	for _, token := range freq.Tokens {
+// Files might be uploaded for another board so check board's upload
+// policy once again.
+func setPostFiles(tx *sql.Tx, post *db.Post, freq FilesRequest, policy config.UploadPolicy) (err error) {
+	switch {
+	case len(freq.Tokens) == 0:
+		return
+	case policy.TextOnly:
+		return errTextOnly
+	case len(freq.Tokens) > policy.MaxFiles:
+		return errTooManyFiles
+	}
+	for i, token := range freq.Tokens {
 		var img *common.Image
 		img, err = getImage(tx, token)
 		if err != nil {
 			return
 		}
+		if int64(img.Size) > policy.MaxSize {
+			return errFileTooLarge
+		}
+		if !policy.IsAllowed(img.FileType) {
+			return errFileNotAllowed
+		}
+		img.Spoiler = i < len(freq.Spoilers) && freq.Spoilers[i]
 		post.Files = append(post.Files, img)
 	}
 	return
@@ -118,7 +118,7 @@ func testCreateThread(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	thread, err := db.GetThread(6, 0)
+	thread, err := db.GetThread(6, 0, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -313,7 +313,7 @@ func TestPostCreation(t *testing.T) {
 
 	assertIP(t, 6, "::1")
 
-	thread, err := db.GetThread(1, 0)
+	thread, err := db.GetThread(1, 0, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -37,7 +37,7 @@ func (c *Client) synchronise(data []byte) error {
 	case !config.IsBoard(msg.Board):
 		return errInvalidBoard
 	case msg.Thread != 0:
-		valid, err := db.ValidateOP(msg.Thread, msg.Board)
+		valid, err := db.ThreadExists(msg.Thread, msg.Board)
 		switch {
 		case err != nil:
 			return err
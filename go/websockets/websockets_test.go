@@ -40,7 +40,7 @@ type mockWSServer struct {
 func init() {
 	db.ConnArgs = db.TestConnArgs
 	db.IsTest = true
-	if err := db.LoadDB(); err != nil {
+	if err := db.StartDB(); err != nil {
 		panic(err)
 	}
 }
@@ -9,6 +9,12 @@ import { Component, h, render } from "preact";
 import { showSendAlert } from "../alerts";
 import API from "../api";
 import { ModerationLevel } from "../auth";
+import { StaffNote } from "../auth/notes";
+import {
+  REPORT_REASON_LABELS,
+  ReportReason,
+  ReportResolution,
+} from "../auth/report";
 import _ from "../lang";
 import { BoardConfig, page } from "../state";
 import { readableTime, relativeTime } from "../templates";
@@ -26,6 +32,7 @@ interface AdminBoardConfig extends BoardConfig {
   modOnly?: boolean;
   accessMode?: AccessMode;
   includeAnon?: boolean;
+  holdPosts?: number;
 }
 
 type ModBoards = AdminBoardConfig[];
@@ -40,15 +47,50 @@ type Staff = StaffRecord[];
 
 interface BanRecord {
   ip: string;
+  account: string;
   board: string;
   id: number;
   by: string;
   expires: number;
   reason: string;
+  type: BanType;
+}
+
+const enum BanType {
+  regular,
+  shadow,
 }
 
 type BanRecords = BanRecord[];
 
+const enum AutomodCondition {
+  bodyMatch,
+  linkDomain,
+  fileHash,
+  newIP,
+  country,
+  postRate,
+}
+
+const enum AutomodAction {
+  reject,
+  hold,
+  shadowBan,
+  report,
+  requireCaptcha,
+}
+
+interface AutomodRule {
+  board: string;
+  condition: AutomodCondition;
+  pattern: string;
+  action: AutomodAction;
+  message: string;
+  dryRun: boolean;
+}
+
+type AutomodRules = AutomodRule[];
+
 const enum ModerationAction {
   banPost,
   unbanPost,
@@ -57,6 +99,47 @@ const enum ModerationAction {
   spoilerImage,
   deleteThread,
   updateBoard,
+  stickThread,
+  unstickThread,
+  deleteBoard,
+  restoreBoard,
+  sendNotification,
+  lockThread,
+  unlockThread,
+  moveThread,
+  splitThread,
+  dismissReports,
+  banRange,
+  unbanRange,
+  rejectAppeal,
+  shadowBanPost,
+  banAccount,
+  purgePosts,
+  automodMatch,
+  approvePost,
+  rejectPost,
+  unbanAccount,
+}
+
+interface ReportRecord {
+  reason: ReportReason;
+  text?: string;
+  created: number;
+}
+
+interface ReportedPost {
+  id: number;
+  op: number;
+  body: string;
+  reports: ReportRecord[];
+}
+
+interface HeldPost {
+  id: number;
+  op: number;
+  time: number;
+  body: string;
+  files?: any[];
 }
 
 interface ModLogRecord {
@@ -74,6 +157,7 @@ declare global {
     modBoards?: ModBoards;
     modStaff?: Staff;
     modBans?: BanRecords;
+    modRules?: AutomodRules;
     modLog?: ModLogRecords;
   }
 }
@@ -81,6 +165,7 @@ declare global {
 export const modBoards = window.modBoards;
 export const modStaff = window.modStaff;
 export const modBans = window.modBans;
+export const modRules = window.modRules;
 export const modLog = window.modLog;
 
 type ChangeFn = (changes: BoardStateChanges) => void;
@@ -100,6 +185,8 @@ class Settings extends Component<SettingsProps, {}> {
   }
   public render({ settings, disabled }: SettingsProps) {
     const { title, readOnly, modOnly, accessMode, includeAnon } = settings;
+    const { maxSize, maxFiles, fileTypes, textOnly } = settings;
+    const { holdPosts } = settings;
     return (
       <div class={cx("admin-settings", disabled && "admin-settings_disabled")}>
         <a class="admin-content-anchor" name="settings" />
@@ -164,6 +251,60 @@ class Settings extends Component<SettingsProps, {}> {
             onChange={this.handleModOnlyToggle}
           />
         </label>
+        <label class="admin-settings-label">
+          <span class="admin-settings-text">{_("Text only")}</span>
+          <input
+            class="admin-settings-checkbox"
+            type="checkbox"
+            checked={textOnly}
+            disabled={disabled}
+            onChange={this.handleTextOnlyToggle}
+          />
+        </label>
+        <label class="admin-settings-label">
+          <span class="admin-settings-text">{_("Max size, MB")}</span>
+          <input
+            class="admin-settings-input"
+            type="number"
+            min="0"
+            value={maxSize || ""}
+            disabled={disabled || textOnly}
+            onInput={this.handleMaxSizeChange}
+          />
+        </label>
+        <label class="admin-settings-label">
+          <span class="admin-settings-text">{_("Max files")}</span>
+          <input
+            class="admin-settings-input"
+            type="number"
+            min="0"
+            value={maxFiles || ""}
+            disabled={disabled || textOnly}
+            onInput={this.handleMaxFilesChange}
+          />
+        </label>
+        <label class="admin-settings-label">
+          <span class="admin-settings-text">{_("File types")}</span>
+          <input
+            class="admin-settings-input"
+            placeholder="jpg png webm"
+            value={(fileTypes || []).join(" ")}
+            disabled={disabled || textOnly}
+            onChange={this.handleFileTypesChange}
+          />
+        </label>
+        <label class="admin-settings-label">
+          <span class="admin-settings-text">{_("Hold posts")}</span>
+          <input
+            class="admin-settings-input"
+            type="number"
+            min="0"
+            title={_("holdPostsHint")}
+            value={holdPosts || ""}
+            disabled={disabled}
+            onInput={this.handleHoldPostsChange}
+          />
+        </label>
       </div>
     );
   }
@@ -184,6 +325,38 @@ class Settings extends Component<SettingsProps, {}> {
     const settings = { ...this.props.settings, modOnly };
     this.props.onChange({ settings });
   };
+  private handleTextOnlyToggle = (e: Event) => {
+    e.preventDefault();
+    const textOnly = !this.props.settings.textOnly;
+    const settings = { ...this.props.settings, textOnly };
+    this.props.onChange({ settings });
+  };
+  // Zero values mean server limits.
+  private handleMaxSizeChange = (e: Event) => {
+    const maxSize = +(e.target as HTMLInputElement).value || 0;
+    const settings = { ...this.props.settings, maxSize };
+    this.props.onChange({ settings });
+  };
+  private handleMaxFilesChange = (e: Event) => {
+    const maxFiles = +(e.target as HTMLInputElement).value || 0;
+    const settings = { ...this.props.settings, maxFiles };
+    this.props.onChange({ settings });
+  };
+  // Parsed on change so separators can be typed.
+  private handleFileTypesChange = (e: Event) => {
+    const value = (e.target as HTMLInputElement).value;
+    const fileTypes = value
+      .toLowerCase()
+      .split(/[\s,]+/)
+      .filter((t) => t);
+    const settings = { ...this.props.settings, fileTypes };
+    this.props.onChange({ settings });
+  };
+  private handleHoldPostsChange = (e: Event) => {
+    const holdPosts = +(e.target as HTMLInputElement).value || 0;
+    const settings = { ...this.props.settings, holdPosts };
+    this.props.onChange({ settings });
+  };
   private handleAccessModeChange = (e: Event) => {
     const accessMode = +(e.target as HTMLInputElement).value;
     const settings = { ...this.props.settings, accessMode };
@@ -299,19 +472,32 @@ class Members extends Component<MembersProps, {}> {
 }
 
 interface BansProps {
+  board: string;
   bans: BanRecords;
   disabled: boolean;
   onChange: ChangeFn;
+  onBan: (rec: BanRecord) => void;
 }
 
-class Bans extends Component<BansProps, {}> {
-  public shouldComponentUpdate(nextProps: BansProps) {
-    return (
-      this.props.bans !== nextProps.bans ||
-      this.props.disabled !== nextProps.disabled
-    );
-  }
-  public render({ bans, disabled }: BansProps) {
+interface BansState {
+  range: string;
+  account: string;
+  reason: string;
+  days: number;
+  shadow: boolean;
+  banning: boolean;
+}
+
+class Bans extends Component<BansProps, BansState> {
+  public state: BansState = {
+    range: "",
+    account: "",
+    reason: "",
+    days: 365,
+    shadow: false,
+    banning: false,
+  };
+  public render({ bans, disabled }: BansProps, s: BansState) {
     return (
       <div class={cx("admin-bans", disabled && "admin-bans_disabled")}>
         <a class="admin-content-anchor" name="bans" />
@@ -330,20 +516,24 @@ class Bans extends Component<BansProps, {}> {
             </tr>
           </thead>
           <tbody>
-            {bans.map(({ id, reason, by, expires }) => (
+            {bans.map((rec) => (
               <tr
                 class="admin-table-item admin-ban-item"
-                onClick={() => this.handleRemove(id)}
+                onClick={() => this.handleRemove(rec)}
               >
-                <td class="admin-ban-id">
-                  <a class="post-link" href={`/all/${id}#${id}`}>
-                    &gt;&gt;{id}
-                  </a>
+                <td class="admin-ban-id">{this.renderTarget(rec)}</td>
+                <td class="admin-ban-reason">
+                  {rec.type === BanType.shadow && (
+                    <i
+                      class="admin-ban-shadow fa fa-user-secret"
+                      title={_("Shadow ban")}
+                    />
+                  )}
+                  {rec.reason}
                 </td>
-                <td class="admin-ban-reason">{reason}</td>
-                <td class="admin-ban-by">{by}</td>
-                <td class="admin-ban-time" title={readableTime(expires)}>
-                  {relativeTime(expires)}
+                <td class="admin-ban-by">{rec.by}</td>
+                <td class="admin-ban-time" title={readableTime(rec.expires)}>
+                  {relativeTime(rec.expires)}
                 </td>
               </tr>
             ))}
@@ -356,14 +546,832 @@ class Bans extends Component<BansProps, {}> {
             )}
           </tbody>
         </table>
+        <form class="admin-ban-form" onSubmit={this.handleBan}>
+          <input
+            class="admin-settings-input admin-ban-range-input"
+            placeholder="192.0.2.0/24"
+            title={_("banRangeHint")}
+            value={s.range}
+            disabled={disabled || s.banning}
+            onInput={this.handleRangeChange}
+          />
+          <input
+            class="admin-settings-input admin-ban-account-input"
+            placeholder={_("Account")}
+            title={_("banAccountHint")}
+            value={s.account}
+            disabled={disabled || s.banning}
+            onInput={this.handleAccountChange}
+          />
+          <input
+            class="admin-settings-input admin-ban-reason-input"
+            placeholder={_("Reason")}
+            value={s.reason}
+            disabled={disabled || s.banning}
+            onInput={this.handleReasonChange}
+          />
+          <input
+            class="admin-settings-input admin-ban-days-input"
+            type="number"
+            min="1"
+            title={_("Days")}
+            value={s.days}
+            disabled={disabled || s.banning}
+            onInput={this.handleDaysChange}
+          />
+          <label class="admin-ban-shadow-label" title={_("shadowBanHint")}>
+            <input
+              class="admin-settings-checkbox admin-ban-shadow-input"
+              type="checkbox"
+              checked={s.shadow}
+              disabled={disabled || s.banning}
+              onChange={this.handleShadowToggle}
+            />
+            {_("Shadow ban")}
+          </label>
+          <button
+            class="button admin-button admin-ban-button"
+            disabled={
+              disabled || s.banning || !s.range === !s.account || !s.reason
+            }
+          >
+            <i class="admin-icon fa fa-gavel" />
+            {_("Ban")}
+          </button>
+        </form>
       </div>
     );
   }
-  private handleRemove(id: number) {
+  private renderTarget({ id, ip, account }: BanRecord) {
+    if (id) {
+      return (
+        <a class="post-link" href={`/all/${id}#${id}`}>
+          &gt;&gt;{id}
+        </a>
+      );
+    }
+    if (account) {
+      return (
+        <span class="admin-ban-account" title={account}>
+          <i class="admin-ban-account-icon fa fa-user" />
+          {account}
+        </span>
+      );
+    }
+    return (
+      <span class="admin-ban-range" title={ip}>
+        {ip}
+      </span>
+    );
+  }
+  private handleRemove({ id, ip, account }: BanRecord) {
     if (this.props.disabled) return;
-    const bans = this.props.bans.filter((b) => b.id !== id);
+    const bans = this.props.bans.filter(
+      (b) => b.id !== id || b.ip !== ip || b.account !== account
+    );
     this.props.onChange({ bans });
   }
+  private handleRangeChange = (e: Event) => {
+    const range = (e.target as HTMLInputElement).value;
+    this.setState({ range });
+  };
+  private handleAccountChange = (e: Event) => {
+    const account = (e.target as HTMLInputElement).value.trim();
+    this.setState({ account });
+  };
+  private handleReasonChange = (e: Event) => {
+    const reason = (e.target as HTMLInputElement).value;
+    this.setState({ reason });
+  };
+  private handleDaysChange = (e: Event) => {
+    const days = +(e.target as HTMLInputElement).value;
+    this.setState({ days });
+  };
+  private handleShadowToggle = (e: Event) => {
+    const shadow = (e.target as HTMLInputElement).checked;
+    this.setState({ shadow });
+  };
+  private handleBan = (e: Event) => {
+    e.preventDefault();
+    const { range, account, reason, days, shadow } = this.state;
+    if (days < 1) return;
+    this.setState({ banning: true });
+    const board = this.props.board;
+    const duration = days * 24 * 60;
+    const req = account
+      ? API.bans.banAccount({ board, account, reason, duration, shadow })
+      : API.bans.banRange({ board, range, reason, duration, shadow });
+    req
+      .then((rec: BanRecord) => {
+        this.props.onBan(rec);
+        this.setState({ range: "", account: "", reason: "" });
+      }, showSendAlert)
+      .then(() => {
+        this.setState({ banning: false });
+      });
+  };
+}
+
+interface RulesProps {
+  board: string;
+  rules: AutomodRules;
+  disabled: boolean;
+  onChange: ChangeFn;
+}
+
+// Indexed by AutomodCondition.
+const CONDITION_LABELS = [
+  "Body regex",
+  "Link domains",
+  "File SHA1",
+  "New IP",
+  "Country",
+  "Posts per minute",
+];
+
+// Indexed by AutomodAction.
+const ACTION_LABELS = [
+  "Reject",
+  "Hold",
+  "Shadow ban",
+  "Report",
+  "Require captcha",
+];
+
+class Rules extends Component<RulesProps, {}> {
+  public shouldComponentUpdate(nextProps: RulesProps) {
+    return (
+      this.props.rules !== nextProps.rules ||
+      this.props.disabled !== nextProps.disabled
+    );
+  }
+  public render({ rules, disabled }: RulesProps) {
+    return (
+      <div class={cx("admin-rules", disabled && "admin-rules_disabled")}>
+        <a class="admin-content-anchor" name="automod" />
+        <h3 class="admin-content-header">
+          <a class="admin-header-link" href="#automod">
+            {_("Automod")}
+          </a>
+        </h3>
+        <table class="admin-table admin-rule-list">
+          <thead>
+            <tr class="admin-table-header admin-rule-item-header">
+              <th class="admin-rule-condition-header">{_("Condition")}</th>
+              <th class="admin-rule-pattern-header">{_("Pattern")}</th>
+              <th class="admin-rule-action-header">{_("Action")}</th>
+              <th class="admin-rule-message-header">{_("Message")}</th>
+              <th class="admin-rule-dry-header" title={_("dryRunHint")}>
+                <i class="fa fa-eye" />
+              </th>
+              <th class="admin-rule-actions-header" />
+            </tr>
+          </thead>
+          <tbody>
+            {rules.map((rule, i) => (
+              <tr class="admin-table-item admin-rule-item">
+                <td class="admin-rule-condition">
+                  <select
+                    class="admin-settings-select"
+                    value={rule.condition.toString()}
+                    disabled={disabled}
+                    onChange={(e) => this.handleConditionChange(i, e)}
+                  >
+                    {CONDITION_LABELS.map((label, c) => (
+                      <option value={c.toString()}>{_(label)}</option>
+                    ))}
+                  </select>
+                </td>
+                <td class="admin-rule-pattern">
+                  <input
+                    class="admin-settings-input"
+                    placeholder={this.getPatternHint(rule.condition)}
+                    value={rule.pattern}
+                    disabled={
+                      disabled || rule.condition === AutomodCondition.newIP
+                    }
+                    onInput={(e) => this.handlePatternChange(i, e)}
+                  />
+                </td>
+                <td class="admin-rule-action">
+                  <select
+                    class="admin-settings-select"
+                    value={rule.action.toString()}
+                    disabled={disabled}
+                    onChange={(e) => this.handleActionChange(i, e)}
+                  >
+                    {ACTION_LABELS.map((label, a) => (
+                      <option value={a.toString()}>{_(label)}</option>
+                    ))}
+                  </select>
+                </td>
+                <td class="admin-rule-message">
+                  <input
+                    class="admin-settings-input"
+                    value={rule.message}
+                    disabled={disabled}
+                    onInput={(e) => this.handleMessageChange(i, e)}
+                  />
+                </td>
+                <td class="admin-rule-dry">
+                  <input
+                    class="admin-settings-checkbox"
+                    type="checkbox"
+                    title={_("dryRunHint")}
+                    checked={rule.dryRun}
+                    disabled={disabled}
+                    onChange={(e) => this.handleDryRunToggle(i, e)}
+                  />
+                </td>
+                <td class="admin-rule-actions">
+                  <i
+                    class="control fa fa-remove"
+                    title={_("deleteRule")}
+                    onClick={() => this.handleRemove(i)}
+                  />
+                </td>
+              </tr>
+            ))}
+            {!rules.length && (
+              <tr class="admin-table-empty">
+                <td class="admin-rules-empty" colSpan={6}>
+                  {_("No rules")}
+                </td>
+              </tr>
+            )}
+          </tbody>
+        </table>
+        <button
+          class="button admin-button admin-rule-add-button"
+          disabled={disabled}
+          onClick={this.handleAdd}
+        >
+          <i class="admin-icon fa fa-plus" />
+          {_("Add rule")}
+        </button>
+      </div>
+    );
+  }
+  private getPatternHint(c: AutomodCondition) {
+    switch (c) {
+      case AutomodCondition.bodyMatch:
+        return "(?i)casino";
+      case AutomodCondition.linkDomain:
+        return "example.com example.org";
+      case AutomodCondition.fileHash:
+        return "SHA1";
+      case AutomodCondition.country:
+        return "XX YY";
+      case AutomodCondition.postRate:
+        return "5";
+      default:
+        return "";
+    }
+  }
+  private update(i: number, changes: Partial<AutomodRule>) {
+    const rules = this.props.rules.slice();
+    rules[i] = { ...rules[i], ...changes };
+    this.props.onChange({ rules });
+  }
+  private handleConditionChange(i: number, e: Event) {
+    const condition = +(e.target as HTMLInputElement).value;
+    this.update(i, { condition, pattern: "" });
+  }
+  private handlePatternChange(i: number, e: Event) {
+    const pattern = (e.target as HTMLInputElement).value;
+    this.update(i, { pattern });
+  }
+  private handleActionChange(i: number, e: Event) {
+    const action = +(e.target as HTMLInputElement).value;
+    this.update(i, { action });
+  }
+  private handleMessageChange(i: number, e: Event) {
+    const message = (e.target as HTMLInputElement).value;
+    this.update(i, { message });
+  }
+  private handleDryRunToggle(i: number, e: Event) {
+    const dryRun = (e.target as HTMLInputElement).checked;
+    this.update(i, { dryRun });
+  }
+  private handleRemove(i: number) {
+    if (this.props.disabled) return;
+    const rules = this.props.rules.filter((_r, j) => j !== i);
+    this.props.onChange({ rules });
+  }
+  private handleAdd = () => {
+    const rule: AutomodRule = {
+      board: this.props.board,
+      condition: AutomodCondition.bodyMatch,
+      pattern: "",
+      action: AutomodAction.report,
+      message: "",
+      dryRun: true,
+    };
+    const rules = this.props.rules.concat(rule);
+    this.props.onChange({ rules });
+  };
+}
+
+interface ReportsProps {
+  board: string;
+}
+
+interface ReportsState {
+  reports: ReportedPost[];
+  loading: boolean;
+}
+
+class Reports extends Component<ReportsProps, ReportsState> {
+  public state: ReportsState = {
+    reports: [],
+    loading: true,
+  };
+  public componentDidMount() {
+    this.load(this.props.board);
+  }
+  public componentWillReceiveProps({ board }: ReportsProps) {
+    if (board !== this.props.board) {
+      this.load(board);
+    }
+  }
+  public render({}, { reports, loading }: ReportsState) {
+    return (
+      <div class="admin-reports">
+        <a class="admin-content-anchor" name="reports" />
+        <h3 class="admin-content-header">
+          <a class="admin-header-link" href="#reports">
+            {_("Reports")}
+          </a>
+        </h3>
+        <table class="admin-table admin-report-list">
+          <thead>
+            <tr class="admin-table-header admin-report-item-header">
+              <th class="admin-report-id-header">#</th>
+              <th class="admin-report-reasons-header">{_("Reason")}</th>
+              <th class="admin-report-time-header">{_("Date")}</th>
+              <th class="admin-report-actions-header" />
+            </tr>
+          </thead>
+          <tbody>
+            {reports.map(({ id, reports: rs }) => (
+              <tr class="admin-table-item admin-report-item">
+                <td class="admin-report-id">
+                  <a class="post-link" href={`/all/${id}#${id}`}>
+                    &gt;&gt;{id}
+                  </a>
+                </td>
+                <td class="admin-report-reasons">
+                  {rs.map(({ reason, text }) => (
+                    <div class="admin-report-reason" title={text}>
+                      {_(REPORT_REASON_LABELS[reason])}
+                      {text && `: ${text}`}
+                    </div>
+                  ))}
+                </td>
+                <td
+                  class="admin-report-time"
+                  title={readableTime(rs[rs.length - 1].created)}
+                >
+                  {relativeTime(rs[rs.length - 1].created)}
+                </td>
+                <td class="admin-report-actions">
+                  <i
+                    class="control fa fa-check"
+                    title={_("dismissReports")}
+                    onClick={() =>
+                      this.handleResolve(id, ReportResolution.dismiss)
+                    }
+                  />
+                  <i
+                    class="control fa fa-remove"
+                    title={_("deletePost")}
+                    onClick={() =>
+                      this.handleResolve(id, ReportResolution.delete)
+                    }
+                  />
+                  <i
+                    class="control fa fa-gavel"
+                    title={_("ban")}
+                    onClick={() =>
+                      this.handleResolve(id, ReportResolution.ban)
+                    }
+                  />
+                </td>
+              </tr>
+            ))}
+            {!reports.length && (
+              <tr class="admin-table-empty">
+                <td class="admin-reports-empty" colSpan={4}>
+                  {loading ? "…" : _("No reports")}
+                </td>
+              </tr>
+            )}
+          </tbody>
+        </table>
+      </div>
+    );
+  }
+  private load(board: string) {
+    this.setState({ reports: [], loading: true });
+    API.reports.get(board).then(
+      (reports: ReportedPost[]) => {
+        this.setState({ reports, loading: false });
+      },
+      (err) => {
+        showSendAlert(err);
+        this.setState({ loading: false });
+      }
+    );
+  }
+  private handleResolve(id: number, resolution: ReportResolution) {
+    if (resolution === ReportResolution.delete && !confirm(_("delConfirm"))) {
+      return;
+    }
+    if (resolution === ReportResolution.ban && !confirm(_("banConfirm"))) {
+      return;
+    }
+    const YEAR = 365 * 24 * 60;
+    API.reports
+      .resolve({
+        id,
+        resolution,
+        // Same defaults as when banning from the post.
+        duration: YEAR,
+        reason: "default",
+      })
+      .then(() => {
+        const reports = this.state.reports.filter((p) => p.id !== id);
+        this.setState({ reports });
+      }, showSendAlert);
+  }
+}
+
+interface HeldProps {
+  board: string;
+}
+
+interface HeldState {
+  posts: HeldPost[];
+  loading: boolean;
+}
+
+class Held extends Component<HeldProps, HeldState> {
+  public state: HeldState = {
+    posts: [],
+    loading: true,
+  };
+  public componentDidMount() {
+    this.load(this.props.board);
+  }
+  public componentWillReceiveProps({ board }: HeldProps) {
+    if (board !== this.props.board) {
+      this.load(board);
+    }
+  }
+  public render({}, { posts, loading }: HeldState) {
+    return (
+      <div class="admin-held">
+        <a class="admin-content-anchor" name="held" />
+        <h3 class="admin-content-header">
+          <a class="admin-header-link" href="#held">
+            {_("Held posts")}
+          </a>
+        </h3>
+        <table class="admin-table admin-held-list">
+          <thead>
+            <tr class="admin-table-header admin-held-item-header">
+              <th class="admin-held-id-header">#</th>
+              <th class="admin-held-body-header">{_("Post")}</th>
+              <th class="admin-held-time-header">{_("Date")}</th>
+              <th class="admin-held-actions-header" />
+            </tr>
+          </thead>
+          <tbody>
+            {posts.map(({ id, op, time, body, files }) => (
+              <tr class="admin-table-item admin-held-item">
+                <td class="admin-held-id">
+                  {id === op ? (
+                    <span class="admin-held-thread" title={_("heldThread")}>
+                      {id}
+                    </span>
+                  ) : (
+                    <a class="post-link" href={`/all/${op}#${op}`}>
+                      &gt;&gt;{op}
+                    </a>
+                  )}
+                </td>
+                <td class="admin-held-body">
+                  {files && <i class="admin-held-files fa fa-paperclip" />}
+                  {body}
+                </td>
+                <td class="admin-held-time" title={readableTime(time)}>
+                  {relativeTime(time)}
+                </td>
+                <td class="admin-held-actions">
+                  <i
+                    class="control fa fa-check"
+                    title={_("approvePost")}
+                    onClick={() => this.handleResolve(id, true)}
+                  />
+                  <i
+                    class="control fa fa-remove"
+                    title={_("rejectPost")}
+                    onClick={() => this.handleResolve(id, false)}
+                  />
+                </td>
+              </tr>
+            ))}
+            {!posts.length && (
+              <tr class="admin-table-empty">
+                <td class="admin-held-empty" colSpan={4}>
+                  {loading ? "…" : _("No held posts")}
+                </td>
+              </tr>
+            )}
+          </tbody>
+        </table>
+      </div>
+    );
+  }
+  private load(board: string) {
+    this.setState({ posts: [], loading: true });
+    API.held.get(board).then(
+      (posts: HeldPost[]) => {
+        this.setState({ posts, loading: false });
+      },
+      (err) => {
+        showSendAlert(err);
+        this.setState({ loading: false });
+      }
+    );
+  }
+  private handleResolve(id: number, approve: boolean) {
+    if (!approve && !confirm(_("rejectPostConfirm"))) return;
+    API.held.resolve({ id, approve }).then(() => {
+      const posts = this.state.posts.filter((p) => p.id !== id);
+      this.setState({ posts });
+    }, showSendAlert);
+  }
+}
+
+interface AppealRecord {
+  id: number;
+  board: string;
+  ip: string;
+  account?: string;
+  post: number;
+  reason: string;
+  text: string;
+  created: number;
+}
+
+interface AppealsProps {
+  board: string;
+}
+
+interface AppealsState {
+  appeals: AppealRecord[];
+  loading: boolean;
+}
+
+class Appeals extends Component<AppealsProps, AppealsState> {
+  public state: AppealsState = {
+    appeals: [],
+    loading: true,
+  };
+  public componentDidMount() {
+    this.load(this.props.board);
+  }
+  public componentWillReceiveProps({ board }: AppealsProps) {
+    if (board !== this.props.board) {
+      this.load(board);
+    }
+  }
+  public render({}, { appeals, loading }: AppealsState) {
+    return (
+      <div class="admin-appeals">
+        <a class="admin-content-anchor" name="appeals" />
+        <h3 class="admin-content-header">
+          <a class="admin-header-link" href="#appeals">
+            {_("Appeals")}
+          </a>
+        </h3>
+        <table class="admin-table admin-appeal-list">
+          <thead>
+            <tr class="admin-table-header admin-appeal-item-header">
+              <th class="admin-appeal-id-header">#</th>
+              <th class="admin-appeal-text-header">{_("Appeal")}</th>
+              <th class="admin-appeal-time-header">{_("Date")}</th>
+              <th class="admin-appeal-actions-header" />
+            </tr>
+          </thead>
+          <tbody>
+            {appeals.map((a) => (
+              <tr class="admin-table-item admin-appeal-item">
+                <td class="admin-appeal-id">{this.renderTarget(a)}</td>
+                <td class="admin-appeal-text">
+                  <div class="admin-appeal-reason">{a.reason}</div>
+                  <div class="admin-appeal-body">{a.text}</div>
+                </td>
+                <td class="admin-appeal-time" title={readableTime(a.created)}>
+                  {relativeTime(a.created)}
+                </td>
+                <td class="admin-appeal-actions">
+                  <i
+                    class="control fa fa-check"
+                    title={_("acceptAppeal")}
+                    onClick={() => this.handleResolve(a.id, true)}
+                  />
+                  <i
+                    class="control fa fa-remove"
+                    title={_("rejectAppeal")}
+                    onClick={() => this.handleResolve(a.id, false)}
+                  />
+                </td>
+              </tr>
+            ))}
+            {!appeals.length && (
+              <tr class="admin-table-empty">
+                <td class="admin-appeals-empty" colSpan={4}>
+                  {loading ? "…" : _("No appeals")}
+                </td>
+              </tr>
+            )}
+          </tbody>
+        </table>
+      </div>
+    );
+  }
+  private renderTarget({ ip, account, post }: AppealRecord) {
+    if (post) {
+      return (
+        <a class="post-link" href={`/all/${post}#${post}`}>
+          &gt;&gt;{post}
+        </a>
+      );
+    }
+    if (account && !ip) {
+      return (
+        <span class="admin-ban-account" title={account}>
+          <i class="admin-ban-account-icon fa fa-user" />
+          {account}
+        </span>
+      );
+    }
+    return (
+      <span class="admin-ban-range" title={ip}>
+        {ip}
+      </span>
+    );
+  }
+  private load(board: string) {
+    this.setState({ appeals: [], loading: true });
+    API.appeals.get(board).then(
+      (appeals: AppealRecord[]) => {
+        this.setState({ appeals, loading: false });
+      },
+      (err) => {
+        showSendAlert(err);
+        this.setState({ loading: false });
+      }
+    );
+  }
+  private handleResolve(id: number, accept: boolean) {
+    let response = "";
+    if (!accept) {
+      response = prompt(_("appealResponse"));
+      // Cancelled.
+      if (response == null) return;
+    }
+    API.appeals.resolve({ id, accept, response }).then(() => {
+      const appeals = this.state.appeals.filter((a) => a.id !== id);
+      this.setState({ appeals });
+    }, showSendAlert);
+  }
+}
+
+interface NotesProps {
+  board: string;
+}
+
+interface NotesState {
+  notes: StaffNote[];
+  query: string;
+  loading: boolean;
+}
+
+class Notes extends Component<NotesProps, NotesState> {
+  public state: NotesState = {
+    notes: [],
+    query: "",
+    loading: true,
+  };
+  public componentDidMount() {
+    this.load(this.props.board, "");
+  }
+  public componentWillReceiveProps({ board }: NotesProps) {
+    if (board !== this.props.board) {
+      this.setState({ query: "" });
+      this.load(board, "");
+    }
+  }
+  public render({}, { notes, query, loading }: NotesState) {
+    return (
+      <div class="admin-notes">
+        <a class="admin-content-anchor" name="notes" />
+        <h3 class="admin-content-header">
+          <a class="admin-header-link" href="#notes">
+            {_("Notes")}
+          </a>
+        </h3>
+        <form class="admin-notes-search" onSubmit={this.handleSearch}>
+          <input
+            class="admin-settings-input admin-notes-search-input"
+            placeholder={_("Search")}
+            title={_("searchNotesHint")}
+            value={query}
+            onInput={this.handleQueryChange}
+          />
+        </form>
+        <table class="admin-table admin-note-list">
+          <thead>
+            <tr class="admin-table-header admin-note-item-header">
+              <th class="admin-note-id-header">#</th>
+              <th class="admin-note-text-header">{_("Note")}</th>
+              <th class="admin-note-by-header">{_("By")}</th>
+              <th class="admin-note-time-header">{_("Date")}</th>
+              <th class="admin-note-actions-header" />
+            </tr>
+          </thead>
+          <tbody>
+            {notes.map(({ id, post, byIP, text, by, created }) => (
+              <tr class="admin-table-item admin-note-item">
+                <td class="admin-note-id">
+                  {byIP && (
+                    <i
+                      class="admin-note-ip fa fa-globe"
+                      title={_("noteByIP")}
+                    />
+                  )}
+                  {post ? (
+                    <a class="post-link" href={`/all/${post}#${post}`}>
+                      &gt;&gt;{post}
+                    </a>
+                  ) : null}
+                </td>
+                <td class="admin-note-text">{text}</td>
+                <td class="admin-note-by">{by}</td>
+                <td class="admin-note-time" title={readableTime(created)}>
+                  {relativeTime(created)}
+                </td>
+                <td class="admin-note-actions">
+                  <i
+                    class="control fa fa-remove"
+                    title={_("deleteNote")}
+                    onClick={() => this.handleDelete(id)}
+                  />
+                </td>
+              </tr>
+            ))}
+            {!notes.length && (
+              <tr class="admin-table-empty">
+                <td class="admin-notes-empty" colSpan={5}>
+                  {loading ? "…" : _("No notes")}
+                </td>
+              </tr>
+            )}
+          </tbody>
+        </table>
+      </div>
+    );
+  }
+  private load(board: string, query: string) {
+    this.setState({ notes: [], loading: true });
+    API.notes.search(board, query).then(
+      (notes: StaffNote[]) => {
+        this.setState({ notes, loading: false });
+      },
+      (err) => {
+        showSendAlert(err);
+        this.setState({ loading: false });
+      }
+    );
+  }
+  private handleQueryChange = (e: Event) => {
+    const query = (e.target as HTMLInputElement).value;
+    this.setState({ query });
+  };
+  private handleSearch = (e: Event) => {
+    e.preventDefault();
+    this.load(this.props.board, this.state.query.trim());
+  };
+  private handleDelete(id: number) {
+    if (!confirm(_("delNoteConfirm"))) return;
+    API.notes.delete({ id }).then(() => {
+      const notes = this.state.notes.filter((n) => n.id !== id);
+      this.setState({ notes });
+    }, showSendAlert);
+  }
 }
 
 interface LogProps {
@@ -419,19 +1427,35 @@ class Log extends Component<LogProps, {}> {
   private renderLink(id: number, a: ModerationAction) {
     switch (a) {
       case ModerationAction.updateBoard:
-        return (
-          <a class="post-link" href={`/${this.props.board}/`}>
-            /{this.props.board}/
-          </a>
-        );
+      case ModerationAction.deleteBoard:
+      case ModerationAction.restoreBoard:
+      case ModerationAction.sendNotification:
+      case ModerationAction.banRange:
+      case ModerationAction.unbanRange:
+      case ModerationAction.banAccount:
+      case ModerationAction.unbanAccount:
+        return this.renderBoardLink();
+      case ModerationAction.automodMatch:
+        // Rejected posts are never created
+        return id ? this.renderPostLink(id) : this.renderBoardLink();
       default:
-        return (
-          <a class="post-link" href={`/all/${id}#${id}`}>
-            &gt;&gt;{id}
-          </a>
-        );
+        return this.renderPostLink(id);
     }
   }
+  private renderBoardLink() {
+    return (
+      <a class="post-link" href={`/${this.props.board}/`}>
+        /{this.props.board}/
+      </a>
+    );
+  }
+  private renderPostLink(id: number) {
+    return (
+      <a class="post-link" href={`/all/${id}#${id}`}>
+        &gt;&gt;{id}
+      </a>
+    );
+  }
   private renderType(a: ModerationAction) {
     switch (a) {
       case ModerationAction.banPost:
@@ -446,13 +1470,68 @@ class Log extends Component<LogProps, {}> {
       case ModerationAction.deletePost:
         return <i class="fa fa-trash" title={_("deletePost")} />;
       case ModerationAction.deleteImage:
-        return null;
+        return <i class="fa fa-file-o" title={_("deleteImage")} />;
       case ModerationAction.spoilerImage:
-        return null;
+        return <i class="fa fa-eye-slash" title={_("spoilerImage")} />;
       case ModerationAction.deleteThread:
         return <i class="fa fa-2x fa-trash-o" title={_("deleteThread")} />;
       case ModerationAction.updateBoard:
         return <i class="fa fa-refresh" title={_("updateBoard")} />;
+      case ModerationAction.stickThread:
+        return <i class="fa fa-thumb-tack" title={_("stickThread")} />;
+      case ModerationAction.unstickThread:
+        return (
+          <span class="fa-stack" title={_("unstickThread")}>
+            <i class="fa fa-thumb-tack fa-stack-1x" />
+            <i class="fa fa-ban fa-stack-2x admin-log-ban-icon" />
+          </span>
+        );
+      case ModerationAction.deleteBoard:
+        return <i class="fa fa-2x fa-times-circle" title={_("deleteBoard")} />;
+      case ModerationAction.restoreBoard:
+        return <i class="fa fa-undo" title={_("restoreBoard")} />;
+      case ModerationAction.sendNotification:
+        return <i class="fa fa-bullhorn" title={_("sendNotification")} />;
+      case ModerationAction.lockThread:
+        return <i class="fa fa-lock" title={_("lockThread")} />;
+      case ModerationAction.unlockThread:
+        return <i class="fa fa-unlock" title={_("unlockThread")} />;
+      case ModerationAction.moveThread:
+        return <i class="fa fa-exchange" title={_("moveThread")} />;
+      case ModerationAction.splitThread:
+        return <i class="fa fa-scissors" title={_("splitThread")} />;
+      case ModerationAction.dismissReports:
+        return <i class="fa fa-flag-o" title={_("dismissReports")} />;
+      case ModerationAction.banRange:
+        return <i class="fa fa-sitemap" title={_("banRange")} />;
+      case ModerationAction.unbanRange:
+        return (
+          <span class="fa-stack" title={_("unbanRange")}>
+            <i class="fa fa-sitemap fa-stack-1x" />
+            <i class="fa fa-ban fa-stack-2x admin-log-ban-icon" />
+          </span>
+        );
+      case ModerationAction.rejectAppeal:
+        return <i class="fa fa-envelope-o" title={_("rejectAppeal")} />;
+      case ModerationAction.shadowBanPost:
+        return <i class="fa fa-user-secret" title={_("shadowBanPost")} />;
+      case ModerationAction.banAccount:
+        return <i class="fa fa-user-times" title={_("banAccount")} />;
+      case ModerationAction.purgePosts:
+        return <i class="fa fa-bomb" title={_("purgePosts")} />;
+      case ModerationAction.automodMatch:
+        return <i class="fa fa-android" title={_("automodMatch")} />;
+      case ModerationAction.approvePost:
+        return <i class="fa fa-check" title={_("approvePost")} />;
+      case ModerationAction.rejectPost:
+        return <i class="fa fa-times" title={_("rejectPost")} />;
+      case ModerationAction.unbanAccount:
+        return (
+          <span class="fa-stack" title={_("unbanAccount")}>
+            <i class="fa fa-user-times fa-stack-1x" />
+            <i class="fa fa-ban fa-stack-2x admin-log-ban-icon" />
+          </span>
+        );
     }
   }
 }
@@ -461,12 +1540,14 @@ interface BoardState {
   settings: AdminBoardConfig;
   staff: Staff;
   bans: BanRecords;
+  rules: AutomodRules;
 }
 
 interface BoardStateChanges {
   settings?: AdminBoardConfig;
   staff?: Staff;
   bans?: BanRecords;
+  rules?: AutomodRules;
 }
 
 interface AdminState {
@@ -494,7 +1575,7 @@ class Admin extends Component<{}, AdminState> {
     };
   }
   public render({}, { id, boardState, needSaving, saving }: AdminState) {
-    const { settings, staff, bans } = boardState;
+    const { settings, staff, bans, rules } = boardState;
     return (
       <section class="admin">
         <header class="admin-header">
@@ -522,6 +1603,21 @@ class Admin extends Component<{}, AdminState> {
             <li class="admin-section-tab">
               <a href="#bans">{_("Bans")}</a>
             </li>
+            <li class="admin-section-tab">
+              <a href="#automod">{_("Automod")}</a>
+            </li>
+            <li class="admin-section-tab">
+              <a href="#reports">{_("Reports")}</a>
+            </li>
+            <li class="admin-section-tab">
+              <a href="#held">{_("Held posts")}</a>
+            </li>
+            <li class="admin-section-tab">
+              <a href="#appeals">{_("Appeals")}</a>
+            </li>
+            <li class="admin-section-tab">
+              <a href="#notes">{_("Notes")}</a>
+            </li>
             <li class="admin-section-tab">
               <a href="#log">{_("Mod log")}</a>
             </li>
@@ -541,7 +1637,28 @@ class Admin extends Component<{}, AdminState> {
               onChange={this.handleChange}
             />
             <hr class="admin-separator" />
-            <Bans bans={bans} disabled={saving} onChange={this.handleChange} />
+            <Bans
+              board={id}
+              bans={bans}
+              disabled={saving}
+              onChange={this.handleChange}
+              onBan={this.handleBan}
+            />
+            <hr class="admin-separator" />
+            <Rules
+              board={id}
+              rules={rules}
+              disabled={saving}
+              onChange={this.handleChange}
+            />
+            <hr class="admin-separator" />
+            <Reports board={id} />
+            <hr class="admin-separator" />
+            <Held board={id} />
+            <hr class="admin-separator" />
+            <Appeals board={id} />
+            <hr class="admin-separator" />
+            <Notes board={id} />
             <hr class="admin-separator" />
             <Log board={id} />
           </section>
@@ -583,6 +1700,7 @@ class Admin extends Component<{}, AdminState> {
       settings: { ...board },
       staff: modStaff.filter((b) => b.board === id),
       bans: modBans.filter((b) => b.board === id),
+      rules: modRules.filter((r) => r.board === id),
     };
   }
   private fixateBoardState() {
@@ -599,6 +1717,10 @@ class Admin extends Component<{}, AdminState> {
     const bans = modBans.filter((b) => b.board !== id);
     bans.push(...s.bans);
     replace(modBans, bans);
+
+    const rules = modRules.filter((r) => r.board !== id);
+    rules.push(...s.rules);
+    replace(modRules, rules);
   }
   private handleBoardChange = (e: Event) => {
     const id = (e.target as HTMLInputElement).value;
@@ -610,6 +1732,16 @@ class Admin extends Component<{}, AdminState> {
     const boardState = Object.assign({}, this.state.boardState, changes);
     this.setState({ boardState, needSaving: true });
   };
+  // Range and account bans are written right away so keep both saved
+  // and edited states in sync with the server.
+  private handleBan = (rec: BanRecord) => {
+    const isOther = (b: BanRecord) =>
+      b.board !== rec.board || b.ip !== rec.ip || b.account !== rec.account;
+    replace(modBans, modBans.filter(isOther).concat(rec));
+    const bans = this.state.boardState.bans.filter(isOther).concat(rec);
+    const boardState = { ...this.state.boardState, bans };
+    this.setState({ boardState });
+  };
   private handleSave = () => {
     const id = this.state.id;
     const oldState = this.getBoardState(id);
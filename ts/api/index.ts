@@ -87,17 +87,54 @@ export const API = {
     create: emit.POST.Form("post"),
     createToken: emit.POST.JSON("post/token"),
     delete: emit.POST.JSON("delete-post"),
+    spoilerFile: emit.POST.JSON("spoiler-file"),
+    deleteFile: emit.POST.JSON("delete-file"),
+    report: emit.POST.JSON("report"),
     get: (id: number) => emit.GET.JSON(`post/${id}`)(),
   },
   thread: {
     create: emit.POST.Form("thread"),
   },
+  captcha: {
+    // Responds with plain text ID of the new captcha.
+    create: () =>
+      sendJSON("/api/captcha", {}).then(
+        (res) => (res.ok ? res.text() : handleErrorCode(res)),
+        handleError
+      ),
+  },
   user: {
     banByPost: emit.POST.JSON("ban"),
   },
   account: {
     setSettings: emit.POST.JSON("account/settings"),
   },
+  bans: {
+    banRange: emit.POST.JSON("ban-range"),
+    banAccount: emit.POST.JSON("ban-account"),
+  },
+  appeal: {
+    create: emit.POST.JSON("appeal"),
+  },
+  appeals: {
+    get: (b: string) => emit.GET.JSON(`appeals/${b}`)(),
+    resolve: emit.POST.JSON("appeals/resolve"),
+  },
+  notes: {
+    search: (b: string, q: string) =>
+      emit.GET.JSON(`notes/${b}?q=${encodeURIComponent(q)}`)(),
+    getByPost: (id: number) => emit.GET.JSON(`post-notes/${id}`)(),
+    create: emit.POST.JSON("notes"),
+    delete: emit.POST.JSON("notes/delete"),
+  },
+  reports: {
+    get: (b: string) => emit.GET.JSON(`reports/${b}`)(),
+    resolve: emit.POST.JSON("reports/resolve"),
+  },
+  held: {
+    get: (b: string) => emit.GET.JSON(`held/${b}`)(),
+    resolve: emit.POST.JSON("held/resolve"),
+  },
   board: {
     save: (b: string, data: Dict) => emit.PUT.JSON(`boards/${b}`)(data),
   },
@@ -23,14 +23,20 @@ import {
 } from "../util";
 import {
   MODAL_CONTAINER_SEL,
+  POST_FILE_SEL,
   TRIGGER_BAN_BY_POST_SEL,
+  TRIGGER_SHADOW_BAN_BY_POST_SEL,
+  TRIGGER_DELETE_FILE_SEL,
   TRIGGER_DELETE_POST_SEL,
   TRIGGER_IGNORE_USER_SEL,
+  TRIGGER_SPOILER_FILE_SEL,
 } from "../vars";
 import { BackgroundClickMixin, EscapePressMixin, MemberList } from "../widgets";
 import { BoardCreationForm } from "./board-form";
 import { LoginForm, validatePasswordMatch } from "./login-form";
 import { PasswordChangeForm } from "./password-form";
+import { init as initNotes } from "./notes";
+import { init as initReport } from "./report";
 import { ServerConfigForm } from "./server-form";
 
 export const enum ModerationLevel {
@@ -491,8 +497,35 @@ function deletePost(post: Post, force?: boolean) {
   }, showAlert);
 }
 
-function banUser(post: Post) {
-  if (!confirm(_("banConfirm"))) return;
+// Index of the file is taken from markup since the same file might be
+// attached to the post several times.
+function getFileIndex(post: Post, target: Element): number {
+  const fileEls = Array.from(post.view.el.querySelectorAll(POST_FILE_SEL));
+  return fileEls.indexOf(target.closest(POST_FILE_SEL));
+}
+
+function spoilerFile(post: Post, target: Element) {
+  const n = getFileIndex(post, target);
+  if (n < 0) return;
+  API.post.spoilerFile({ id: post.id, file: n }).then(() => {
+    post.files[n].spoiler = true;
+    post.view.renderSpoiler(n);
+  }, showAlert);
+}
+
+function deleteFile(post: Post, target: Element) {
+  const n = getFileIndex(post, target);
+  if (n < 0 || !confirm(_("delFileConfirm"))) return;
+  API.post.deleteFile({ id: post.id, file: n }).then(() => {
+    // In thread we should delete on WebSocket event.
+    if (!page.thread) {
+      post.removeFile(n);
+    }
+  }, showAlert);
+}
+
+function banUser(post: Post, shadow = false) {
+  if (!confirm(_(shadow ? "shadowBanConfirm" : "banConfirm"))) return;
   const YEAR = 365 * 24 * 60;
   API.user
     .banByPost({
@@ -501,15 +534,20 @@ function banUser(post: Post) {
       global: position >= ModerationLevel.admin,
       ids: [post.id],
       reason: "default",
+      shadow,
     })
     .then(() => {
-      deletePost(post, true);
+      // Shadow banned posts stay in place, only hidden from others.
+      if (!shadow) {
+        deletePost(post, true);
+      }
     })
     .catch(showAlert);
 }
 
 export function init() {
   accountPanel = new AccountPanel();
+  initReport();
   if (position === ModerationLevel.notLoggedIn) {
     // tslint:disable-next-line:no-unused-expression
     new LoginForm("login-form", "login");
@@ -531,6 +569,8 @@ export function init() {
     }
   }
   if (position >= ModerationLevel.moderator) {
+    initNotes();
+
     on(
       document,
       "click",
@@ -548,5 +588,32 @@ export function init() {
       },
       { selector: TRIGGER_BAN_BY_POST_SEL }
     );
+
+    on(
+      document,
+      "click",
+      (e) => {
+        banUser(getModelByEvent(e), true);
+      },
+      { selector: TRIGGER_SHADOW_BAN_BY_POST_SEL }
+    );
+
+    on(
+      document,
+      "click",
+      (e) => {
+        spoilerFile(getModelByEvent(e), e.target as Element);
+      },
+      { selector: TRIGGER_SPOILER_FILE_SEL }
+    );
+
+    on(
+      document,
+      "click",
+      (e) => {
+        deleteFile(getModelByEvent(e), e.target as Element);
+      },
+      { selector: TRIGGER_DELETE_FILE_SEL }
+    );
   }
 }
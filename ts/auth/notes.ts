@@ -0,0 +1,71 @@
+/**
+ * Private staff notes on posts and their authors.
+ */
+
+import { showAlert } from "../alerts";
+import API from "../api";
+import _ from "../lang";
+import { getModel } from "../state";
+import { on } from "../util";
+import { TRIGGER_POST_NOTES_SEL } from "../vars";
+
+// MUST BE KEPT IN SYNC WITH go/common/notes.go!
+export interface StaffNote {
+  id: number;
+  board: string;
+  post?: number;
+  byIP: boolean;
+  text: string;
+  by: string;
+  created: number;
+}
+
+// Notes are fetched once per control and shown as its tooltip.
+function showNotes(target: Element) {
+  if (target.hasAttribute("title")) return;
+  const post = getModel(target);
+  if (!post) return;
+  target.setAttribute("title", "…");
+  API.notes.getByPost(post.id).then(
+    (notes: StaffNote[]) => {
+      const title = notes.length
+        ? notes.map((n) => `${n.by}: ${n.text}`).join("\n")
+        : _("No notes");
+      target.setAttribute("title", title);
+    },
+    () => {
+      target.removeAttribute("title");
+    }
+  );
+}
+
+function addNote(target: Element) {
+  const post = getModel(target);
+  if (!post) return;
+  const text = prompt(_("staffNote"));
+  if (!text) return;
+  const byIP = confirm(_("noteByIPConfirm"));
+  API.notes.create({ post: post.id, byIP, text }).then(() => {
+    // Reload on next hover.
+    target.removeAttribute("title");
+  }, showAlert);
+}
+
+export function init() {
+  on(
+    document,
+    "mouseover",
+    (e) => {
+      showNotes(e.target as Element);
+    },
+    { selector: TRIGGER_POST_NOTES_SEL }
+  );
+  on(
+    document,
+    "click",
+    (e) => {
+      addNote(e.target as Element);
+    },
+    { selector: TRIGGER_POST_NOTES_SEL }
+  );
+}
@@ -0,0 +1,160 @@
+/**
+ * Post reports.
+ */
+
+import cx from "classnames";
+import { Component, h, render } from "preact";
+import { showAlert } from "../alerts";
+import API from "../api";
+import _ from "../lang";
+import { getModel } from "../state";
+import { hook, HOOKS, on, trigger, unhook } from "../util";
+import { MODAL_CONTAINER_SEL, TRIGGER_REPORT_POST_SEL } from "../vars";
+import { BackgroundClickMixin, EscapePressMixin } from "../widgets";
+
+// MUST BE KEPT IN SYNC WITH go/common/reports.go!
+export const enum ReportReason {
+  spam,
+  illegal,
+  offtopic,
+  abuse,
+  other,
+}
+
+// MUST BE KEPT IN SYNC WITH go/common/reports.go!
+export const enum ReportResolution {
+  dismiss,
+  delete,
+  ban,
+}
+
+export const REPORT_REASONS = [
+  ReportReason.spam,
+  ReportReason.illegal,
+  ReportReason.offtopic,
+  ReportReason.abuse,
+  ReportReason.other,
+];
+
+export const REPORT_REASON_LABELS = [
+  "reportSpam",
+  "reportIllegal",
+  "reportOfftopic",
+  "reportAbuse",
+  "reportOther",
+];
+
+interface ReportState {
+  target?: Element;
+  shown: boolean;
+  left: number;
+  top: number;
+  id: number;
+  text: string;
+  sending: boolean;
+}
+
+class ReportModalBase extends Component<{}, ReportState> {
+  public state: ReportState = {
+    target: null,
+    shown: false,
+    left: 0,
+    top: 0,
+    id: 0,
+    text: "",
+    sending: false,
+  };
+  public componentDidMount() {
+    hook(HOOKS.openReportModal, this.show);
+  }
+  public componentWillUnmount() {
+    unhook(HOOKS.openReportModal, this.show);
+  }
+  public render({}, { shown, left, top, text, sending }: ReportState) {
+    if (!shown) return null;
+    const style = { left, top };
+    return (
+      <div
+        class={cx("report-modal", sending && "report-modal_sending")}
+        style={style}
+        onClick={this.handleModalClick}
+      >
+        <input
+          class="report-modal-text"
+          placeholder={_("reportText")}
+          maxLength={200}
+          value={text}
+          disabled={sending}
+          onInput={this.handleTextChange}
+        />
+        {REPORT_REASONS.map((reason) => (
+          <div
+            class="report-modal-item"
+            onClick={() => this.handleReport(reason)}
+          >
+            {_(REPORT_REASON_LABELS[reason])}
+          </div>
+        ))}
+      </div>
+    );
+  }
+  public onBackgroundClick = (e: MouseEvent) => {
+    if (e.target === this.state.target) return;
+    if (this.state.shown) {
+      this.hide();
+    }
+  };
+  public onEscapePress = () => {
+    this.hide();
+  };
+  private show = (target: Element) => {
+    if (target === this.state.target) {
+      this.hide();
+      return;
+    }
+    const post = getModel(target);
+    if (!post) return;
+    let { left, top } = target.getBoundingClientRect();
+    left += window.pageXOffset - 200;
+    top += window.pageYOffset + 20;
+    this.setState({ shown: true, target, left, top, id: post.id, text: "" });
+  };
+  private hide = () => {
+    if (this.state.sending) return;
+    this.setState({ target: null, shown: false });
+  };
+  private handleModalClick = (e: Event) => {
+    e.stopPropagation();
+  };
+  private handleTextChange = (e: Event) => {
+    const text = (e.target as HTMLInputElement).value;
+    this.setState({ text });
+  };
+  private handleReport(reason: ReportReason) {
+    if (this.state.sending) return;
+    const { id, text } = this.state;
+    this.setState({ sending: true });
+    API.post
+      .report({ id, reason, text })
+      .then(() => showAlert(_("reportSent")), showAlert)
+      .then(() => {
+        this.setState({ sending: false }, this.hide);
+      });
+  }
+}
+
+const ReportModal = EscapePressMixin(BackgroundClickMixin(ReportModalBase));
+
+export function init() {
+  const container = document.querySelector(MODAL_CONTAINER_SEL);
+  if (!container) return;
+  render(<ReportModal />, container);
+  on(
+    document,
+    "click",
+    (e) => {
+      trigger(HOOKS.openReportModal, e.target);
+    },
+    { selector: TRIGGER_REPORT_POST_SEL }
+  );
+}
@@ -0,0 +1,38 @@
+/**
+ * Ban page with appeal forms.
+ *
+ * @module cutechan/banned
+ */
+
+import { showSendAlert } from "../alerts";
+import API from "../api";
+import _ from "../lang";
+import { on } from "../util";
+import { BANNED_APPEAL_FORM_SEL } from "../vars";
+
+function handleAppeal(e: Event) {
+  e.preventDefault();
+  const form = e.target as HTMLFormElement;
+  const board = form.dataset.board;
+  const textarea = form.querySelector("textarea");
+  const button = form.querySelector("button");
+  const text = textarea.value.trim();
+  if (!text) return;
+  button.disabled = true;
+  API.appeal.create({ board, text }).then(
+    () => {
+      const status = document.createElement("div");
+      status.className = "banned-appeal";
+      status.textContent = _("appealPending");
+      form.replaceWith(status);
+    },
+    (err) => {
+      button.disabled = false;
+      showSendAlert(err);
+    }
+  );
+}
+
+export function init() {
+  on(document, "submit", handleAppeal, { selector: BANNED_APPEAL_FORM_SEL });
+}
@@ -5,12 +5,19 @@
 import { showAlert } from "../alerts";
 import { PostData } from "../common";
 import { connEvent, connSM, handlers, message } from "../connection";
+import _ from "../lang";
 import options from "../options";
 import { isHoverActive, Post, PostView } from "../posts";
 import { page, posts } from "../state";
 import { postAdded } from "../ui";
 import { isAtBottom, scrollToBottom } from "../util";
 
+interface SplitMessage {
+  id: number;
+  board: string;
+  posts: number[];
+}
+
 // Run a function on a model, if it exists
 function handle(id: number, fn: (m: Post) => void) {
   const model = posts.get(id);
@@ -59,6 +66,40 @@ export function init() {
   handlers[message.deletePost] = (id: number) =>
     handle(id, (m) => m.setDeleted());
 
+  handlers[message.spoiler] = (msg: { id: number; file: number }) =>
+    handle(msg.id, (m) => {
+      const file = m.files && m.files[msg.file];
+      if (file && !file.spoiler) {
+        file.spoiler = true;
+        m.view.renderSpoiler(msg.file);
+      }
+    });
+
+  handlers[message.deleteImage] = (msg: { id: number; file: number }) =>
+    handle(msg.id, (m) => m.removeFile(msg.file));
+
+  handlers[message.lockThread] =(msg: { id: number; locked: boolean }) => {
+    const thread = document.getElementById(`thread${msg.id}`);
+    if (thread) {
+      thread.classList.toggle("thread_locked", msg.locked);
+      showAlert(_(msg.locked ? "threadLocked" : "threadUnlocked"));
+    }
+  };
+
+  handlers[message.splitThread] = (msg: SplitMessage) => {
+    for (const id of msg.posts) {
+      handle(id, (m) => m.remove());
+    }
+    showAlert({
+      title: _("threadSplit"),
+      message: `/${msg.board}/${msg.id}`,
+    });
+  };
+
+  handlers[message.moveThread] = (msg: { id: number; board: string }) => {
+    location.href = `/${msg.board}/${msg.id}`;
+  };
+
   handlers[message.redirect] = (board: string) => {
     location.href = `/${board}/`;
   };
@@ -93,10 +134,6 @@ export function init() {
   //     m.closePost();
   //   });
 
-  // handlers[message.deleteImage] = (id: number) =>
-  //   handle(id, (m) =>
-  //     m.removeImage());
-
   // handlers[message.banned] = (id: number) =>
   //   handle(id, (m) =>
   //     m.setBanned());
@@ -4,6 +4,7 @@
 export interface ThreadData extends PostData {
   abbrev: boolean;
   sticky: boolean;
+  locked?: boolean;
   postCtr: number;
   imageCtr: number;
   replyTime: number;
@@ -26,6 +27,7 @@ export interface PostData {
   files?: ImageData[];
   op?: number;
   board?: string;
+  shadow?: boolean;
 }
 
 /** Generic link object containing target post board and thread. */
@@ -56,6 +58,14 @@ export interface ImageData {
   title?: string;
   // [width, height, thumbnail_width, thumbnail_height]
   dims: [number, number, number, number];
+  // Base size goes first, empty for old uploads.
+  thumbSizes?: number[];
+  webp?: boolean;
+  // Set by poster or moderator, thumbnail is hidden behind placeholder.
+  spoiler?: boolean;
+  // Expiring URLs of files posted only to mod-only boards.
+  signedSource?: string;
+  signedThumb?: string;
 }
 
 /** Possible file types of a post image. */
@@ -74,6 +84,7 @@ export enum fileTypes {
   "7z",
   "tar.gz",
   "tar.xz",
+  webp,
 }
 
 export const thumbSize = 200;
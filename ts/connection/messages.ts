@@ -15,6 +15,9 @@ export const enum message {
   deletePost,
   banned,
   deleteImage,
+  lockThread,
+  splitThread,
+  moveThread,
 
   // >= 30 are miscellaneous and do not write to post models
   synchronise = 30,
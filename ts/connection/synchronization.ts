@@ -11,10 +11,16 @@ interface SyncData {
   recent: number[]; // Posts created within the last 15 minutes
   open: { [id: number]: OpenPost }; // Posts currently open
   deleted: number[]; // Posts deleted
-  deletedImage: number[]; // Posts deleted in this thread
+  deletedImage: DeletedFile[]; // Files deleted from posts in this thread
   banned: number[]; // Posts banned in this thread
 }
 
+// N-th file deleted from the post
+interface DeletedFile {
+  id: number;
+  file: number;
+}
+
 // State of an open post
 interface OpenPost {
   body: string;
@@ -83,10 +89,12 @@ handlers[message.synchronise] = async (data: SyncData) => {
     //   }
     // }
 
-    // for (const id of deletedImage) {
+    // Page might be rendered after some of these deletions, so they
+    // can't be replayed by index.
+    // for (const { id, file } of deletedImage) {
     //   const post = posts.get(id);
-    //   if (post && post.image) {
-    //     post.removeImage();
+    //   if (post) {
+    //     post.removeFile(file);
     //   }
     // }
 
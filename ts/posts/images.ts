@@ -1,4 +1,4 @@
-import { fileTypes } from "../common";
+import { fileTypes, ImageData } from "../common";
 import { config } from "../state";
 
 export function getFilePrefix(): string {
@@ -12,6 +12,30 @@ export function thumbPath(thumbType: fileTypes, sha1: string): string {
   }`;
 }
 
+// Get URL of the specific thumbnail rendition. Base size is stored
+// without suffix.
+function thumbVariantPath(
+  thumbType: fileTypes, sha1: string, size: number,
+): string {
+  const suffix = size ? `_${size}` : "";
+  return `${getFilePrefix()}/thumb/${sha1.slice(0, 2)}/${sha1.slice(2)}${
+    suffix}.${fileTypes[thumbType]}`;
+}
+
+// Get srcset with all thumbnail renditions of the specified type.
+// Empty if there is nothing to add to the base thumbnail.
+export function thumbSrcset(img: ImageData, thumbType: fileTypes): string {
+  const sizes = img.thumbSizes || [];
+  if (!sizes.length || img.signedThumb) return "";
+  if (thumbType === fileTypes.webp && !img.webp) return "";
+  if (thumbType !== fileTypes.webp && sizes.length < 2) return "";
+  const base = sizes[0];
+  return sizes.map((size, i) => {
+    const url = thumbVariantPath(thumbType, img.SHA1, i ? size : 0);
+    return `${url} ${size / base}x`;
+  }).join(", ");
+}
+
 // Resolve the path to the source file of an upload.
 export function sourcePath(fileType: fileTypes, sha1: string): string {
   return `${getFilePrefix()}/src/${sha1.slice(0, 2)}/${sha1.slice(2)}.${
@@ -107,6 +107,13 @@ export class Post extends Model implements PostData {
     this.view.renderBacklinks();
   }
 
+  // Remove n-th file of the post.
+  public removeFile(n: number) {
+    if (!this.files || !this.files[n]) return;
+    this.files.splice(n, 1);
+    this.view.removeFile(n);
+  }
+
   // Set post as deleted.
   public setDeleted() {
     if (this.isOP()) {
@@ -155,13 +162,16 @@ export class File implements ImageData {
   public length?: number;
   public title?: string;
   public dims: [number, number, number, number];
+  public spoiler?: boolean;
+  public signedSource?: string;
+  public signedThumb?: string;
 
   public get thumb(): string {
-    return thumbPath(this.thumbType, this.SHA1);
+    return this.signedThumb || thumbPath(this.thumbType, this.SHA1);
   }
 
   public get src(): string {
-    return sourcePath(this.fileType, this.SHA1);
+    return this.signedSource || sourcePath(this.fileType, this.SHA1);
   }
 
   public get transparent(): boolean {
@@ -1,12 +1,12 @@
 import cx from "classnames";
 import { Component, h, render } from "preact";
 import vmsg from "vmsg";
-import { showAlert } from "../alerts";
+import { showAlert, showSendAlert } from "../alerts";
 import API from "../api";
 import { isModerator } from "../auth";
 import { PostData } from "../common";
 import _ from "../lang";
-import { boards, config, page, storeMine } from "../state";
+import { boards, getUploadPolicy, page, storeMine } from "../state";
 import { duration, fileSize, renderBody } from "../templates";
 import {
   AbortError,
@@ -36,6 +36,9 @@ import { Progress } from "../widgets";
 import { gen as genSign } from "./signature";
 import SmileBox, { autocomplete } from "./smile-box";
 
+// Returned by server when automod rule demands captcha.
+const CAPTCHA_REQUIRED_ERR = "captcha required";
+
 function quoteText(text: string): string {
   return text
     .trim()
@@ -124,6 +127,19 @@ function getFileInfo(file: File | Blob): Promise<Dict> {
   return fn(file, skipCopy);
 }
 
+// Check file against board's allowed extensions. Mime is used for
+// blobs like recordings which have no name.
+function isAllowedType(file: File | Blob, fileTypes: string[]): boolean {
+  const name = (file as File).name || "";
+  let ext = name.includes(".") ? name.split(".").pop().toLowerCase() : "";
+  if (!ext) {
+    ext = file.type.split("/").pop();
+  }
+  if (ext === "jpeg") ext = "jpg";
+  if (ext === "mpeg") ext = "mp3";
+  return fileTypes.includes(ext);
+}
+
 // Event helpers.
 function getClientX(e: MouseEvent | TouchEvent): number {
   return (e as any).touches
@@ -139,7 +155,9 @@ function getClientY(e: MouseEvent | TouchEvent): number {
 interface FilePreviewProps {
   info: Dict;
   file: File | Blob;
+  spoiler?: boolean;
   onRemove: () => void;
+  onSpoiler: () => void;
 }
 
 class FilePreview extends Component<FilePreviewProps, {}> {
@@ -152,6 +170,17 @@ class FilePreview extends Component<FilePreviewProps, {}> {
         <a class="control reply-remove-file-control" onClick={props.onRemove}>
           <i class="fa fa-remove" />
         </a>
+        <a
+          class={cx(
+            "control",
+            "reply-spoiler-file-control",
+            props.spoiler && "reply-spoiler-file-control_active"
+          )}
+          title={_("spoilerFile")}
+          onClick={props.onSpoiler}
+        >
+          <i class="fa fa-eye-slash" />
+        </a>
         {record ? (
           <div class="reply-file-thumb reply-file-thumb_record">
             <i class="reply-file-thumb-icon fa fa-music" />
@@ -199,6 +228,7 @@ class BodyPreview extends Component<any, any> {
 interface FWrap {
   file: File | Blob;
   info: Dict;
+  spoiler?: boolean;
 }
 
 type FWraps = FWrap[];
@@ -222,6 +252,8 @@ class Reply extends Component<any, any> {
     smileBoxAC: null as string[],
     fwraps: [] as FWraps,
     showBadge: false,
+    captchaID: "",
+    captcha: "",
   };
   private mainEl: HTMLElement = null;
   private bodyEl: HTMLTextAreaElement = null;
@@ -314,6 +346,7 @@ class Reply extends Component<any, any> {
             <div class="reply-content-inner">
               {this.renderHeader()}
               {this.renderBody()}
+              {this.renderCaptcha()}
             </div>
           </div>
 
@@ -675,6 +708,13 @@ class Reply extends Component<any, any> {
     const fwraps = this.state.fwraps.filter((f) => f.info.src !== src);
     this.setState({ fwraps }, this.focus);
   };
+  private handleAttachSpoiler = (src: string) => {
+    if (this.state.sending) return;
+    const fwraps = this.state.fwraps.map((f) =>
+      f.info.src === src ? { ...f, spoiler: !f.spoiler } : f
+    );
+    this.setState({ fwraps });
+  };
   private handleDrop = (files: FileList) => {
     if (files.length) {
       this.handleFiles(files);
@@ -688,11 +728,16 @@ class Reply extends Component<any, any> {
     this.fileEl.value = null; // Allow to select same file again
   };
   private handleFiles = (files: FileList | Blob[]) => {
+    const { maxFiles, textOnly } = getUploadPolicy(this.state.board);
+    if (textOnly) {
+      showAlert(_("textOnly"));
+      return;
+    }
     // Limit number of selected files.
     const fslice: Array<File | Blob> = Array.prototype.slice.call(
       files,
       0,
-      config.maxFiles
+      maxFiles
     );
     const fwrapsOld = this.state.fwraps;
     const fwrapsNew = Array(fslice.length);
@@ -703,7 +748,7 @@ class Reply extends Component<any, any> {
           fwrapsNew[i] = fwrap;
           let fwraps = fwrapsOld.concat(fwrapsNew.filter((f) => f != null));
           // Skip elder attachments.
-          fwraps = fwraps.slice(Math.max(0, fwraps.length - config.maxFiles));
+          fwraps = fwraps.slice(Math.max(0, fwraps.length - maxFiles));
           this.setState({ fwraps }, this.focus);
         },
         (err) => {
@@ -714,15 +759,23 @@ class Reply extends Component<any, any> {
     );
   };
   private handleFile = (file: File | Blob): Promise<FWrap> => {
-    if (file.size > config.maxSize * 1024 * 1024) {
+    const { maxSize, fileTypes } = getUploadPolicy(this.state.board);
+    if (file.size > maxSize * 1024 * 1024) {
       return Promise.reject(new Error(_("tooBig")));
     }
+    if (fileTypes.length && !isAllowedType(file, fileTypes)) {
+      return Promise.reject(new Error(_("fileNotAllowed")));
+    }
     return getFileInfo(file).then((info: Dict) => ({ file, info }));
   };
   private handleSend = () => {
     if (this.disabled) return;
     const { board, thread, subject, body, showBadge } = this.state;
+    const { captchaID, captcha } = this.state;
     const files = this.state.fwraps.map((f) => f.file);
+    const spoilers = this.state.fwraps
+      .map((f, i) => (f.spoiler ? i : -1))
+      .filter((i) => i >= 0);
     const sendFn = page.thread ? API.post.create : API.thread.create;
     this.setState({ sending: true });
     API.post
@@ -736,9 +789,12 @@ class Reply extends Component<any, any> {
             subject,
             body,
             files,
+            spoilers,
             showBadge,
             token,
             sign,
+            captchaID,
+            captcha,
           },
           this.handleSendProgress,
           this.sendAPI
@@ -746,7 +802,12 @@ class Reply extends Component<any, any> {
       })
       .then(
         (res: Dict) => {
-          if (page.thread) {
+          if (res.held) {
+            // Not visible until moderator approves it.
+            storeMine(res.id, page.thread || res.id);
+            this.handleFormHide();
+            showAlert(_("postHeld"));
+          } else if (page.thread) {
             storeMine(res.id, page.thread);
             this.handleFormHide();
           } else {
@@ -756,6 +817,11 @@ class Reply extends Component<any, any> {
         },
         (err: Error) => {
           if (err instanceof AbortError) return;
+          if (err.message === CAPTCHA_REQUIRED_ERR) {
+            this.loadCaptcha();
+            showAlert({ title: _("sendErr"), message: _("solveCaptcha") });
+            return;
+          }
           showAlert({ title: _("sendErr"), message: err.message });
         }
       )
@@ -764,6 +830,15 @@ class Reply extends Component<any, any> {
         this.sendAPI = {};
       });
   };
+  private loadCaptcha = () => {
+    API.captcha.create().then((captchaID: string) => {
+      this.setState({ captchaID, captcha: "" });
+    }, showSendAlert);
+  };
+  private handleCaptchaChange = (e: Event) => {
+    const captcha = (e.target as HTMLInputElement).value;
+    this.setState({ captcha });
+  };
   private handleSendProgress = (e: ProgressEvent) => {
     const progress = Math.floor((e.loaded / e.total) * 100);
     this.setState({ progress });
@@ -830,12 +905,14 @@ class Reply extends Component<any, any> {
     const { fwraps } = this.state;
     return (
       <div class="reply-files">
-        {fwraps.map(({ file, info }) => (
+        {fwraps.map(({ file, info, spoiler }) => (
           <FilePreview
             key={info.src}
             info={info}
             file={file}
+            spoiler={spoiler}
             onRemove={this.handleAttachRemove.bind(null, info.src)}
+            onSpoiler={this.handleAttachSpoiler.bind(null, info.src)}
           />
         ))}
       </div>
@@ -874,6 +951,27 @@ class Reply extends Component<any, any> {
       <BodyPreview body={body} />
     );
   }
+  private renderCaptcha() {
+    const { sending, captchaID, captcha } = this.state;
+    if (!captchaID) return null;
+    return (
+      <div class="reply-captcha">
+        <img
+          class="reply-captcha-image"
+          src={`/api/captcha/${captchaID}.png`}
+          title={_("reloadCaptcha")}
+          onClick={this.loadCaptcha}
+        />
+        <input
+          class="reply-captcha-input"
+          placeholder={_("Captcha")}
+          value={captcha}
+          disabled={sending}
+          onInput={this.handleCaptchaChange}
+        />
+      </div>
+    );
+  }
   private renderSideControls() {
     const { float, sending } = this.state;
     return (
@@ -904,14 +1002,15 @@ class Reply extends Component<any, any> {
     );
   }
   private renderFooterControls() {
-    const { editing, sending, progress, showBadge } = this.state;
+    const { board, editing, sending, progress, showBadge } = this.state;
+    const { maxSize, textOnly } = getUploadPolicy(board);
     const sendTitle = sending ? `${progress}% (${_("clickToCancel")})` : "";
     return (
       <div class="reply-controls reply-footer-controls">
         <button
           class="control reply-footer-control reply-attach-control"
-          title={printf(_("attach"), fileSize(config.maxSize * 1024 * 1024))}
-          disabled={sending}
+          title={printf(_("attach"), fileSize(maxSize * 1024 * 1024))}
+          disabled={sending || textOnly}
           onClick={this.handleAttach}
         >
           <i class="fa fa-file-image-o" />
@@ -919,7 +1018,7 @@ class Reply extends Component<any, any> {
         <button
           class="control reply-footer-control reply-record-control"
           title={_("record")}
-          disabled={sending}
+          disabled={sending || textOnly}
           onClick={this.handleRecord}
         >
           <i class="fa fa-file-audio-o" />
@@ -1031,6 +1130,10 @@ class ReplyContainer extends Component<any, any> {
       document,
       "click",
       () => {
+        if (document.querySelector(".thread_locked")) {
+          showAlert(_("threadLocked"));
+          return;
+        }
         this.setState({ show: true });
       },
       { selector: TRIGGER_OPEN_REPLY_SEL }
@@ -10,7 +10,7 @@ import {
   TemplateContext,
 } from "../templates";
 import { getID } from "../util";
-import { POST_BACKLINKS_SEL, THREAD_SEL } from "../vars";
+import { POST_BACKLINKS_SEL, POST_FILE_SEL, THREAD_SEL } from "../vars";
 import { render as renderEmbeds } from "./embed";
 import { Post, Thread } from "./model";
 
@@ -69,6 +69,30 @@ export default class PostView extends View<Post> {
     container.innerHTML = html;
   }
 
+  // Hide thumbnail of n-th file behind placeholder, same as template
+  // does for spoilered files.
+  public renderSpoiler(n: number) {
+    const fileEl = this.el.querySelectorAll(POST_FILE_SEL)[n];
+    const picture = fileEl && fileEl.querySelector("picture");
+    if (!picture) return;
+    const placeholder = document.createElement("i");
+    placeholder.className =
+      "post-file-thumb post-file-spoiler trigger-media-popup fa fa-eye-slash";
+    placeholder.dataset.sha1 = this.model.files[n].SHA1;
+    picture.replaceWith(placeholder);
+    const control = fileEl.querySelector(".post-file-spoiler-control");
+    if (control) {
+      control.remove();
+    }
+  }
+
+  public removeFile(n: number) {
+    const fileEl = this.el.querySelectorAll(POST_FILE_SEL)[n];
+    if (fileEl) {
+      fileEl.remove();
+    }
+  }
+
   public removeThread() {
     this.el.closest(THREAD_SEL).remove();
   }
@@ -18,12 +18,27 @@ export interface BoardConfig {
   id: string;
   title: string;
   readOnly?: boolean;
+  // Upload policy, unset values fallback to server ones.
+  maxSize?: number;
+  maxFiles?: number;
+  fileTypes?: string[];
+  textOnly?: boolean;
+}
+
+// Effective upload limits of the board
+export interface UploadPolicy {
+  maxSize: number;
+  maxFiles: number;
+  // Allowed file extensions, empty means any supported.
+  fileTypes: string[];
+  textOnly: boolean;
 }
 
 // The current state of a board or thread page
 export interface PageState {
   landing: boolean;
   stickers: boolean;
+  banned: boolean;
   admin: string;
   catalog: boolean;
   thread: number;
@@ -62,6 +77,7 @@ function getState(href: string): PageState {
     href,
     landing: pathname === "/",
     stickers: pathname.startsWith("/stickers/"),
+    banned: pathname === "/banned",
     admin: admin ? admin[1] || "all" : "",
     lastN: /[&\?]last=100/.test(u.search) ? 100 : 0,
     page: pageN ? parseInt(pageN[1], 10) : 0,
@@ -79,6 +95,19 @@ export const config: Config = (window as any).config;
 // Currently existing boards
 export let boards: BoardConfig[] = (window as any).boards;
 
+// Board settings can only tighten server limits, same as on server side.
+export function getUploadPolicy(board: string): UploadPolicy {
+  const b = boards.find((c) => c.id === board) || ({} as BoardConfig);
+  return {
+    maxSize: b.maxSize ? Math.min(b.maxSize, config.maxSize) : config.maxSize,
+    maxFiles: b.maxFiles
+      ? Math.min(b.maxFiles, config.maxFiles)
+      : config.maxFiles,
+    fileTypes: b.fileTypes || [],
+    textOnly: !!b.textOnly,
+  };
+}
+
 // All posts currently displayed
 export const posts = new PostCollection();
 
@@ -6,7 +6,14 @@ import Mustache from "mustache";
 import { bodyEmbeds, renderBody } from ".";
 import { fileTypes, ImageData } from "../common";
 import { _, days, months, ngettext } from "../lang";
-import { Backlinks, Post, sourcePath, Thread, thumbPath } from "../posts";
+import {
+  Backlinks,
+  Post,
+  sourcePath,
+  Thread,
+  thumbPath,
+  thumbSrcset,
+} from "../posts";
 import { mine } from "../state";
 import { Dict, makeNode, pad, printf } from "../util";
 
@@ -68,6 +75,9 @@ export function makePostContext(
         break;
       }
     }
+    if (ctx.post.shadow) {
+      classes.push("post_shadow");
+    }
     if (!p.userID) {
       classes.push("post_by-anon");
     } else {
@@ -103,14 +113,18 @@ export function makePostContext(
       HasLength: img.video || img.audio,
       Length: duration(img.length || 0),
       Record: img.audio && !img.video,
+      Spoiler: !!img.spoiler,
       Size: fileSize(img.size),
       Width: img.dims[0],
       Height: img.dims[1],
       TWidth: img.dims[2],
       THeight: img.dims[3],
       DName: getDownloadName(p, img, n),
-      SourcePath: sourcePath(img.fileType, img.SHA1),
-      ThumbPath: thumbPath(img.thumbType, img.SHA1),
+      SourcePath: img.signedSource || sourcePath(img.fileType, img.SHA1),
+      ThumbPath: img.signedThumb || thumbPath(img.thumbType, img.SHA1),
+      ThumbSrcset: thumbSrcset(img, img.thumbType),
+      HasWebP: !!img.webp && !img.signedThumb,
+      WebPSrcset: thumbSrcset(img, fileTypes.webp),
     }).render()
   );
 
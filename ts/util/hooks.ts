@@ -18,6 +18,7 @@ export const enum HOOKS {
   spoilerMarkup,
   focusIdolSearch,
   openIgnoreModal,
+  openReportModal,
 }
 
 const hooks = new EventEmitter();
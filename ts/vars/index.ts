@@ -17,6 +17,7 @@ export const THREAD_SEL = ".thread";
 export const POST_SEL = ".post";
 export const POST_LINK_SEL = ".post-link";
 export const POST_BODY_SEL = ".post-body";
+export const POST_FILE_SEL = ".post-file";
 export const POST_FILE_TITLE_SEL = ".post-file-title";
 export const POST_FILE_LINK_SEL = ".post-file-link";
 export const POST_FILE_THUMB_SEL = ".post-file-thumb";
@@ -24,13 +25,19 @@ export const POST_BACKLINKS_SEL = ".post-backlinks";
 export const POST_EMBED_SEL = ".post-embed";
 export const PAGE_NAV_TOP_SEL = ".page-nav-top";
 export const PAGE_NAV_BOTTOM_SEL = ".page-nav-bottom";
+export const BANNED_APPEAL_FORM_SEL = ".banned-appeal-form";
 
 // Action trigger selectors, might appear multiple times in markup.
 export const TRIGGER_OPEN_REPLY_SEL = ".trigger-open-reply";
 export const TRIGGER_QUOTE_POST_SEL = ".trigger-quote-post";
 export const TRIGGER_DELETE_POST_SEL = ".trigger-delete-post";
 export const TRIGGER_BAN_BY_POST_SEL = ".trigger-ban-by-post";
+export const TRIGGER_SHADOW_BAN_BY_POST_SEL = ".trigger-shadow-ban-by-post";
+export const TRIGGER_POST_NOTES_SEL = ".trigger-post-notes";
+export const TRIGGER_SPOILER_FILE_SEL = ".trigger-spoiler-file";
+export const TRIGGER_DELETE_FILE_SEL = ".trigger-delete-file";
 export const TRIGGER_IGNORE_USER_SEL = ".trigger-ignore-user";
+export const TRIGGER_REPORT_POST_SEL = ".trigger-report-post";
 export const TRIGGER_MEDIA_HOVER_SEL = ".trigger-media-hover";
 export const TRIGGER_MEDIA_POPUP_SEL = ".trigger-media-popup";
 export const TRIGGER_PAGE_NAV_TOP_SEL = ".trigger-page-nav-top";